@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/therecipe/qt/core"
@@ -12,9 +13,30 @@ import (
 	"github.com/therecipe/qt/widgets"
 
 	"Nix-Client-Launcher/internal/auth"
+	"Nix-Client-Launcher/internal/download"
+	"Nix-Client-Launcher/internal/game/version"
+	"Nix-Client-Launcher/internal/instance"
+	"Nix-Client-Launcher/internal/java"
+	"Nix-Client-Launcher/internal/launch"
+	"Nix-Client-Launcher/internal/launch/process"
+	"Nix-Client-Launcher/internal/loader/fabric"
+	"Nix-Client-Launcher/internal/memory"
+	"Nix-Client-Launcher/internal/modrinth"
+	"Nix-Client-Launcher/internal/mods"
+	"Nix-Client-Launcher/internal/modpack/channel"
 	"Nix-Client-Launcher/internal/storage"
 )
 
+// defaultVersionID and defaultLoader are what the Nix client currently targets
+const (
+	defaultVersionID = "1.21.11"
+	defaultLoader    = "fabric"
+)
+
+// appCtx is cancelled when the application quits, so logins and token
+// refreshes still running in the background stop with it
+var appCtx, quitApp = context.WithCancel(context.Background())
+
 func main() {
 	// Force Wayland if available. 
 	// Note: The user must have qt6-wayland (or qt5-wayland) installed on their system.
@@ -25,6 +47,7 @@ func main() {
 
 	// Create the application
 	app := widgets.NewQApplication(len(os.Args), os.Args)
+	app.ConnectAboutToQuit(quitApp)
 
 	// Locate media directory
 	mediaDir := findMediaDir()
@@ -41,20 +64,12 @@ func main() {
 	// Check for existing login
 	account, err := storage.LoadAccount()
 	if err == nil && account.Tokens.MinecraftAccessToken != "" {
-		// Check expiry
-		if time.Now().After(account.Tokens.MinecraftExpiry) {
-			// Refresh
-			refreshedAccount, err := auth.RefreshLogin(account)
+		openAccount(account, func(err error) {
 			if err != nil {
 				fmt.Println("Failed to refresh token, requiring login:", err)
 				showLoginWindow(mediaDir)
-			} else {
-				showMainWindow(refreshedAccount)
 			}
-		} else {
-			// Valid token
-			showMainWindow(account)
-		}
+		})
 	} else {
 		showLoginWindow(mediaDir)
 	}
@@ -65,23 +80,57 @@ func main() {
 
 // findMediaDir attempts to locate the media directory relative to the working directory
 func findMediaDir() string {
+	return findResourceDir("media")
+}
+
+// findResourceDir attempts to locate a bundled directory (media, read) relative to the working directory
+func findResourceDir(name string) string {
 	wd, _ := os.Getwd()
 	
 	// Check current directory
-	path := filepath.Join(wd, "media")
+	path := filepath.Join(wd, name)
 	if _, err := os.Stat(path); err == nil {
 		return path
 	}
 	
 	// Check parent directory (useful if running from Main/ subdirectory)
-	path = filepath.Join(wd, "..", "media")
+	path = filepath.Join(wd, "..", name)
 	if _, err := os.Stat(path); err == nil {
 		return path
 	}
 	
 	// Check project root assumption (hardcoded fallback)
 	// Adjust this if your project path is fixed
-	return filepath.Join(wd, "media") 
+	return filepath.Join(wd, name) 
+}
+
+// openAccount makes account the active one and shows the main window for it.
+// Expired tokens are refreshed in the background first. done is called on the
+// Qt thread once the window is up, or with the error when the refresh failed,
+// in which case the active account is left alone.
+func openAccount(account *storage.AccountData, done func(error)) {
+	open := func(a *storage.AccountData) {
+		if err := storage.SetActiveAccount(a.Profile.ID); err != nil {
+			fmt.Println("Failed to switch account:", err)
+		}
+		showMainWindow(a)
+		done(nil)
+	}
+	if !time.Now().After(account.Tokens.MinecraftExpiry) {
+		open(account)
+		return
+	}
+
+	go func() {
+		refreshed, err := auth.RefreshLogin(appCtx, account)
+		runOnMainThread(func() {
+			if err != nil {
+				done(err)
+				return
+			}
+			open(refreshed)
+		})
+	}()
 }
 
 func showLoginWindow(mediaDir string) {
@@ -119,13 +168,62 @@ func showLoginWindow(mediaDir string) {
 	textLabel.SetAlignment(core.Qt__AlignCenter)
 	layout.AddWidget(textLabel, 0, core.Qt__AlignCenter)
 
+	// Accounts logged in before, so switching back needs no new login
+	if accounts, err := storage.LoadAccounts(); err == nil && len(accounts.Accounts) > 0 {
+		saved := accounts.List()
+		accountList := widgets.NewQListWidget(centralWidget)
+		accountList.SetIconSize(core.NewQSize2(32, 32))
+		for i, account := range saved {
+			item := widgets.NewQListWidgetItem2(account.Profile.DisplayName(), accountList, 0)
+			if account.Profile.ID == accounts.Active {
+				accountList.SetCurrentRow(i)
+			}
+			loadSkinHead(account, func(head *gui.QIcon) {
+				item.SetIcon(head)
+			})
+		}
+		layout.AddWidget(accountList, 0, 0)
+
+		continueButton := widgets.NewQPushButton2("Continue", centralWidget)
+		continueButton.SetFixedWidth(200)
+		continueButton.ConnectClicked(func(checked bool) {
+			row := accountList.CurrentRow()
+			if row < 0 || row >= len(saved) {
+				return
+			}
+			account := saved[row]
+			accountList.SetEnabled(false)
+			continueButton.SetEnabled(false)
+			openAccount(&account, func(err error) {
+				if err != nil {
+					accountList.SetEnabled(true)
+					continueButton.SetEnabled(true)
+					widgets.QMessageBox_Critical(window, "Login Error", fmt.Sprintf("Failed to refresh the login for %s, log in again: %v", account.Profile.DisplayName(), err), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+					return
+				}
+				window.Close()
+			})
+		})
+		layout.AddWidget(continueButton, 0, core.Qt__AlignCenter)
+		textLabel.SetText("Pick an account or login with another one")
+	}
+
 	// Button
 	loginButton := widgets.NewQPushButton2("Login", centralWidget)
 	loginButton.SetFixedWidth(200)
 	loginButton.ConnectClicked(func(checked bool) {
+		startBrowserLogin(window)
+	})
+	layout.AddWidget(loginButton, 0, core.Qt__AlignCenter)
+
+	// Device code login, for when the browser can't reach the launcher
+	deviceButton := widgets.NewQPushButton2("Login with a code instead", centralWidget)
+	deviceButton.SetFixedWidth(200)
+	deviceButton.SetFlat(true)
+	deviceButton.ConnectClicked(func(checked bool) {
 		// Start Device Flow in a goroutine to prevent freezing
 		go func() {
-			flow, err := auth.StartDeviceLogin()
+			flow, err := auth.StartDeviceLogin(appCtx)
 			if err != nil {
 				timer := core.NewQTimer(nil)
 				timer.SetSingleShot(true)
@@ -168,12 +266,20 @@ func showLoginWindow(mediaDir string) {
 				})
 				dLayout.AddWidget(openButton, 0, core.Qt__AlignCenter)
 
+				// Closing the dialog stops the polling
+				ctx, cancel := context.WithCancel(appCtx)
+				dialog.ConnectFinished(func(result int) {
+					cancel()
+				})
+
 				dialog.Show()
 
 				// Start Polling in Background
 				go func() {
-					ctx := context.Background()
 					account, err := flow.WaitForLogin(ctx)
+					if ctx.Err() != nil {
+						return
+					}
 
 					if err != nil {
 						fmt.Println("Login Error:", err)
@@ -193,7 +299,7 @@ func showLoginWindow(mediaDir string) {
 					timer.SetSingleShot(true)
 					timer.ConnectTimeout(func() {
 						dialog.Close()
-						widgets.QMessageBox_Information(window, "Login Successful", "Please restart the launcher.", widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+						showMainWindow(account)
 						window.Close()
 					})
 					timer.Start(0)
@@ -202,11 +308,79 @@ func showLoginWindow(mediaDir string) {
 			timer.Start(0)
 		}()
 	})
-	layout.AddWidget(loginButton, 0, core.Qt__AlignCenter)
+	layout.AddWidget(deviceButton, 0, core.Qt__AlignCenter)
 
 	window.Show()
 }
 
+// startBrowserLogin opens the Microsoft login page in the user's browser and
+// waits for it to redirect back to the launcher
+func startBrowserLogin(window *widgets.QMainWindow) {
+	flow, err := auth.StartBrowserLogin()
+	if err != nil {
+		widgets.QMessageBox_Critical(window, "Error", fmt.Sprintf("Failed to start login: %v", err), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(appCtx)
+
+	dialog := widgets.NewQDialog(window, 0)
+	dialog.SetWindowTitle("Microsoft Login")
+	dialog.SetFixedSize2(400, 200)
+
+	dLayout := widgets.NewQVBoxLayout()
+	dialog.SetLayout(dLayout)
+
+	infoLabel := widgets.NewQLabel(dialog, 0)
+	infoLabel.SetText("Sign in with your Microsoft account in the browser window that just opened.")
+	infoLabel.SetAlignment(core.Qt__AlignCenter)
+	infoLabel.SetWordWrap(true)
+	infoLabel.SetStyleSheet("font-size: 14px; font-weight: bold;")
+	dLayout.AddWidget(infoLabel, 0, core.Qt__AlignCenter)
+
+	reopenButton := widgets.NewQPushButton2("Open Login Page Again", dialog)
+	reopenButton.ConnectClicked(func(checked bool) {
+		gui.QDesktopServices_OpenUrl(core.NewQUrl3(flow.AuthURL, core.QUrl__TolerantMode))
+	})
+	dLayout.AddWidget(reopenButton, 0, core.Qt__AlignCenter)
+
+	cancelButton := widgets.NewQPushButton2("Cancel", dialog)
+	cancelButton.ConnectClicked(func(checked bool) {
+		dialog.Close()
+	})
+	dLayout.AddWidget(cancelButton, 0, core.Qt__AlignCenter)
+
+	// Closing the dialog any way at all gives up on the login
+	dialog.ConnectFinished(func(result int) {
+		cancel()
+	})
+
+	dialog.Show()
+	gui.QDesktopServices_OpenUrl(core.NewQUrl3(flow.AuthURL, core.QUrl__TolerantMode))
+
+	go func() {
+		account, err := flow.WaitForLogin(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			fmt.Println("Login Error:", err)
+			runOnMainThread(func() {
+				dialog.Close()
+				widgets.QMessageBox_Critical(window, "Login Error", fmt.Sprintf("Login failed: %v", err), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+			})
+			return
+		}
+
+		fmt.Println("Login Successful for:", account.Profile.Name)
+		runOnMainThread(func() {
+			dialog.Close()
+			showMainWindow(account)
+			window.Close()
+		})
+	}()
+}
+
 func showMainWindow(account *storage.AccountData) {
 	window := widgets.NewQMainWindow(nil, 0)
 	window.SetWindowTitle("Nix Client Launcher")
@@ -223,12 +397,1264 @@ func showMainWindow(account *storage.AccountData) {
 	welcomeLabel.SetAlignment(core.Qt__AlignCenter)
 	layout.AddWidget(welcomeLabel, 0, core.Qt__AlignCenter)
 
+	// Account picker, the game is launched as the selected account
+	accountRow := widgets.NewQHBoxLayout()
+	accountBox := widgets.NewQComboBox(centralWidget)
+	accountBox.SetIconSize(core.NewQSize2(24, 24))
+	accountBox.SetMinimumWidth(200)
+	addAccountButton := widgets.NewQPushButton2("Add account", centralWidget)
+	logoutButton := widgets.NewQPushButton2("Log out", centralWidget)
+	accountRow.AddStretch(1)
+	accountRow.AddWidget(accountBox, 0, 0)
+	accountRow.AddWidget(addAccountButton, 0, 0)
+	accountRow.AddWidget(logoutButton, 0, 0)
+	accountRow.AddStretch(1)
+	layout.AddLayout(accountRow, 0)
+
+	var accountList []storage.AccountData
+	if accounts, err := storage.LoadAccounts(); err == nil {
+		accountList = accounts.List()
+	}
+	if len(accountList) == 0 {
+		accountList = []storage.AccountData{*account}
+	}
+	for i, a := range accountList {
+		accountBox.AddItem(a.Profile.DisplayName(), core.NewQVariant())
+		if a.Profile.ID == account.Profile.ID {
+			accountBox.SetCurrentIndex(i)
+		}
+		index := i
+		loadSkinHead(a, func(head *gui.QIcon) {
+			accountBox.SetItemIcon(index, head)
+		})
+	}
+
+	dataDir, err := storage.GetConfigDir()
+	if err != nil {
+		widgets.QMessageBox_Critical(window, "Error", fmt.Sprintf("Failed to find the data directory: %v", err), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+		return
+	}
+	store := instance.NewStore(dataDir)
+
+	// Instance picker with the buttons that manage instances
+	instanceRow := widgets.NewQHBoxLayout()
+	instanceBox := widgets.NewQComboBox(centralWidget)
+	instanceBox.SetMinimumWidth(250)
+	newButton := widgets.NewQPushButton2("New", centralWidget)
+	duplicateButton := widgets.NewQPushButton2("Duplicate", centralWidget)
+	deleteButton := widgets.NewQPushButton2("Delete", centralWidget)
+	memoryButton := widgets.NewQPushButton2("Memory...", centralWidget)
+	javaButton := widgets.NewQPushButton2("Java...", centralWidget)
+	modsButton := widgets.NewQPushButton2("Mods...", centralWidget)
+	instanceRow.AddWidget(instanceBox, 0, 0)
+	instanceRow.AddWidget(newButton, 0, 0)
+	instanceRow.AddWidget(duplicateButton, 0, 0)
+	instanceRow.AddWidget(deleteButton, 0, 0)
+	instanceRow.AddWidget(memoryButton, 0, 0)
+	instanceRow.AddWidget(javaButton, 0, 0)
+	instanceRow.AddWidget(modsButton, 0, 0)
+	layout.AddLayout(instanceRow, 0)
+
 	playButton := widgets.NewQPushButton2("Play", centralWidget)
 	layout.AddWidget(playButton, 0, core.Qt__AlignCenter)
 
+	statusLabel := widgets.NewQLabel(centralWidget, 0)
+	statusLabel.SetAlignment(core.Qt__AlignCenter)
+	layout.AddWidget(statusLabel, 0, core.Qt__AlignCenter)
+
+	setStatus := func(text string) {
+		runOnMainThread(func() {
+			statusLabel.SetText(text)
+		})
+	}
+
+	// Nix client modpack, offered once the channel says there is one to install
+	packButton := widgets.NewQPushButton2("Install Nix client", centralWidget)
+	packButton.SetVisible(false)
+	layout.AddWidget(packButton, 0, core.Qt__AlignCenter)
+
+	// instances, pack and running are only touched on the Qt thread. running
+	// holds the games started from this window by instance id.
+	var instances []*instance.Instance
+	var pack *channel.Modpack
+	running := map[string]*launch.Game{}
+
+	selected := func() *instance.Instance {
+		i := instanceBox.CurrentIndex()
+		if i < 0 || i >= len(instances) {
+			return nil
+		}
+		return instances[i]
+	}
+
+	checkPack := func(inst *instance.Instance) {
+		pack = nil
+		packButton.SetVisible(false)
+		work := inst.Clone()
+		go func() {
+			status, available, err := checkNixClient(store, work)
+			if err != nil {
+				fmt.Println("Failed to check Nix client modpack:", err)
+				return
+			}
+			runOnMainThread(func() {
+				// The user may have picked another instance meanwhile
+				if selected() != inst {
+					return
+				}
+				pack = available
+				switch status {
+				case channel.NotInstalled:
+					packButton.SetText(fmt.Sprintf("Install Nix client %s", available.Version))
+					packButton.SetVisible(true)
+				case channel.UpdateAvailable:
+					packButton.SetText(fmt.Sprintf("Update Nix client to %s", available.Version))
+					packButton.SetVisible(true)
+				}
+			})
+		}()
+	}
+
+	reload := func(selectID string) {
+		list, err := ensureDefaultInstance(store, dataDir)
+		if err != nil {
+			fmt.Println("Failed to load instances:", err)
+		}
+		instances = list
+		instanceBox.Clear()
+		current := 0
+		for i, inst := range instances {
+			instanceBox.AddItem(fmt.Sprintf("%s (%s)", inst.Name, inst.MinecraftVersion), core.NewQVariant())
+			if inst.ID == selectID {
+				current = i
+			}
+		}
+		instanceBox.SetCurrentIndex(current)
+		if inst := selected(); inst != nil {
+			checkPack(inst)
+		}
+	}
+
+	updatePlay := func() {
+		if inst := selected(); inst != nil && running[inst.ID] != nil {
+			playButton.SetText("Stop")
+		} else {
+			playButton.SetText("Play")
+		}
+	}
+
+	accountBox.ConnectCurrentIndexChanged(func(index int) {
+		if index < 0 || index >= len(accountList) || accountList[index].Profile.ID == account.Profile.ID {
+			return
+		}
+		picked := accountList[index]
+		// The switch only sticks, on disk too, once the account can play
+		switchTo := func(a *storage.AccountData) {
+			if err := storage.SetActiveAccount(a.Profile.ID); err != nil {
+				fmt.Println("Failed to switch account:", err)
+			}
+			account = a
+			welcomeLabel.SetText(fmt.Sprintf("Welcome, %s!", account.Profile.Name))
+		}
+		if !time.Now().After(picked.Tokens.MinecraftExpiry) {
+			switchTo(&picked)
+			return
+		}
+
+		// Expired tokens are refreshed before the account can play
+		playButton.SetEnabled(false)
+		accountBox.SetEnabled(false)
+		statusLabel.SetText("Refreshing login...")
+		go func() {
+			refreshed, err := auth.RefreshLogin(appCtx, &picked)
+			runOnMainThread(func() {
+				playButton.SetEnabled(true)
+				accountBox.SetEnabled(true)
+				statusLabel.SetText("")
+				if err != nil {
+					widgets.QMessageBox_Critical(window, "Login Error", fmt.Sprintf("Failed to refresh the login for %s, log in again: %v", picked.Profile.DisplayName(), err), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+					// Show the account still in use, the handler ignores it
+					for i, a := range accountList {
+						if a.Profile.ID == account.Profile.ID {
+							accountBox.SetCurrentIndex(i)
+						}
+					}
+					return
+				}
+				// Refresh tokens are single use, switching back needs the new one
+				accountList[index] = *refreshed
+				switchTo(refreshed)
+			})
+		}()
+	})
+
+	addAccountButton.ConnectClicked(func(checked bool) {
+		if len(running) > 0 {
+			widgets.QMessageBox_Warning(window, "Accounts", "Close Minecraft before adding an account.", widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+			return
+		}
+		showLoginWindow(findMediaDir())
+		window.Close()
+	})
+
+	logoutButton.ConnectClicked(func(checked bool) {
+		if len(running) > 0 {
+			widgets.QMessageBox_Warning(window, "Accounts", "Close Minecraft before logging out.", widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+			return
+		}
+		answer := widgets.QMessageBox_Question(window, "Log out", fmt.Sprintf("Log out %s?", account.Profile.Name), widgets.QMessageBox__Yes|widgets.QMessageBox__No, widgets.QMessageBox__No)
+		if answer != widgets.QMessageBox__Yes {
+			return
+		}
+		if err := storage.RemoveAccount(account.Profile.ID); err != nil {
+			widgets.QMessageBox_Critical(window, "Accounts", fmt.Sprintf("Failed to log out: %v", err), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+			return
+		}
+		next, err := storage.LoadAccount()
+		if err != nil {
+			showLoginWindow(findMediaDir())
+			window.Close()
+			return
+		}
+		// This window stays up until the next one is, closing the last
+		// window would quit the app
+		window.SetEnabled(false)
+		openAccount(next, func(err error) {
+			if err != nil {
+				fmt.Println("Failed to refresh token, requiring login:", err)
+				showLoginWindow(findMediaDir())
+			}
+			window.Close()
+		})
+	})
+
+	instanceBox.ConnectCurrentIndexChanged(func(index int) {
+		updatePlay()
+		if inst := selected(); inst != nil {
+			checkPack(inst)
+		}
+	})
+
+	setBusy := func(busy bool) {
+		for _, w := range []*widgets.QPushButton{packButton, newButton, duplicateButton, deleteButton, memoryButton, javaButton, modsButton} {
+			w.SetEnabled(!busy)
+		}
+		instanceBox.SetEnabled(!busy)
+	}
+
+	newButton.ConnectClicked(func(checked bool) {
+		var ok bool
+		name := widgets.QInputDialog_GetText(window, "New Instance", "Name:", widgets.QLineEdit__Normal, "", &ok, 0, 0)
+		if !ok || name == "" {
+			return
+		}
+		mc := widgets.QInputDialog_GetText(window, "New Instance", "Minecraft version:", widgets.QLineEdit__Normal, defaultVersionID, &ok, 0, 0)
+		if !ok || mc == "" {
+			return
+		}
+		inst, err := store.Create(instance.Instance{Name: name, MinecraftVersion: mc, Loader: defaultLoader})
+		if err != nil {
+			widgets.QMessageBox_Critical(window, "Instance Error", fmt.Sprintf("Failed to create the instance: %v", err), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+			return
+		}
+		reload(inst.ID)
+	})
+
+	duplicateButton.ConnectClicked(func(checked bool) {
+		src := selected()
+		if src == nil {
+			return
+		}
+		var ok bool
+		name := widgets.QInputDialog_GetText(window, "Duplicate Instance", "Name:", widgets.QLineEdit__Normal, src.Name+" copy", &ok, 0, 0)
+		if !ok || name == "" {
+			return
+		}
+		setBusy(true)
+		playButton.SetEnabled(false)
+		statusLabel.SetText("Copying instance...")
+		go func() {
+			dup, err := store.Duplicate(src.ID, name)
+			runOnMainThread(func() {
+				setBusy(false)
+				playButton.SetEnabled(true)
+				statusLabel.SetText("")
+				if err != nil {
+					widgets.QMessageBox_Critical(window, "Instance Error", fmt.Sprintf("Failed to duplicate the instance: %v", err), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+					return
+				}
+				reload(dup.ID)
+			})
+		}()
+	})
+
+	deleteButton.ConnectClicked(func(checked bool) {
+		inst := selected()
+		if inst == nil {
+			return
+		}
+		if running[inst.ID] != nil {
+			widgets.QMessageBox_Warning(window, "Delete Instance", fmt.Sprintf("%s is still running.", inst.Name), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+			return
+		}
+		answer := widgets.QMessageBox_Question(window, "Delete Instance", fmt.Sprintf("Delete %s and its worlds, mods and settings?", inst.Name), widgets.QMessageBox__Yes|widgets.QMessageBox__No, widgets.QMessageBox__No)
+		if answer != widgets.QMessageBox__Yes {
+			return
+		}
+		if err := store.Delete(inst.ID); err != nil {
+			widgets.QMessageBox_Critical(window, "Instance Error", fmt.Sprintf("Failed to delete the instance: %v", err), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+		}
+		reload("")
+	})
+
+	memoryButton.ConnectClicked(func(checked bool) {
+		inst := selected()
+		if inst == nil {
+			return
+		}
+		limit := 65536
+		if info, err := memory.Read(); err == nil {
+			limit = info.UsableMB()
+		}
+		var ok bool
+		maxMB := widgets.QInputDialog_GetInt(window, "Memory", "Maximum memory (-Xmx) in MB, 0 for the Java default:", inst.MaxMemoryMB, 0, limit, 256, &ok, 0)
+		if !ok {
+			return
+		}
+		minMB := widgets.QInputDialog_GetInt(window, "Memory", "Minimum memory (-Xms) in MB, 0 for the Java default:", inst.MinMemoryMB, 0, limit, 256, &ok, 0)
+		if !ok {
+			return
+		}
+		if err := memory.Validate(minMB, maxMB); err != nil {
+			widgets.QMessageBox_Warning(window, "Memory", err.Error(), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+			return
+		}
+		inst.MinMemoryMB = minMB
+		inst.MaxMemoryMB = maxMB
+		if err := store.Save(inst); err != nil {
+			widgets.QMessageBox_Critical(window, "Instance Error", fmt.Sprintf("Failed to save the instance: %v", err), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+		}
+	})
+
+	javaButton.ConnectClicked(func(checked bool) {
+		if inst := selected(); inst != nil {
+			showJavaDialog(window, store, inst)
+		}
+	})
+
+	modsButton.ConnectClicked(func(checked bool) {
+		if inst := selected(); inst != nil {
+			showModsDialog(window, store, inst)
+		}
+	})
+
+	packButton.ConnectClicked(func(checked bool) {
+		inst := selected()
+		if pack == nil || inst == nil {
+			return
+		}
+		if running[inst.ID] != nil {
+			widgets.QMessageBox_Warning(window, "Modpack", fmt.Sprintf("Close %s before installing the Nix client.", inst.Name), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+			return
+		}
+		chosen := pack
+		work := inst.Clone()
+		setBusy(true)
+		playButton.SetEnabled(false)
+		go func() {
+			err := installNixClient(store, work, chosen, setStatus)
+			runOnMainThread(func() {
+				setBusy(false)
+				playButton.SetEnabled(true)
+				statusLabel.SetText("")
+				if err == nil {
+					inst.VersionID = work.VersionID
+					err = store.Save(inst)
+				}
+				if err != nil {
+					fmt.Println("Modpack Error:", err)
+					widgets.QMessageBox_Critical(window, "Modpack Error", fmt.Sprintf("Failed to install the Nix client: %v", err), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+					return
+				}
+				packButton.SetVisible(false)
+			})
+		}()
+	})
+
+	// play starts work, a copy of inst, for player and follows the game
+	// until it exits
+	play := func(player *storage.AccountData, inst, work *instance.Instance) {
+		go func() {
+			game, err := startGame(player, store, work, setStatus)
+			// A Fabric profile installed for a launch that then failed is
+			// still worth keeping
+			versionID := work.VersionID
+			if err != nil {
+				fmt.Println("Launch Error:", err)
+				runOnMainThread(func() {
+					if versionID != inst.VersionID {
+						inst.VersionID = versionID
+						if err := store.Save(inst); err != nil {
+							fmt.Println("Failed to save instance:", err)
+						}
+					}
+					playButton.SetEnabled(true)
+					setBusy(false)
+					statusLabel.SetText("")
+					widgets.QMessageBox_Critical(window, "Launch Error", fmt.Sprintf("Failed to launch the game: %v", err), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+				})
+				return
+			}
+
+			runOnMainThread(func() {
+				inst.VersionID = versionID
+				if err := store.MarkPlayed(inst); err != nil {
+					fmt.Println("Failed to save instance:", err)
+				}
+				running[inst.ID] = game
+				updatePlay()
+				playButton.SetEnabled(true)
+				setBusy(false)
+			})
+
+			exit := game.Wait()
+			fmt.Printf("Game exited: code %d signal %q\n", exit.Code, exit.Signal)
+			crashed := game.State() == process.Crashed
+			tail := game.Tail()
+			runOnMainThread(func() {
+				delete(running, inst.ID)
+				updatePlay()
+				playButton.SetEnabled(true)
+				statusLabel.SetText("")
+				if crashed {
+					widgets.QMessageBox_Critical(window, "Game Crashed", crashReport(exit, tail), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+				}
+			})
+		}()
+	}
+
+	playButton.ConnectClicked(func(checked bool) {
+		inst := selected()
+		if inst == nil {
+			return
+		}
+		if game := running[inst.ID]; game != nil {
+			playButton.SetEnabled(false)
+			statusLabel.SetText("Stopping...")
+			go game.Stop(10 * time.Second)
+			return
+		}
+
+		if err := memory.Validate(inst.MinMemoryMB, inst.MaxMemoryMB); err != nil {
+			widgets.QMessageBox_Critical(window, "Launch Error", err.Error(), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+			return
+		}
+		if info, err := memory.Read(); err == nil {
+			var runningMB []int
+			for id := range running {
+				if other, err := store.Get(id); err == nil {
+					runningMB = append(runningMB, other.MaxMemoryMB)
+				}
+			}
+			if warnings := info.Check(inst.MaxMemoryMB, runningMB); len(warnings) > 0 {
+				text := "Minecraft may run out of memory or slow the system down:\n\n" + strings.Join(warnings, "\n") + "\n\nLaunch anyway?"
+				answer := widgets.QMessageBox_Warning(window, "Memory", text, widgets.QMessageBox__Yes|widgets.QMessageBox__No, widgets.QMessageBox__No)
+				if answer != widgets.QMessageBox__Yes {
+					return
+				}
+			}
+		}
+
+		player := account
+		work := inst.Clone()
+		playButton.SetEnabled(false)
+		setBusy(true)
+		statusLabel.SetText("Checking mods...")
+		// Reading the jars and probing java take a while, so the mods are
+		// checked off the UI thread
+		go func() {
+			report, err := checkMods(store, work)
+			runOnMainThread(func() {
+				if err != nil {
+					fmt.Println("Failed to check mods:", err)
+				} else if report.HasErrors() {
+					text := "These problems will probably stop Minecraft from starting:\n\n" + report.String() + "\n\nLaunch anyway?"
+					answer := widgets.QMessageBox_Warning(window, "Mod Problems", text, widgets.QMessageBox__Yes|widgets.QMessageBox__No, widgets.QMessageBox__No)
+					if answer != widgets.QMessageBox__Yes {
+						playButton.SetEnabled(true)
+						setBusy(false)
+						statusLabel.SetText("")
+						return
+					}
+				} else if len(report.Problems) > 0 {
+					fmt.Println(report.String())
+				}
+				play(player, inst, work)
+			})
+		}()
+	})
+
+	reload("")
 	window.Show()
 }
 
+// showJavaDialog lets the user pick the Java an instance runs with, from the
+// installations found on the system or a java binary of their choosing.
+// Automatic leaves JavaPath empty so the Mojang runtime is used.
+func showJavaDialog(parent widgets.QWidget_ITF, store *instance.Store, inst *instance.Instance) {
+	dataDir, err := storage.GetConfigDir()
+	if err != nil {
+		widgets.QMessageBox_Critical(parent, "Java", fmt.Sprintf("Failed to find the data directory: %v", err), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+		return
+	}
+	required := requiredJava(dataDir, inst)
+
+	dialog := widgets.NewQDialog(parent, 0)
+	dialog.SetWindowTitle(fmt.Sprintf("Java - %s", inst.Name))
+	dialog.Resize2(600, 350)
+
+	layout := widgets.NewQVBoxLayout()
+	dialog.SetLayout(layout)
+
+	infoLabel := widgets.NewQLabel(dialog, 0)
+	infoLabel.SetWordWrap(true)
+	if required > 0 {
+		infoLabel.SetText(fmt.Sprintf("Minecraft %s needs Java %d.", inst.MinecraftVersion, required))
+	} else {
+		infoLabel.SetText(fmt.Sprintf("Launch Minecraft %s once to see which Java it needs.", inst.MinecraftVersion))
+	}
+	layout.AddWidget(infoLabel, 0, 0)
+
+	list := widgets.NewQListWidget(dialog)
+	layout.AddWidget(list, 0, 0)
+
+	statusLabel := widgets.NewQLabel(dialog, 0)
+	layout.AddWidget(statusLabel, 0, 0)
+
+	buttonRow := widgets.NewQHBoxLayout()
+	browseButton := widgets.NewQPushButton2("Browse...", dialog)
+	rescanButton := widgets.NewQPushButton2("Rescan", dialog)
+	okButton := widgets.NewQPushButton2("OK", dialog)
+	cancelButton := widgets.NewQPushButton2("Cancel", dialog)
+	buttonRow.AddWidget(browseButton, 0, 0)
+	buttonRow.AddWidget(rescanButton, 0, 0)
+	buttonRow.AddStretch(1)
+	buttonRow.AddWidget(okButton, 0, 0)
+	buttonRow.AddWidget(cancelButton, 0, 0)
+	layout.AddLayout(buttonRow, 0)
+
+	// found is only touched on the Qt thread, row 0 of the list is Automatic
+	var found []java.Installation
+	fill := func(selectPath string) {
+		list.Clear()
+		widgets.NewQListWidgetItem2("Automatic (Mojang runtime)", list, 0)
+		current := 0
+		for i, j := range found {
+			text := fmt.Sprintf("Java %s", j.Version)
+			if j.Vendor != "" {
+				text += " by " + j.Vendor
+			}
+			text += fmt.Sprintf(" - %s (%s)", j.Path, j.Source)
+			if _, err := java.Check(&found[i], required); err != nil {
+				text += " - too old"
+			}
+			widgets.NewQListWidgetItem2(text, list, 0)
+			if j.Path == selectPath {
+				current = i + 1
+			}
+		}
+		list.SetCurrentRow(current)
+	}
+	add := func(j java.Installation) {
+		for _, f := range found {
+			if f.Path == j.Path {
+				return
+			}
+		}
+		found = append(found, j)
+	}
+
+	scan := func() {
+		rescanButton.SetEnabled(false)
+		statusLabel.SetText("Searching for Java...")
+		currentPath := inst.JavaPath
+		go func() {
+			discovered := java.Discover()
+			// The instance's own java may live somewhere Discover doesn't look
+			var current *java.Installation
+			if currentPath != "" {
+				current, _ = java.Probe(currentPath)
+			}
+			runOnMainThread(func() {
+				found = nil
+				if current != nil {
+					current.Source = "chosen"
+					add(*current)
+				}
+				for _, j := range discovered {
+					add(j)
+				}
+				rescanButton.SetEnabled(true)
+				statusLabel.SetText(fmt.Sprintf("Found %d Java installations.", len(discovered)))
+				fill(inst.JavaPath)
+			})
+		}()
+	}
+
+	rescanButton.ConnectClicked(func(checked bool) {
+		scan()
+	})
+
+	browseButton.ConnectClicked(func(checked bool) {
+		path := widgets.QFileDialog_GetOpenFileName(dialog, "Choose a java binary", "/usr/lib/jvm", "", "", 0)
+		if path == "" {
+			return
+		}
+		statusLabel.SetText("Checking " + path + "...")
+		go func() {
+			j, err := java.Probe(path)
+			runOnMainThread(func() {
+				statusLabel.SetText("")
+				if err != nil {
+					widgets.QMessageBox_Warning(dialog, "Java", fmt.Sprintf("%s is not a usable java: %v", path, err), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+					return
+				}
+				j.Source = "chosen"
+				add(*j)
+				fill(j.Path)
+			})
+		}()
+	})
+
+	okButton.ConnectClicked(func(checked bool) {
+		row := list.CurrentRow()
+		path := ""
+		if row > 0 && row <= len(found) {
+			chosen := found[row-1]
+			warning, err := java.Check(&chosen, required)
+			if err != nil {
+				widgets.QMessageBox_Critical(dialog, "Java", err.Error(), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+				return
+			}
+			if warning != "" {
+				answer := widgets.QMessageBox_Warning(dialog, "Java", warning+".\n\nUse it anyway?", widgets.QMessageBox__Yes|widgets.QMessageBox__No, widgets.QMessageBox__No)
+				if answer != widgets.QMessageBox__Yes {
+					return
+				}
+			}
+			path = chosen.Path
+		}
+		inst.JavaPath = path
+		if err := store.Save(inst); err != nil {
+			widgets.QMessageBox_Critical(dialog, "Instance Error", fmt.Sprintf("Failed to save the instance: %v", err), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+			return
+		}
+		dialog.Accept()
+	})
+
+	cancelButton.ConnectClicked(func(checked bool) {
+		dialog.Reject()
+	})
+
+	fill(inst.JavaPath)
+	dialog.Show()
+	scan()
+}
+
+// requiredJava returns the Java major version an instance's Minecraft
+// version asks for, or 0 when it isn't installed yet
+func requiredJava(dataDir string, inst *instance.Instance) int {
+	v, err := version.LoadInstalled(dataDir, inst.LaunchVersion())
+	if err != nil {
+		v, err = version.LoadInstalled(dataDir, inst.MinecraftVersion)
+	}
+	if err != nil || v.JavaVersion == nil {
+		return 0
+	}
+	return v.JavaVersion.MajorVersion
+}
+
+// showModsDialog lists the jars in an instance's mods folder. Ticking a mod
+// enables it, unticking renames it to .jar.disabled.
+func showModsDialog(parent widgets.QWidget_ITF, store *instance.Store, inst *instance.Instance) {
+	gameDir := store.GameDir(inst)
+	dialog := widgets.NewQDialog(parent, 0)
+	dialog.SetWindowTitle(fmt.Sprintf("Mods - %s", inst.Name))
+	dialog.Resize2(600, 450)
+
+	layout := widgets.NewQVBoxLayout()
+	dialog.SetLayout(layout)
+
+	list := widgets.NewQListWidget(dialog)
+	list.SetIconSize(core.NewQSize2(32, 32))
+	layout.AddWidget(list, 0, 0)
+
+	buttonRow := widgets.NewQHBoxLayout()
+	addButton := widgets.NewQPushButton2("Add from Modrinth...", dialog)
+	updateButton := widgets.NewQPushButton2("Check for updates", dialog)
+	rollbackButton := widgets.NewQPushButton2("Roll back updates", dialog)
+	checkButton := widgets.NewQPushButton2("Check problems", dialog)
+	pinButton := widgets.NewQPushButton2("Pin", dialog)
+	deleteButton := widgets.NewQPushButton2("Delete", dialog)
+	buttonRow.AddWidget(addButton, 0, 0)
+	buttonRow.AddWidget(updateButton, 0, 0)
+	buttonRow.AddWidget(rollbackButton, 0, 0)
+	buttonRow.AddWidget(checkButton, 0, 0)
+	buttonRow.AddStretch(1)
+	buttonRow.AddWidget(pinButton, 0, 0)
+	buttonRow.AddWidget(deleteButton, 0, 0)
+	layout.AddLayout(buttonRow, 0)
+
+	statusLabel := widgets.NewQLabel(dialog, 0)
+	layout.AddWidget(statusLabel, 0, 0)
+
+	setBusy := func(busy bool) {
+		for _, b := range []*widgets.QPushButton{addButton, updateButton, checkButton, pinButton, deleteButton} {
+			b.SetEnabled(!busy)
+		}
+		rollbackButton.SetEnabled(!busy && modrinth.HasBackup(store, inst))
+	}
+	setStatus := func(text string) {
+		runOnMainThread(func() {
+			statusLabel.SetText(text)
+		})
+	}
+
+	var installed []*mods.Mod
+	// filling stops the check state changes made while filling the list from
+	// being taken as the user toggling mods
+	filling := false
+
+	refresh := func() {
+		filling = true
+		defer func() { filling = false }()
+
+		list.Clear()
+		var err error
+		installed, err = mods.List(gameDir)
+		if err != nil {
+			widgets.QMessageBox_Critical(dialog, "Mods", fmt.Sprintf("Failed to read the mods folder: %v", err), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+			return
+		}
+		for _, m := range installed {
+			text := m.DisplayName()
+			if m.Version != "" {
+				text += " " + m.Version
+			}
+			if len(m.Authors) > 0 {
+				text += " by " + strings.Join(m.Authors, ", ")
+			}
+			if inst.IsPinned(strings.TrimSuffix(m.FileName, mods.DisabledSuffix)) {
+				text += " (pinned)"
+			}
+			item := widgets.NewQListWidgetItem2(text, list, 0)
+			item.SetToolTip(modToolTip(m))
+			if m.Enabled {
+				item.SetCheckState(core.Qt__Checked)
+			} else {
+				item.SetCheckState(core.Qt__Unchecked)
+			}
+			if data, err := m.IconData(); err == nil {
+				pixmap := gui.NewQPixmap()
+				if pixmap.LoadFromData2(core.NewQByteArray2(string(data), len(data)), "", core.Qt__AutoColor) {
+					item.SetIcon(gui.NewQIcon2(pixmap))
+				}
+			}
+		}
+	}
+
+	list.ConnectItemChanged(func(item *widgets.QListWidgetItem) {
+		row := list.Row(item)
+		if filling || row < 0 || row >= len(installed) {
+			return
+		}
+		m := installed[row]
+		enabled := item.CheckState() == core.Qt__Checked
+		if enabled == m.Enabled {
+			return
+		}
+		if err := m.SetEnabled(enabled); err != nil {
+			widgets.QMessageBox_Critical(dialog, "Mods", fmt.Sprintf("Failed to update %s: %v", m.DisplayName(), err), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+			refresh()
+		}
+	})
+
+	deleteButton.ConnectClicked(func(checked bool) {
+		row := list.CurrentRow()
+		if row < 0 || row >= len(installed) {
+			return
+		}
+		m := installed[row]
+		answer := widgets.QMessageBox_Question(dialog, "Delete Mod", fmt.Sprintf("Delete %s?", m.FileName), widgets.QMessageBox__Yes|widgets.QMessageBox__No, widgets.QMessageBox__No)
+		if answer != widgets.QMessageBox__Yes {
+			return
+		}
+		if err := m.Delete(); err != nil {
+			widgets.QMessageBox_Critical(dialog, "Mods", fmt.Sprintf("Failed to delete %s: %v", m.FileName, err), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+		} else {
+			inst.RemoveMod(strings.TrimSuffix(m.FileName, mods.DisabledSuffix))
+			if err := store.Save(inst); err != nil {
+				fmt.Println("Failed to save instance:", err)
+			}
+		}
+		refresh()
+	})
+
+	addButton.ConnectClicked(func(checked bool) {
+		var ok bool
+		query := widgets.QInputDialog_GetText(dialog, "Add from Modrinth", "Search:", widgets.QLineEdit__Normal, "", &ok, 0, 0)
+		if !ok || query == "" {
+			return
+		}
+		setBusy(true)
+		work := inst.Clone()
+		// done reports the outcome, installed says work now holds new mods
+		done := func(err error, installed bool) {
+			runOnMainThread(func() {
+				setBusy(false)
+				statusLabel.SetText("")
+				if err == nil && installed {
+					inst.Mods = work.Mods
+					err = store.Save(inst)
+				}
+				if err != nil {
+					widgets.QMessageBox_Critical(dialog, "Modrinth", fmt.Sprintf("Failed to add the mod: %v", err), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+				}
+				refresh()
+			})
+		}
+
+		setStatus("Searching Modrinth...")
+		go func() {
+			client := modrinth.NewClient()
+			result, err := client.Search(context.Background(), query, modrinth.SearchOptions{GameVersion: work.MinecraftVersion, Loader: work.Loader, Limit: 20})
+			if err != nil {
+				done(fmt.Errorf("search failed: %v", err), false)
+				return
+			}
+			if len(result.Hits) == 0 {
+				done(fmt.Errorf("nothing for Minecraft %s matches %q", work.MinecraftVersion, query), false)
+				return
+			}
+			runOnMainThread(func() {
+				var items []string
+				for _, hit := range result.Hits {
+					items = append(items, fmt.Sprintf("%s by %s - %s", hit.Title, hit.Author, hit.Description))
+				}
+				var ok bool
+				chosen := widgets.QInputDialog_GetItem(dialog, "Add from Modrinth", "Mod:", items, 0, false, &ok, 0, 0)
+				if !ok {
+					done(nil, false)
+					return
+				}
+				hit := result.Hits[0]
+				for i, item := range items {
+					if item == chosen {
+						hit = result.Hits[i]
+					}
+				}
+				go func() {
+					err := installModrinthMod(client, store, work, hit.ProjectID, setStatus)
+					done(err, err == nil)
+				}()
+			})
+		}()
+	})
+
+	list.ConnectCurrentRowChanged(func(row int) {
+		if row >= 0 && row < len(installed) && inst.IsPinned(strings.TrimSuffix(installed[row].FileName, mods.DisabledSuffix)) {
+			pinButton.SetText("Unpin")
+		} else {
+			pinButton.SetText("Pin")
+		}
+	})
+
+	pinButton.ConnectClicked(func(checked bool) {
+		row := list.CurrentRow()
+		if row < 0 || row >= len(installed) {
+			return
+		}
+		name := strings.TrimSuffix(installed[row].FileName, mods.DisabledSuffix)
+		inst.SetPinned(name, !inst.IsPinned(name))
+		if err := store.Save(inst); err != nil {
+			widgets.QMessageBox_Critical(dialog, "Mods", fmt.Sprintf("Failed to save the instance: %v", err), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+		}
+		refresh()
+		list.SetCurrentRow(row)
+	})
+
+	updateButton.ConnectClicked(func(checked bool) {
+		setBusy(true)
+		statusLabel.SetText("Checking for updates...")
+		work := inst.Clone()
+		go func() {
+			client := modrinth.NewClient()
+			updates, err := client.CheckUpdates(context.Background(), store, work)
+			runOnMainThread(func() {
+				statusLabel.SetText("")
+				if err != nil {
+					setBusy(false)
+					widgets.QMessageBox_Critical(dialog, "Mod Updates", fmt.Sprintf("Failed to check for updates: %v", err), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+					return
+				}
+				if len(updates) == 0 {
+					setBusy(false)
+					widgets.QMessageBox_Information(dialog, "Mod Updates", "All mods are up to date.", widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+					return
+				}
+
+				preview := "These mods have updates:\n"
+				for _, u := range updates {
+					preview += fmt.Sprintf("\n%s: %s -> %s", u.Mod.DisplayName(), u.Current.VersionNumber, u.Latest.VersionNumber)
+				}
+				preview += "\n\nUpdate them now? The current jars are kept so the update can be rolled back."
+				answer := widgets.QMessageBox_Question(dialog, "Mod Updates", preview, widgets.QMessageBox__Yes|widgets.QMessageBox__No, widgets.QMessageBox__Yes)
+				if answer != widgets.QMessageBox__Yes {
+					setBusy(false)
+					return
+				}
+
+				go func() {
+					events := make(chan download.Event)
+					done := make(chan struct{})
+					go func() {
+						defer close(done)
+						reportProgress(events, setStatus)
+					}()
+					err := modrinth.ApplyUpdates(context.Background(), download.NewManager(), store, work, updates, events)
+					close(events)
+					<-done
+					runOnMainThread(func() {
+						if err == nil {
+							inst.Mods = work.Mods
+							if err := store.Save(inst); err != nil {
+								fmt.Println("Failed to save instance:", err)
+							}
+						}
+						setBusy(false)
+						statusLabel.SetText("")
+						if err != nil {
+							widgets.QMessageBox_Critical(dialog, "Mod Updates", fmt.Sprintf("Failed to update mods, nothing was changed: %v", err), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+						}
+						refresh()
+					})
+				}()
+			})
+		}()
+	})
+
+	checkButton.ConnectClicked(func(checked bool) {
+		work := inst.Clone()
+		setBusy(true)
+		statusLabel.SetText("Checking mods...")
+		go func() {
+			report, err := checkMods(store, work)
+			runOnMainThread(func() {
+				setBusy(false)
+				statusLabel.SetText("")
+				if err != nil {
+					widgets.QMessageBox_Critical(dialog, "Mod Problems", fmt.Sprintf("Failed to check mods: %v", err), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+					return
+				}
+				widgets.QMessageBox_Information(dialog, "Mod Problems", report.String(), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+			})
+		}()
+	})
+
+	rollbackButton.ConnectClicked(func(checked bool) {
+		answer := widgets.QMessageBox_Question(dialog, "Mod Updates", "Put back the mods replaced by the last update?", widgets.QMessageBox__Yes|widgets.QMessageBox__No, widgets.QMessageBox__No)
+		if answer != widgets.QMessageBox__Yes {
+			return
+		}
+		if err := modrinth.Rollback(store, inst); err != nil {
+			widgets.QMessageBox_Critical(dialog, "Mod Updates", fmt.Sprintf("Failed to roll back: %v", err), widgets.QMessageBox__Ok, widgets.QMessageBox__Ok)
+		}
+		setBusy(false)
+		refresh()
+	})
+
+	setBusy(false)
+	refresh()
+	dialog.Exec()
+}
+
+// checkMods looks for missing dependencies and conflicts among the instance's
+// enabled mods before java is started
+func checkMods(store *instance.Store, inst *instance.Instance) (*mods.Report, error) {
+	installed, err := mods.List(store.GameDir(inst))
+	if err != nil {
+		return nil, err
+	}
+	loaderVersion := inst.LoaderVersion
+	if loaderVersion == "" && inst.Loader == "fabric" {
+		loaderVersion = fabric.LoaderFromProfileID(inst.VersionID, inst.MinecraftVersion)
+	}
+	return mods.Analyze(installed, mods.Environment{
+		MinecraftVersion: inst.MinecraftVersion,
+		Loader:           inst.Loader,
+		LoaderVersion:    loaderVersion,
+		JavaVersion:      instanceJava(inst),
+	}), nil
+}
+
+// instanceJava is the major version of the java the instance will run with,
+// the one picked for it or else the runtime its version asks for. It is 0
+// when neither is known.
+func instanceJava(inst *instance.Instance) int {
+	if inst.JavaPath != "" {
+		if found, err := java.Probe(inst.JavaPath); err == nil {
+			return found.Major
+		}
+		return 0
+	}
+	dataDir, err := storage.GetConfigDir()
+	if err != nil {
+		return 0
+	}
+	return requiredJava(dataDir, inst)
+}
+
+// installModrinthMod installs a Modrinth project and its required
+// dependencies into the instance
+func installModrinthMod(client *modrinth.Client, store *instance.Store, inst *instance.Instance, projectID string, status func(string)) error {
+	status("Resolving dependencies...")
+	plan, err := client.Resolve(context.Background(), store, inst, projectID)
+	if err != nil {
+		return err
+	}
+
+	events := make(chan download.Event)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		reportProgress(events, status)
+	}()
+	err = plan.Install(context.Background(), download.NewManager(), store, inst, events)
+	close(events)
+	<-done
+	return err
+}
+
+// modToolTip describes a mod's id, file and dependencies
+func modToolTip(m *mods.Mod) string {
+	lines := []string{m.FileName}
+	if m.ID != "" {
+		lines = append(lines, fmt.Sprintf("%s mod %s", m.Loader, m.ID))
+	}
+	if m.Description != "" {
+		lines = append(lines, m.Description)
+	}
+	for _, dep := range m.Dependencies {
+		versions := "any version"
+		if len(dep.Versions) > 0 {
+			versions = strings.Join(dep.Versions, " or ")
+		}
+		lines = append(lines, fmt.Sprintf("%s %s (%s)", dep.Kind, dep.ID, versions))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// loadSkinHead fetches an account's skin and calls done on the Qt thread with
+// its face, hat layer included. done is not called for the default skin or
+// when the skin can't be fetched.
+func loadSkinHead(account storage.AccountData, done func(*gui.QIcon)) {
+	if account.Profile.Skin == "" {
+		return
+	}
+	dataDir, err := storage.GetConfigDir()
+	if err != nil {
+		return
+	}
+	// Skin URLs end in the texture hash, so a cached file never goes stale
+	path := filepath.Join(dataDir, "cache", "skins", filepath.Base(account.Profile.Skin)+".png")
+
+	go func() {
+		err := download.NewManager().Download(context.Background(), []download.File{{URL: account.Profile.Skin, Path: path}}, nil)
+		if err != nil {
+			fmt.Println("Failed to download skin:", err)
+			return
+		}
+		runOnMainThread(func() {
+			skin := gui.NewQPixmap3(path, "PNG", core.Qt__AutoColor)
+			if skin.IsNull() || skin.Width() < 64 {
+				return
+			}
+			head := skin.Copy2(8, 8, 8, 8).Scaled2(32, 32, core.Qt__IgnoreAspectRatio, core.Qt__FastTransformation)
+			hat := skin.Copy2(40, 8, 8, 8).Scaled2(32, 32, core.Qt__IgnoreAspectRatio, core.Qt__FastTransformation)
+			painter := gui.NewQPainter2(head)
+			painter.DrawPixmap10(core.NewQRect4(0, 0, 32, 32), hat)
+			painter.End()
+			done(gui.NewQIcon2(head))
+		})
+	}()
+}
+
+// crashReport summarises how the game died with the last lines it printed
+func crashReport(exit *process.ExitStatus, tail []process.Line) string {
+	report := fmt.Sprintf("Minecraft exited with code %d", exit.Code)
+	if exit.Signal != "" {
+		report = fmt.Sprintf("Minecraft was killed by %s", exit.Signal)
+	}
+	if exit.Err != nil {
+		report += fmt.Sprintf(": %v", exit.Err)
+	}
+	if len(tail) > 10 {
+		tail = tail[len(tail)-10:]
+	}
+	if len(tail) > 0 {
+		report += "\n\nLast output:"
+		for _, line := range tail {
+			report += "\n" + line.Text
+		}
+	}
+	return report
+}
+
+// ensureDefaultInstance lists the instances, creating the Nix client instance
+// on first run. Installs from before instances existed keep their game
+// directory and installed pack.
+func ensureDefaultInstance(store *instance.Store, dataDir string) ([]*instance.Instance, error) {
+	instances, err := store.List()
+	if err != nil || len(instances) > 0 {
+		return instances, err
+	}
+
+	inst := instance.Instance{Name: "Nix client", MinecraftVersion: defaultVersionID, Loader: defaultLoader}
+	legacyDir := filepath.Join(dataDir, "minecraft")
+	if _, err := os.Stat(legacyDir); err == nil {
+		inst.GameDir = legacyDir
+		if installed, err := channel.LoadInstalled(legacyDir); err == nil && installed != nil {
+			inst.VersionID = installed.VersionID
+		}
+	}
+	created, err := store.Create(inst)
+	if err != nil {
+		return nil, err
+	}
+	return []*instance.Instance{created}, nil
+}
+
+// startGame installs anything the instance is missing, reporting download
+// progress through status, then launches it with the logged in account
+func startGame(account *storage.AccountData, store *instance.Store, inst *instance.Instance, status func(string)) (*launch.Game, error) {
+	dataDir, err := storage.GetConfigDir()
+	if err != nil {
+		return nil, err
+	}
+
+	status("Checking game files...")
+	events := make(chan download.Event)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		reportProgress(events, status)
+	}()
+
+	ctx := context.Background()
+	gameDir := store.GameDir(inst)
+	if inst.Loader == "fabric" && inst.VersionID == "" {
+		inst.VersionID, err = fabric.NewClient().Install(ctx, download.NewManager(), dataDir, inst.MinecraftVersion, inst.LoaderVersion, events)
+	}
+	if err == nil {
+		_, err = launch.Install(ctx, dataDir, inst.LaunchVersion(), gameDir, events)
+	}
+	close(events)
+	<-done
+	if err != nil {
+		return nil, err
+	}
+
+	status("Starting Minecraft...")
+	opts, err := launch.LoadInstalled(dataDir, inst.LaunchVersion(), gameDir)
+	if err != nil {
+		return nil, err
+	}
+	opts.ApplyInstance(inst)
+	game, err := launch.Prepare(account, *opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, warning := range game.Warnings {
+		fmt.Println("Warning:", warning)
+	}
+
+	game.Subscribe(func(ev process.Event) {
+		if ev.Line != nil {
+			fmt.Println(ev.Line.Text)
+			return
+		}
+		switch ev.State {
+		case process.Running:
+			status("Playing")
+		case process.Crashed:
+			status("Crashed")
+		}
+	})
+	if err := game.Start(); err != nil {
+		return nil, err
+	}
+	return game, nil
+}
+
+// checkNixClient asks the modpack channel whether the Nix client pack for the
+// instance's version needs installing or updating
+func checkNixClient(store *instance.Store, inst *instance.Instance) (channel.Status, *channel.Modpack, error) {
+	manifest, err := channel.New(filepath.Join(findResourceDir("read"), "version.json")).Fetch(context.Background())
+	if err != nil {
+		return channel.Unavailable, nil, err
+	}
+	return channel.Check(manifest, store.GameDir(inst), inst.MinecraftVersion, inst.Loader)
+}
+
+// installNixClient installs pack into the instance and sets its loader
+// profile as the one inst launches. inst is a copy owned by the caller's
+// goroutine, the GUI thread saves the result.
+func installNixClient(store *instance.Store, inst *instance.Instance, pack *channel.Modpack, status func(string)) error {
+	dataDir, err := storage.GetConfigDir()
+	if err != nil {
+		return err
+	}
+
+	status(fmt.Sprintf("Installing Nix client %s...", pack.Version))
+	events := make(chan download.Event)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		reportProgress(events, status)
+	}()
+
+	versionID, err := channel.Install(context.Background(), download.NewManager(), dataDir, store.GameDir(inst), pack, events)
+	close(events)
+	<-done
+	if err != nil {
+		return err
+	}
+	inst.VersionID = versionID
+	return nil
+}
+
+// reportProgress turns download events into status text until events is closed
+func reportProgress(events <-chan download.Event, status func(string)) {
+	var last time.Time
+	for ev := range events {
+		// Thousands of asset events would flood the Qt event loop
+		if time.Since(last) < 100*time.Millisecond && ev.FilesDone != ev.FilesTotal {
+			continue
+		}
+		last = time.Now()
+		status(fmt.Sprintf("Downloading files %d/%d", ev.FilesDone, ev.FilesTotal))
+	}
+}
+
+// runOnMainThread queues f on the Qt event loop, widgets must not be touched
+// from goroutines
+func runOnMainThread(f func()) {
+	timer := core.NewQTimer(nil)
+	timer.SetSingleShot(true)
+	timer.ConnectTimeout(f)
+	timer.Start(0)
+}
+
 func fileExists(filename string) bool {
 	info, err := os.Stat(filename)
 	if os.IsNotExist(err) {
@@ -7,21 +7,30 @@ import (
 
 	"Nix-Client-Launcher/internal/auth/microsoft"
 	"Nix-Client-Launcher/internal/auth/minecraft"
-	"Nix-Client-Launcher/internal/auth/xbox"
 	"Nix-Client-Launcher/internal/storage"
 )
 
+// deviceCodeLifetime is how long Microsoft keeps a device code valid
+const deviceCodeLifetime = 15 * time.Minute
+
 // DeviceLoginFlow encapsulates the state needed for the Device Code flow
 type DeviceLoginFlow struct {
 	DeviceCode string
 	UserCode   string
 	AuthURL    string
 	Interval   int
+
+	client *Client
+}
+
+// StartDeviceLogin initiates the flow with the real services
+func StartDeviceLogin(ctx context.Context) (*DeviceLoginFlow, error) {
+	return NewClient().StartDeviceLogin(ctx)
 }
 
 // StartDeviceLogin initiates the flow and returns the details to show the user
-func StartDeviceLogin() (*DeviceLoginFlow, error) {
-	resp, err := microsoft.StartDeviceFlow()
+func (c *Client) StartDeviceLogin(ctx context.Context) (*DeviceLoginFlow, error) {
+	resp, err := c.microsoft().StartDeviceFlow(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -30,43 +39,68 @@ func StartDeviceLogin() (*DeviceLoginFlow, error) {
 		UserCode:   resp.UserCode,
 		AuthURL:    resp.VerificationURI,
 		Interval:   resp.Interval,
+		client:     c,
 	}, nil
 }
 
-// WaitForLogin polls for the token and completes the chain
+// WaitForLogin polls for the token and completes the chain, giving up as soon
+// as ctx is cancelled
 func (f *DeviceLoginFlow) WaitForLogin(ctx context.Context) (*storage.AccountData, error) {
 	// 1. Poll for Microsoft Token
-	msToken, err := microsoft.PollForToken(f.DeviceCode, f.Interval)
+	msToken, err := f.client.pollForToken(ctx, f.DeviceCode, f.Interval)
 	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		return nil, fmt.Errorf("failed to get token: %v", err)
 	}
 
-	// 2. Xbox Live Auth
-	xboxResp, err := xbox.AuthenticateXboxLive(msToken.AccessToken)
-	if err != nil {
-		return nil, fmt.Errorf("xbox auth failed: %v", err)
-	}
+	return f.client.completeLogin(ctx, msToken)
+}
 
-	// 3. XSTS Auth
-	xstsResp, err := xbox.AuthenticateXSTS(xboxResp.Token)
-	if err != nil {
-		return nil, fmt.Errorf("xsts auth failed: %v", err)
+// pollForToken polls the token endpoint every interval seconds until the user
+// authenticates, the code expires or ctx is cancelled
+func (c *Client) pollForToken(ctx context.Context, deviceCode string, interval int) (*microsoft.TokenResponse, error) {
+	if interval <= 0 {
+		interval = 5
+	}
+	ms := c.microsoft()
+	deadline := c.Clock.Now().Add(deviceCodeLifetime)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-c.Clock.After(time.Duration(interval) * time.Second):
+		}
+		if c.Clock.Now().After(deadline) {
+			return nil, fmt.Errorf("authentication timed out")
+		}
+
+		token, errCode, err := ms.PollToken(ctx, deviceCode)
+		if err != nil {
+			return nil, err
+		}
+		if token != nil {
+			return token, nil
+		}
+		if errCode == "slow_down" {
+			interval += 5
+		}
 	}
+}
 
-	// Extract User Hash (uhs)
-	if len(xstsResp.DisplayClaims.Xui) == 0 {
-		return nil, fmt.Errorf("no user hash found in xsts response")
-	}
-	userHash := xstsResp.DisplayClaims.Xui[0].Uhs
-
-	// 4. Minecraft Auth
-	mcResp, err := minecraft.AuthenticateMinecraft(userHash, xstsResp.Token)
+// completeLogin turns a Microsoft token into a saved Minecraft account,
+// through Xbox Live and XSTS
+func (c *Client) completeLogin(ctx context.Context, msToken *microsoft.TokenResponse) (*storage.AccountData, error) {
+	mc, gamertag, err := c.minecraftLogin(ctx, msToken.AccessToken)
 	if err != nil {
-		return nil, fmt.Errorf("minecraft auth failed: %v", err)
+		return nil, err
 	}
+	mcClient := c.minecraft()
 
-	// 5. Check Ownership
-	ownsGame, err := minecraft.CheckOwnership(mcResp.AccessToken)
+	// 4. Check Ownership
+	ownsGame, err := mcClient.CheckOwnership(ctx, mc.AccessToken)
 	if err != nil {
 		return nil, fmt.Errorf("ownership check failed: %v", err)
 	}
@@ -74,28 +108,32 @@ func (f *DeviceLoginFlow) WaitForLogin(ctx context.Context) (*storage.AccountDat
 		return nil, fmt.Errorf("user does not own Minecraft Java Edition")
 	}
 
-	// 6. Get Profile
-	profile, err := minecraft.GetProfile(mcResp.AccessToken)
+	// 5. Get Profile
+	profile, err := mcClient.GetProfile(ctx, mc.AccessToken)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get profile: %v", err)
 	}
 
-	// 7. Prepare Account Data
+	// 6. Prepare Account Data
+	now := c.Clock.Now()
 	account := storage.AccountData{
 		Tokens: storage.AuthTokens{
 			MicrosoftAccessToken:  msToken.AccessToken,
 			MicrosoftRefreshToken: msToken.RefreshToken,
-			MicrosoftExpiry:       time.Now().Add(time.Duration(msToken.ExpiresIn) * time.Second),
-			MinecraftAccessToken:  mcResp.AccessToken,
-			MinecraftExpiry:       time.Now().Add(time.Duration(mcResp.ExpiresIn) * time.Second),
+			MicrosoftExpiry:       now.Add(time.Duration(msToken.ExpiresIn) * time.Second),
+			MinecraftAccessToken:  mc.AccessToken,
+			MinecraftExpiry:       now.Add(time.Duration(mc.ExpiresIn) * time.Second),
 		},
 		Profile: storage.MinecraftProfile{
-			ID:   profile.ID,
-			Name: profile.Name,
+			ID:       profile.ID,
+			Name:     profile.Name,
+			Gamertag: gamertag,
+			XUID:     minecraft.XUIDFromToken(mc.AccessToken),
+			Skin:     profile.ActiveSkin(),
 		},
 	}
 
-	// 8. Save Account
+	// 7. Save Account
 	if err := storage.SaveAccount(account); err != nil {
 		return nil, fmt.Errorf("failed to save account: %v", err)
 	}
@@ -103,40 +141,79 @@ func (f *DeviceLoginFlow) WaitForLogin(ctx context.Context) (*storage.AccountDat
 	return &account, nil
 }
 
-// RefreshLogin handles token refreshing
-func RefreshLogin(account *storage.AccountData) (*storage.AccountData, error) {
-	// Refresh Microsoft Token
-	msToken, err := microsoft.RefreshToken(account.Tokens.MicrosoftRefreshToken)
+// minecraftLogin runs the Xbox Live, XSTS and Minecraft steps shared by a new
+// login and a refresh. The gamertag is "" when it can't be looked up, it is
+// only shown in the account picker.
+func (c *Client) minecraftLogin(ctx context.Context, msAccessToken string) (*minecraft.MinecraftAuthResponse, string, error) {
+	xboxClient := c.xbox()
+
+	// 1. Xbox Live Auth
+	xboxResp, err := xboxClient.AuthenticateXboxLive(ctx, msAccessToken)
 	if err != nil {
-		return nil, err
+		return nil, "", fmt.Errorf("xbox auth failed: %v", err)
 	}
 
-	// Re-authenticate Xbox/Minecraft flow
-	xboxResp, err := xbox.AuthenticateXboxLive(msToken.AccessToken)
+	// 2. XSTS Auth
+	xstsResp, err := xboxClient.AuthenticateXSTS(ctx, xboxResp.Token)
 	if err != nil {
-		return nil, err
+		return nil, "", fmt.Errorf("xsts auth failed: %v", err)
 	}
 
-	xstsResp, err := xbox.AuthenticateXSTS(xboxResp.Token)
+	// Extract User Hash (uhs)
+	if len(xstsResp.DisplayClaims.Xui) == 0 {
+		return nil, "", fmt.Errorf("no user hash found in xsts response")
+	}
+	userHash := xstsResp.DisplayClaims.Xui[0].Uhs
+
+	// 3. Minecraft Auth
+	mcResp, err := c.minecraft().AuthenticateMinecraft(ctx, userHash, xstsResp.Token)
 	if err != nil {
-		return nil, err
+		return nil, "", fmt.Errorf("minecraft auth failed: %v", err)
 	}
 
-	userHash := xstsResp.DisplayClaims.Xui[0].Uhs
+	// 4. Gamertag, a failure here shouldn't stop the login
+	gamertag, _ := xboxClient.Gamertag(ctx, xboxResp.Token)
+	return mcResp, gamertag, nil
+}
+
+// RefreshLogin refreshes account's tokens with the real services
+func RefreshLogin(ctx context.Context, account *storage.AccountData) (*storage.AccountData, error) {
+	return NewClient().RefreshLogin(ctx, account)
+}
 
-	mcResp, err := minecraft.AuthenticateMinecraft(userHash, xstsResp.Token)
+// RefreshLogin handles token refreshing
+func (c *Client) RefreshLogin(ctx context.Context, account *storage.AccountData) (*storage.AccountData, error) {
+	// Refresh Microsoft Token
+	msToken, err := c.microsoft().RefreshToken(ctx, account.Tokens.MicrosoftRefreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// Re-authenticate Xbox/Minecraft flow
+	mcResp, gamertag, err := c.minecraftLogin(ctx, msToken.AccessToken)
 	if err != nil {
 		return nil, err
 	}
 
 	// Update Account Data
+	now := c.Clock.Now()
 	account.Tokens.MicrosoftAccessToken = msToken.AccessToken
 	account.Tokens.MicrosoftRefreshToken = msToken.RefreshToken
-	account.Tokens.MicrosoftExpiry = time.Now().Add(time.Duration(msToken.ExpiresIn) * time.Second)
+	account.Tokens.MicrosoftExpiry = now.Add(time.Duration(msToken.ExpiresIn) * time.Second)
 	account.Tokens.MinecraftAccessToken = mcResp.AccessToken
-	account.Tokens.MinecraftExpiry = time.Now().Add(time.Duration(mcResp.ExpiresIn) * time.Second)
+	account.Tokens.MinecraftExpiry = now.Add(time.Duration(mcResp.ExpiresIn) * time.Second)
+	account.Profile.XUID = minecraft.XUIDFromToken(mcResp.AccessToken)
+	if gamertag != "" {
+		account.Profile.Gamertag = gamertag
+	}
+
+	// Pick up name and skin changes, the old ones will do if this fails
+	if profile, err := c.minecraft().GetProfile(ctx, mcResp.AccessToken); err == nil {
+		account.Profile.Name = profile.Name
+		account.Profile.Skin = profile.ActiveSkin()
+	}
 
-	if err := storage.SaveAccount(*account); err != nil {
+	if err := storage.UpdateAccount(*account); err != nil {
 		return nil, err
 	}
 
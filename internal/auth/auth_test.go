@@ -0,0 +1,269 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"Nix-Client-Launcher/internal/auth"
+	"Nix-Client-Launcher/internal/auth/authtest"
+	"Nix-Client-Launcher/internal/storage"
+)
+
+// startServer starts a fake services server and points the account store at
+// a temporary config dir
+func startServer(t *testing.T, configure func(s *authtest.Server)) *authtest.Server {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	s := authtest.NewServer()
+	s.UserAgent = "Nix-Client-Launcher/test"
+	if configure != nil {
+		configure(s)
+	}
+	s.Start()
+	t.Cleanup(s.Close)
+	return s
+}
+
+func deviceLogin(t *testing.T, client *auth.Client) *storage.AccountData {
+	t.Helper()
+	flow, err := client.StartDeviceLogin(context.Background())
+	if err != nil {
+		t.Fatalf("StartDeviceLogin: %v", err)
+	}
+	account, err := flow.WaitForLogin(context.Background())
+	if err != nil {
+		t.Fatalf("WaitForLogin: %v", err)
+	}
+	return account
+}
+
+// checkAccount verifies account against what the fake hands out and that it
+// is the active account on disk
+func checkAccount(t *testing.T, s *authtest.Server, client *auth.Client, account *storage.AccountData) {
+	t.Helper()
+	if account.Profile.ID != s.ProfileID || account.Profile.Name != s.ProfileName() {
+		t.Errorf("profile = %s/%s, want %s/%s", account.Profile.ID, account.Profile.Name, s.ProfileID, s.ProfileName())
+	}
+	if account.Profile.Gamertag != s.Gamertag {
+		t.Errorf("Gamertag = %q, want %q", account.Profile.Gamertag, s.Gamertag)
+	}
+	if account.Profile.XUID != s.XUID {
+		t.Errorf("XUID = %q, want %q", account.Profile.XUID, s.XUID)
+	}
+	if account.Profile.Skin != s.SkinURL {
+		t.Errorf("Skin = %q, want %q", account.Profile.Skin, s.SkinURL)
+	}
+	for name, token := range map[string]string{
+		"microsoft access":  account.Tokens.MicrosoftAccessToken,
+		"microsoft refresh": account.Tokens.MicrosoftRefreshToken,
+		"minecraft access":  account.Tokens.MinecraftAccessToken,
+	} {
+		if !s.IsValid(token) {
+			t.Errorf("%s token %q was not issued by the server", name, token)
+		}
+	}
+
+	now := client.Clock.Now()
+	if want := now.Add(time.Duration(s.TokenLifetime) * time.Second); !account.Tokens.MicrosoftExpiry.Equal(want) {
+		t.Errorf("MicrosoftExpiry = %v, want %v", account.Tokens.MicrosoftExpiry, want)
+	}
+	if want := now.Add(time.Duration(s.MinecraftLifetime) * time.Second); !account.Tokens.MinecraftExpiry.Equal(want) {
+		t.Errorf("MinecraftExpiry = %v, want %v", account.Tokens.MinecraftExpiry, want)
+	}
+
+	saved, err := storage.LoadAccount()
+	if err != nil {
+		t.Fatalf("LoadAccount: %v", err)
+	}
+	if saved.Profile.ID != account.Profile.ID || saved.Tokens.MinecraftAccessToken != account.Tokens.MinecraftAccessToken {
+		t.Errorf("saved account = %+v, want %+v", saved, account)
+	}
+}
+
+func TestDeviceLogin(t *testing.T) {
+	s := startServer(t, func(s *authtest.Server) { s.PendingPolls = 2 })
+	client := s.AuthClient()
+	start := client.Clock.Now()
+
+	account := deviceLogin(t, client)
+	checkAccount(t, s, client, account)
+
+	if polls := s.Polls(); polls != 3 {
+		t.Errorf("polled %d times, want 3", polls)
+	}
+	if waited := client.Clock.Now().Sub(start); waited != 15*time.Second {
+		t.Errorf("waited %v between polls, want 15s", waited)
+	}
+}
+
+func TestDeviceLoginTimesOut(t *testing.T) {
+	s := startServer(t, func(s *authtest.Server) { s.PendingPolls = -1 })
+
+	flow, err := s.AuthClient().StartDeviceLogin(context.Background())
+	if err != nil {
+		t.Fatalf("StartDeviceLogin: %v", err)
+	}
+	_, err = flow.WaitForLogin(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("WaitForLogin = %v, want a timeout", err)
+	}
+	if _, err := storage.LoadAccount(); err == nil {
+		t.Error("an account was saved after a failed login")
+	}
+}
+
+func TestDeviceLoginCancelled(t *testing.T) {
+	s := startServer(t, func(s *authtest.Server) { s.PendingPolls = -1 })
+	client := s.AuthClient()
+	// A real clock, so the poll loop is parked waiting when the cancel comes
+	client.Clock = auth.SystemClock
+
+	flow, err := client.StartDeviceLogin(context.Background())
+	if err != nil {
+		t.Fatalf("StartDeviceLogin: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := flow.WaitForLogin(ctx)
+		done <- err
+	}()
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("WaitForLogin = %v, want context.Canceled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("WaitForLogin kept polling after the context was cancelled")
+	}
+}
+
+func TestDeviceLoginWithoutGame(t *testing.T) {
+	s := startServer(t, func(s *authtest.Server) { s.OwnsGame = false })
+
+	flow, err := s.AuthClient().StartDeviceLogin(context.Background())
+	if err != nil {
+		t.Fatalf("StartDeviceLogin: %v", err)
+	}
+	_, err = flow.WaitForLogin(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "does not own") {
+		t.Fatalf("WaitForLogin = %v, want an ownership error", err)
+	}
+	if _, err := storage.LoadAccount(); err == nil {
+		t.Error("an account was saved after a failed login")
+	}
+}
+
+func TestBrowserLogin(t *testing.T) {
+	s := startServer(t, nil)
+	client := s.AuthClient()
+
+	flow, err := client.StartBrowserLogin()
+	if err != nil {
+		t.Fatalf("StartBrowserLogin: %v", err)
+	}
+
+	// Play the browser: the authorize page redirects back to the launcher
+	resp, err := http.Get(flow.AuthURL)
+	if err != nil {
+		t.Fatalf("opening %s: %v", flow.AuthURL, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Request.URL.String(), "http://127.0.0.1:") {
+		t.Fatalf("browser ended on %s with %s, want the loopback page", resp.Request.URL, resp.Status)
+	}
+
+	account, err := flow.WaitForLogin(context.Background())
+	if err != nil {
+		t.Fatalf("WaitForLogin: %v", err)
+	}
+	checkAccount(t, s, client, account)
+}
+
+func TestRefreshLogin(t *testing.T) {
+	s := startServer(t, nil)
+	client := s.AuthClient()
+	account := deviceLogin(t, client)
+	old := account.Tokens
+
+	client.Clock.(*authtest.Clock).Advance(48 * time.Hour)
+	s.SetProfileName("Jeb")
+
+	refreshed, err := client.RefreshLogin(context.Background(), account)
+	if err != nil {
+		t.Fatalf("RefreshLogin: %v", err)
+	}
+	checkAccount(t, s, client, refreshed)
+
+	if refreshed.Tokens.MicrosoftRefreshToken == old.MicrosoftRefreshToken ||
+		refreshed.Tokens.MinecraftAccessToken == old.MinecraftAccessToken {
+		t.Error("RefreshLogin kept the old tokens")
+	}
+	if s.IsValid(old.MicrosoftRefreshToken) {
+		t.Error("the old refresh token was not used")
+	}
+
+	accounts, err := storage.LoadAccounts()
+	if err != nil {
+		t.Fatalf("LoadAccounts: %v", err)
+	}
+	if n := len(accounts.List()); n != 1 {
+		t.Errorf("%d accounts saved after a refresh, want 1", n)
+	}
+}
+
+func TestRefreshLoginRevoked(t *testing.T) {
+	s := startServer(t, nil)
+	client := s.AuthClient()
+	account := deviceLogin(t, client)
+	saved := *account
+
+	// Refresh tokens are single use, the second refresh with the same one fails
+	stale := *account
+	if _, err := client.RefreshLogin(context.Background(), account); err != nil {
+		t.Fatalf("RefreshLogin: %v", err)
+	}
+	if _, err := client.RefreshLogin(context.Background(), &stale); err == nil {
+		t.Fatal("RefreshLogin accepted a used refresh token")
+	}
+	if stale.Tokens != saved.Tokens {
+		t.Error("a failed refresh changed the account")
+	}
+
+	current, err := storage.LoadAccount()
+	if err != nil {
+		t.Fatalf("LoadAccount: %v", err)
+	}
+	if current.Tokens.MicrosoftRefreshToken != account.Tokens.MicrosoftRefreshToken {
+		t.Error("a failed refresh overwrote the saved account")
+	}
+}
+
+func TestLoginWithoutGamertag(t *testing.T) {
+	s := startServer(t, func(s *authtest.Server) { s.Gamertag = "" })
+	client := s.AuthClient()
+
+	// The gamertag is only for display, the login goes ahead without it
+	account := deviceLogin(t, client)
+	if account.Profile.Gamertag != "" || account.Profile.DisplayName() != s.ProfileName() {
+		t.Errorf("Gamertag = %q, DisplayName = %q, want the player name", account.Profile.Gamertag, account.Profile.DisplayName())
+	}
+
+	// A refresh that can't look it up keeps the one already known
+	account.Profile.Gamertag = "Stored"
+	refreshed, err := client.RefreshLogin(context.Background(), account)
+	if err != nil {
+		t.Fatalf("RefreshLogin: %v", err)
+	}
+	if refreshed.Profile.Gamertag != "Stored" {
+		t.Errorf("Gamertag = %q after a refresh, want the stored one", refreshed.Profile.Gamertag)
+	}
+}
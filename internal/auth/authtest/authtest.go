@@ -0,0 +1,480 @@
+// Package authtest is a fake of the Microsoft, Xbox Live and Minecraft
+// services for running the login chain without touching the real ones
+package authtest
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"Nix-Client-Launcher/internal/auth"
+	"Nix-Client-Launcher/internal/auth/microsoft"
+	"Nix-Client-Launcher/internal/auth/xbox"
+)
+
+// Paths the fake serves, mirroring the real services
+const (
+	DeviceCodePath            = "/consumers/oauth2/v2.0/devicecode"
+	TokenPath                 = "/consumers/oauth2/v2.0/token"
+	AuthorizePath             = "/consumers/oauth2/v2.0/authorize"
+	XboxLivePath              = "/user/authenticate"
+	XSTSPath                  = "/xsts/authorize"
+	MinecraftAuthPath         = "/authentication/login_with_xbox"
+	MinecraftProfilePath      = "/minecraft/profile"
+	MinecraftEntitlementsPath = "/entitlements/mcstore"
+)
+
+// Server fakes every service in the login chain. Each step only accepts the
+// tokens the previous step handed out, so a chain that passes the wrong
+// token along fails the same way it would against the real services.
+//
+// Set the exported fields before Start; use SetProfileName to change the
+// player while the server is running.
+type Server struct {
+	*httptest.Server
+
+	ProfileID string
+	XUID      string
+	SkinURL   string
+	OwnsGame  bool
+	UserHash  string
+	Gamertag  string
+
+	// PendingPolls is how many device code polls are answered with
+	// "authorization_pending" before the user counts as logged in, -1 never
+	// logs in
+	PendingPolls int
+
+	// UserAgent, if set, is required on every request
+	UserAgent string
+
+	// TokenLifetime and MinecraftLifetime are the expires_in handed out
+	TokenLifetime     int
+	MinecraftLifetime int
+
+	mu          sync.Mutex
+	profileName string
+	serial      int
+	polls       int
+	deviceCode  string
+	codes       map[string]authCode
+	tokens      map[string]string // token -> kind
+}
+
+type authCode struct {
+	challenge   string
+	redirectURI string
+}
+
+// Token kinds tracked by the server
+const (
+	kindMicrosoft = "microsoft"
+	kindRefresh   = "refresh"
+	kindXbox      = "xbox"
+	kindXSTS      = "xsts"
+	kindXboxLive  = "xboxlive" // XSTS token for Xbox Live itself
+	kindMinecraft = "minecraft"
+)
+
+// NewServer returns an unstarted fake with a player that owns the game and
+// logs in on the first poll
+func NewServer() *Server {
+	s := &Server{
+		ProfileID:         "069a79f444e94726a5befca90e38aaf5",
+		XUID:              "2535405290120195",
+		SkinURL:           "http://textures.minecraft.net/texture/1a4af718455d4aab528e7a61f86fa25e6a369d1768dcb13f7df319a713eb810b",
+		OwnsGame:          true,
+		UserHash:          "1234567890123456789",
+		Gamertag:          "NotchXbox",
+		TokenLifetime:     3600,
+		MinecraftLifetime: 86400,
+		profileName:       "Notch",
+		codes:             make(map[string]authCode),
+		tokens:            make(map[string]string),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(DeviceCodePath, s.handleDeviceCode)
+	mux.HandleFunc(TokenPath, s.handleToken)
+	mux.HandleFunc(AuthorizePath, s.handleAuthorize)
+	mux.HandleFunc(XboxLivePath, s.handleXboxLive)
+	mux.HandleFunc(XSTSPath, s.handleXSTS)
+	mux.HandleFunc(MinecraftAuthPath, s.handleMinecraftAuth)
+	mux.HandleFunc(MinecraftProfilePath, s.handleProfile)
+	mux.HandleFunc(MinecraftEntitlementsPath, s.handleEntitlements)
+	s.Server = httptest.NewUnstartedServer(s.checkUserAgent(mux))
+	return s
+}
+
+// Endpoints points the login chain at the fake
+func (s *Server) Endpoints() auth.Endpoints {
+	return auth.Endpoints{
+		DeviceCode:            s.URL + DeviceCodePath,
+		Token:                 s.URL + TokenPath,
+		Authorize:             s.URL + AuthorizePath,
+		XboxLive:              s.URL + XboxLivePath,
+		XSTS:                  s.URL + XSTSPath,
+		MinecraftAuth:         s.URL + MinecraftAuthPath,
+		MinecraftProfile:      s.URL + MinecraftProfilePath,
+		MinecraftEntitlements: s.URL + MinecraftEntitlementsPath,
+	}
+}
+
+// AuthClient returns an auth.Client that talks to the fake and runs on a
+// fake clock, so device code polling doesn't wait
+func (s *Server) AuthClient() *auth.Client {
+	userAgent := s.UserAgent
+	if userAgent == "" {
+		userAgent = "authtest"
+	}
+	return &auth.Client{
+		Endpoints:  s.Endpoints(),
+		HTTPClient: s.Client(),
+		UserAgent:  userAgent,
+		Clock:      NewClock(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)),
+	}
+}
+
+// ProfileName is the name the profile endpoint currently reports
+func (s *Server) ProfileName() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.profileName
+}
+
+// SetProfileName renames the player, like a name change on minecraft.net
+func (s *Server) SetProfileName(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.profileName = name
+}
+
+// Polls is how many times the device code token endpoint has been polled
+func (s *Server) Polls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.polls
+}
+
+// IsValid reports whether token was issued by the server and not yet used
+// up, refresh tokens are single use
+func (s *Server) IsValid(token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.tokens[token]
+	return ok
+}
+
+func (s *Server) checkUserAgent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The authorize page is opened by the user's browser, not the launcher
+		if s.UserAgent != "" && r.URL.Path != AuthorizePath && r.UserAgent() != s.UserAgent {
+			http.Error(w, fmt.Sprintf("unexpected user agent %q", r.UserAgent()), http.StatusBadRequest)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// issue hands out a new token of kind, the caller holds s.mu
+func (s *Server) issue(kind string) string {
+	s.serial++
+	token := fmt.Sprintf("%s-token-%d", kind, s.serial)
+	s.tokens[token] = kind
+	return token
+}
+
+// take checks that token is a live token of kind, using it up if once is set
+func (s *Server) take(token, kind string, once bool) bool {
+	if s.tokens[token] != kind {
+		return false
+	}
+	if once {
+		delete(s.tokens, token)
+	}
+	return true
+}
+
+func (s *Server) handleDeviceCode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.PostFormValue("client_id") != microsoft.ClientID {
+		oauthError(w, "invalid_request")
+		return
+	}
+	s.mu.Lock()
+	s.serial++
+	s.deviceCode = fmt.Sprintf("device-code-%d", s.serial)
+	s.polls = 0
+	resp := microsoft.DeviceCodeResponse{
+		UserCode:        "ABCD1234",
+		DeviceCode:      s.deviceCode,
+		VerificationURI: s.URL + "/link",
+		ExpiresIn:       900,
+		Interval:        5,
+	}
+	s.mu.Unlock()
+	writeJSON(w, resp)
+}
+
+func (s *Server) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirectURI := q.Get("redirect_uri")
+	if q.Get("client_id") != microsoft.ClientID || q.Get("response_type") != "code" ||
+		q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" ||
+		!strings.HasPrefix(redirectURI, "http://127.0.0.1:") {
+		http.Error(w, "bad authorize request", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.serial++
+	code := fmt.Sprintf("auth-code-%d", s.serial)
+	s.codes[code] = authCode{challenge: q.Get("code_challenge"), redirectURI: redirectURI}
+	s.mu.Unlock()
+
+	back := url.Values{}
+	back.Set("code", code)
+	back.Set("state", q.Get("state"))
+	http.Redirect(w, r, redirectURI+"?"+back.Encode(), http.StatusFound)
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.PostFormValue("client_id") != microsoft.ClientID {
+		oauthError(w, "invalid_request")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.PostFormValue("grant_type") {
+	case "urn:ietf:params:oauth:grant-type:device_code":
+		if s.deviceCode == "" || r.PostFormValue("device_code") != s.deviceCode {
+			oauthError(w, "expired_token")
+			return
+		}
+		s.polls++
+		if s.PendingPolls < 0 || s.polls <= s.PendingPolls {
+			oauthError(w, "authorization_pending")
+			return
+		}
+		s.deviceCode = ""
+
+	case "authorization_code":
+		code, ok := s.codes[r.PostFormValue("code")]
+		delete(s.codes, r.PostFormValue("code"))
+		if !ok || code.redirectURI != r.PostFormValue("redirect_uri") {
+			oauthError(w, "invalid_grant")
+			return
+		}
+		sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(sum[:]) != code.challenge {
+			oauthError(w, "invalid_grant")
+			return
+		}
+
+	case "refresh_token":
+		if !s.take(r.PostFormValue("refresh_token"), kindRefresh, true) {
+			oauthError(w, "invalid_grant")
+			return
+		}
+
+	default:
+		oauthError(w, "unsupported_grant_type")
+		return
+	}
+
+	writeJSON(w, microsoft.TokenResponse{
+		AccessToken:  s.issue(kindMicrosoft),
+		RefreshToken: s.issue(kindRefresh),
+		ExpiresIn:    s.TokenLifetime,
+		Scope:        microsoft.Scope,
+		TokenType:    "Bearer",
+	})
+}
+
+// xboxRequest covers both the Xbox Live and XSTS request bodies
+type xboxRequest struct {
+	Properties struct {
+		RpsTicket  string   `json:"RpsTicket"`
+		UserTokens []string `json:"UserTokens"`
+	} `json:"Properties"`
+	RelyingParty string `json:"RelyingParty"`
+}
+
+func (s *Server) handleXboxLive(w http.ResponseWriter, r *http.Request) {
+	var req xboxRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.take(strings.TrimPrefix(req.Properties.RpsTicket, "d="), kindMicrosoft, false) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	s.writeXboxToken(w, kindXbox, "")
+}
+
+func (s *Server) handleXSTS(w http.ResponseWriter, r *http.Request) {
+	var req xboxRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(req.Properties.UserTokens) != 1 || !s.take(req.Properties.UserTokens[0], kindXbox, false) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch req.RelyingParty {
+	case xbox.MinecraftRelyingParty:
+		s.writeXboxToken(w, kindXSTS, "")
+	case xbox.XboxLiveRelyingParty:
+		// Only the Xbox Live relying party hands out the gamertag
+		s.writeXboxToken(w, kindXboxLive, s.Gamertag)
+	default:
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+}
+
+// writeXboxToken answers with a new token of kind, the caller holds s.mu
+func (s *Server) writeXboxToken(w http.ResponseWriter, kind, gamertag string) {
+	var resp xbox.XboxAuthResponse
+	resp.Token = s.issue(kind)
+	resp.DisplayClaims.Xui = append(resp.DisplayClaims.Xui, struct {
+		Uhs string `json:"uhs"`
+		Gtg string `json:"gtg,omitempty"`
+	}{s.UserHash, gamertag})
+	writeJSON(w, resp)
+}
+
+func (s *Server) handleMinecraftAuth(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		IdentityToken string `json:"identityToken"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prefix := "XBL3.0 x=" + s.UserHash + ";"
+	if !strings.HasPrefix(req.IdentityToken, prefix) ||
+		!s.take(strings.TrimPrefix(req.IdentityToken, prefix), kindXSTS, false) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	// Real Minecraft tokens are JWTs carrying the xuid claim
+	s.serial++
+	claims, _ := json.Marshal(map[string]interface{}{"xuid": s.XUID, "jti": s.serial})
+	token := "eyJhbGciOiJIUzI1NiJ9." + base64.RawURLEncoding.EncodeToString(claims) + ".signature"
+	s.tokens[token] = kindMinecraft
+
+	writeJSON(w, map[string]interface{}{
+		"username":     s.ProfileID,
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   s.MinecraftLifetime,
+	})
+}
+
+// authorized checks the bearer token of a Minecraft services request, the
+// caller holds s.mu
+func (s *Server) authorized(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return s.take(token, kindMinecraft, false)
+}
+
+func (s *Server) handleEntitlements(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.authorized(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	items := []map[string]string{}
+	if s.OwnsGame {
+		items = append(items, map[string]string{"name": "product_minecraft"}, map[string]string{"name": "game_minecraft"})
+	}
+	writeJSON(w, map[string]interface{}{"items": items})
+}
+
+func (s *Server) handleProfile(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.authorized(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if !s.OwnsGame {
+		http.Error(w, `{"error":"NOT_FOUND"}`, http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"id":   s.ProfileID,
+		"name": s.profileName,
+		"skins": []map[string]string{
+			{"id": "skin-1", "state": "ACTIVE", "url": s.SkinURL, "variant": "CLASSIC"},
+		},
+	})
+}
+
+func oauthError(w http.ResponseWriter, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// Clock is a fake auth.Clock: After fires straight away and moves the time
+// forward by the duration waited
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewClock returns a Clock starting at start
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+// Now returns the fake time
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After advances the clock by d and returns a channel that already fired
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	ch <- c.Advance(d)
+	return ch
+}
+
+// Advance moves the clock forward by d and returns the new time
+func (c *Clock) Advance(d time.Duration) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	return c.now
+}
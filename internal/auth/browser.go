@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"Nix-Client-Launcher/internal/auth/pkce"
+	"Nix-Client-Launcher/internal/storage"
+)
+
+const loginDonePage = `<!DOCTYPE html>
+<html><head><title>Nix Client Launcher</title></head>
+<body style="font-family: sans-serif; text-align: center; margin-top: 4em">
+<h2>%s</h2><p>You can close this tab and return to the launcher.</p>
+</body></html>`
+
+// BrowserLoginFlow is the authorization code + PKCE login: the user signs in
+// in their own browser, which redirects back to a short-lived server on
+// 127.0.0.1
+type BrowserLoginFlow struct {
+	AuthURL string
+
+	redirectURI string
+	verifier    string
+	state       string
+	server      *http.Server
+	result      chan codeResult
+	client      *Client
+}
+
+type codeResult struct {
+	code string
+	err  error
+}
+
+// StartBrowserLogin starts a browser login against the real services
+func StartBrowserLogin() (*BrowserLoginFlow, error) {
+	return NewClient().StartBrowserLogin()
+}
+
+// StartBrowserLogin starts the loopback server and returns the URL to open
+func (c *Client) StartBrowserLogin() (*BrowserLoginFlow, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to start login server: %v", err)
+	}
+
+	verifier, err := pkce.GenerateVerifier()
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	state, err := pkce.GenerateState()
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	f := &BrowserLoginFlow{
+		redirectURI: fmt.Sprintf("http://127.0.0.1:%d", listener.Addr().(*net.TCPAddr).Port),
+		verifier:    verifier,
+		state:       state,
+		result:      make(chan codeResult, 1),
+		client:      c,
+	}
+	f.AuthURL = c.microsoft().LoginURL(f.redirectURI, pkce.GenerateChallenge(f.verifier), f.state)
+	f.server = &http.Server{Handler: http.HandlerFunc(f.handleRedirect), ReadHeaderTimeout: 10 * time.Second}
+
+	go f.server.Serve(listener)
+	return f, nil
+}
+
+func (f *BrowserLoginFlow) handleRedirect(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	query := r.URL.Query()
+
+	// Anything without our state didn't come from the login we started
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(f.state)) != 1 {
+		http.Error(w, "Invalid login state", http.StatusBadRequest)
+		return
+	}
+
+	var res codeResult
+	if errCode := query.Get("error"); errCode != "" {
+		res.err = fmt.Errorf("microsoft login failed: %s - %s", errCode, query.Get("error_description"))
+	} else if res.code = query.Get("code"); res.code == "" {
+		res.err = fmt.Errorf("microsoft login returned no code")
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if res.err != nil {
+		fmt.Fprintf(w, loginDonePage, "Login failed")
+	} else {
+		fmt.Fprintf(w, loginDonePage, "Login complete")
+	}
+
+	select {
+	case f.result <- res:
+	default:
+		// A second redirect, the first one already decided the outcome
+	}
+}
+
+// WaitForLogin waits for the browser to come back, exchanges the code and
+// completes the chain. The loopback server is shut down when it returns.
+func (f *BrowserLoginFlow) WaitForLogin(ctx context.Context) (*storage.AccountData, error) {
+	defer f.Close()
+
+	var res codeResult
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res = <-f.result:
+	}
+	if res.err != nil {
+		return nil, res.err
+	}
+
+	msToken, err := f.client.microsoft().ExchangeCode(ctx, res.code, f.verifier, f.redirectURI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token: %v", err)
+	}
+	return f.client.completeLogin(ctx, msToken)
+}
+
+// Close stops the loopback server, for when the user gives up on the login
+func (f *BrowserLoginFlow) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return f.server.Shutdown(ctx)
+}
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"net/http"
+	"time"
+
+	"Nix-Client-Launcher/internal/auth/microsoft"
+	"Nix-Client-Launcher/internal/auth/minecraft"
+	"Nix-Client-Launcher/internal/auth/xbox"
+	"Nix-Client-Launcher/internal/download"
+)
+
+// Endpoints is every service URL the login chain talks to
+type Endpoints struct {
+	DeviceCode            string
+	Token                 string
+	Authorize             string
+	XboxLive              string
+	XSTS                  string
+	MinecraftAuth         string
+	MinecraftProfile      string
+	MinecraftEntitlements string
+}
+
+// DefaultEndpoints returns the real Microsoft, Xbox Live and Minecraft services
+func DefaultEndpoints() Endpoints {
+	return Endpoints{
+		DeviceCode:            microsoft.DeviceCodeEndpoint,
+		Token:                 microsoft.TokenEndpoint,
+		Authorize:             microsoft.AuthorizeEndpoint,
+		XboxLive:              xbox.XboxLiveAuthURL,
+		XSTS:                  xbox.XSTSAuthURL,
+		MinecraftAuth:         minecraft.MinecraftAuthURL,
+		MinecraftProfile:      minecraft.MinecraftProfileURL,
+		MinecraftEntitlements: minecraft.MinecraftEntitlementsURL,
+	}
+}
+
+// Clock is where the login chain gets the time from, for token expiry and
+// for waiting between device code polls
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// SystemClock is the real wall clock
+var SystemClock Clock = systemClock{}
+
+// Client runs the Microsoft → Xbox Live → XSTS → Minecraft login chain
+// against a configurable set of endpoints
+type Client struct {
+	Endpoints  Endpoints
+	HTTPClient *http.Client
+	UserAgent  string
+	Clock      Clock
+}
+
+// NewClient returns a Client for the real services
+func NewClient() *Client {
+	return &Client{
+		Endpoints:  DefaultEndpoints(),
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+		UserAgent:  download.UserAgent,
+		Clock:      SystemClock,
+	}
+}
+
+func (c *Client) microsoft() *microsoft.Client {
+	return &microsoft.Client{
+		ClientID:      microsoft.ClientID,
+		DeviceCodeURL: c.Endpoints.DeviceCode,
+		TokenURL:      c.Endpoints.Token,
+		AuthorizeURL:  c.Endpoints.Authorize,
+		HTTPClient:    c.HTTPClient,
+		UserAgent:     c.UserAgent,
+	}
+}
+
+func (c *Client) xbox() *xbox.Client {
+	return &xbox.Client{
+		UserAuthURL: c.Endpoints.XboxLive,
+		XSTSAuthURL: c.Endpoints.XSTS,
+		HTTPClient:  c.HTTPClient,
+		UserAgent:   c.UserAgent,
+	}
+}
+
+func (c *Client) minecraft() *minecraft.Client {
+	return &minecraft.Client{
+		AuthURL:         c.Endpoints.MinecraftAuth,
+		ProfileURL:      c.Endpoints.MinecraftProfile,
+		EntitlementsURL: c.Endpoints.MinecraftEntitlements,
+		HTTPClient:      c.HTTPClient,
+		UserAgent:       c.UserAgent,
+	}
+}
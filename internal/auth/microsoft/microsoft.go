@@ -1,6 +1,7 @@
 package microsoft
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -17,6 +18,7 @@ const (
 	// Correct v2.0 endpoints for Personal Accounts (consumers)
 	DeviceCodeEndpoint = "https://login.microsoftonline.com/consumers/oauth2/v2.0/devicecode"
 	TokenEndpoint      = "https://login.microsoftonline.com/consumers/oauth2/v2.0/token"
+	AuthorizeEndpoint  = "https://login.microsoftonline.com/consumers/oauth2/v2.0/authorize"
 )
 
 type DeviceCodeResponse struct {
@@ -37,18 +39,48 @@ type TokenResponse struct {
 	UserID       string `json:"user_id"`
 }
 
+// Client talks to the Microsoft identity platform. The zero value is not
+// usable, use NewClient or fill in every field.
+type Client struct {
+	ClientID      string
+	DeviceCodeURL string
+	TokenURL      string
+	AuthorizeURL  string
+	HTTPClient    *http.Client
+	UserAgent     string
+}
+
+// NewClient returns a Client for the real consumer endpoints
+func NewClient() *Client {
+	return &Client{
+		ClientID:      ClientID,
+		DeviceCodeURL: DeviceCodeEndpoint,
+		TokenURL:      TokenEndpoint,
+		AuthorizeURL:  AuthorizeEndpoint,
+		HTTPClient:    &http.Client{Timeout: 10 * time.Second},
+		UserAgent:     "Nix-Client-Launcher/1.0",
+	}
+}
+
+// postForm sends a form to endpoint and returns the response, the caller
+// closes the body
+func (c *Client) postForm(ctx context.Context, endpoint string, data url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", c.UserAgent)
+	return c.HTTPClient.Do(req)
+}
+
 // StartDeviceFlow initiates the device code flow
-func StartDeviceFlow() (*DeviceCodeResponse, error) {
+func (c *Client) StartDeviceFlow(ctx context.Context) (*DeviceCodeResponse, error) {
 	data := url.Values{}
-	data.Set("client_id", ClientID)
+	data.Set("client_id", c.ClientID)
 	data.Set("scope", Scope)
 
-	req, _ := http.NewRequest("POST", DeviceCodeEndpoint, strings.NewReader(data.Encode()))
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("User-Agent", "Nix-Client-Launcher/1.0")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := c.postForm(ctx, c.DeviceCodeURL, data)
 	if err != nil {
 		return nil, err
 	}
@@ -66,76 +98,53 @@ func StartDeviceFlow() (*DeviceCodeResponse, error) {
 	return &deviceResp, nil
 }
 
-// PollForToken polls the token endpoint until the user authenticates or the code expires
-func PollForToken(deviceCode string, interval int) (*TokenResponse, error) {
-	if interval == 0 {
-		interval = 5
+// PollToken asks the token endpoint once whether the user has finished the
+// device code login. It returns the token, or the "authorization_pending" /
+// "slow_down" code while the user isn't done yet, or an error for anything
+// else. Network errors count as pending so a dropped connection doesn't end
+// the login; the caller decides how long to keep polling.
+func (c *Client) PollToken(ctx context.Context, deviceCode string) (*TokenResponse, string, error) {
+	data := url.Values{}
+	data.Set("grant_type", "urn:ietf:params:oauth:grant-type:device_code")
+	data.Set("client_id", c.ClientID)
+	data.Set("device_code", deviceCode)
+
+	resp, err := c.postForm(ctx, c.TokenURL, data)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, "", ctx.Err()
+		}
+		return nil, "authorization_pending", nil
 	}
-	
-	ticker := time.NewTicker(time.Duration(interval) * time.Second)
-	defer ticker.Stop()
-
-	timeout := time.After(15 * time.Minute)
-
-	for {
-		select {
-		case <-timeout:
-			return nil, fmt.Errorf("authentication timed out")
-		case <-ticker.C:
-			data := url.Values{}
-			data.Set("grant_type", "urn:ietf:params:oauth:grant-type:device_code")
-			data.Set("client_id", ClientID)
-			data.Set("device_code", deviceCode)
-
-			req, _ := http.NewRequest("POST", TokenEndpoint, strings.NewReader(data.Encode()))
-			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-			req.Header.Set("User-Agent", "Nix-Client-Launcher/1.0")
-
-			client := &http.Client{Timeout: 10 * time.Second}
-			resp, err := client.Do(req)
-			if err != nil {
-				continue
-			}
-			defer resp.Body.Close()
-
-			if resp.StatusCode == http.StatusOK {
-				var tokenResp TokenResponse
-				if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
-					return nil, err
-				}
-				return &tokenResp, nil
-			}
-
-			var errResp map[string]interface{}
-			json.NewDecoder(resp.Body).Decode(&errResp)
-			errCode, _ := errResp["error"].(string)
-
-			if errCode == "authorization_pending" {
-				continue
-			} else if errCode == "slow_down" {
-				ticker.Reset(time.Duration(interval+5) * time.Second)
-				continue
-			} else {
-				return nil, fmt.Errorf("token polling failed: %v", errResp)
-			}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		var tokenResp TokenResponse
+		if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+			return nil, "", err
 		}
+		return &tokenResp, "", nil
 	}
+
+	var errResp map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&errResp)
+	errCode, _ := errResp["error"].(string)
+
+	if errCode == "authorization_pending" || errCode == "slow_down" {
+		return nil, errCode, nil
+	}
+	return nil, "", fmt.Errorf("token polling failed: %v", errResp)
 }
 
 // RefreshToken refreshes the access token using the refresh token
-func RefreshToken(refreshToken string) (*TokenResponse, error) {
+func (c *Client) RefreshToken(ctx context.Context, refreshToken string) (*TokenResponse, error) {
 	data := url.Values{}
-	data.Set("client_id", ClientID)
+	data.Set("client_id", c.ClientID)
 	data.Set("refresh_token", refreshToken)
 	data.Set("grant_type", "refresh_token")
-	data.Set("scope", Scope) 
-
-	req, _ := http.NewRequest("POST", TokenEndpoint, strings.NewReader(data.Encode()))
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("User-Agent", "Nix-Client-Launcher/1.0")
+	data.Set("scope", Scope)
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := c.postForm(ctx, c.TokenURL, data)
 	if err != nil {
 		return nil, err
 	}
@@ -152,3 +161,48 @@ func RefreshToken(refreshToken string) (*TokenResponse, error) {
 	}
 	return &tokenResp, nil
 }
+
+// LoginURL builds the browser login URL for the authorization code flow
+// with an S256 PKCE challenge
+func (c *Client) LoginURL(redirectURI, challenge, state string) string {
+	params := url.Values{}
+	params.Set("client_id", c.ClientID)
+	params.Set("response_type", "code")
+	params.Set("redirect_uri", redirectURI)
+	params.Set("response_mode", "query")
+	params.Set("scope", Scope)
+	params.Set("state", state)
+	params.Set("code_challenge", challenge)
+	params.Set("code_challenge_method", "S256")
+	params.Set("prompt", "select_account")
+	return c.AuthorizeURL + "?" + params.Encode()
+}
+
+// ExchangeCode redeems an authorization code for tokens, proving with the
+// PKCE verifier that this is the client that started the login
+func (c *Client) ExchangeCode(ctx context.Context, code, verifier, redirectURI string) (*TokenResponse, error) {
+	data := url.Values{}
+	data.Set("client_id", c.ClientID)
+	data.Set("grant_type", "authorization_code")
+	data.Set("code", code)
+	data.Set("redirect_uri", redirectURI)
+	data.Set("code_verifier", verifier)
+	data.Set("scope", Scope)
+
+	resp, err := c.postForm(ctx, c.TokenURL, data)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("microsoft code exchange failed: %s - %s", resp.Status, string(body))
+	}
+
+	var tokenResp TokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return nil, err
+	}
+	return &tokenResp, nil
+}
@@ -2,10 +2,13 @@ package minecraft
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -46,14 +49,45 @@ type MinecraftProfile struct {
 	} `json:"capes"`
 }
 
+// ActiveSkin returns the URL of the skin the player is wearing, or "" for
+// the default skin
+func (p *MinecraftProfile) ActiveSkin() string {
+	for _, skin := range p.Skins {
+		if skin.State == "ACTIVE" {
+			return skin.URL
+		}
+	}
+	return ""
+}
+
 type EntitlementsResponse struct {
 	Items []struct {
 		Name string `json:"name"`
 	} `json:"items"`
 }
 
+// Client talks to the Minecraft services API
+type Client struct {
+	AuthURL         string
+	ProfileURL      string
+	EntitlementsURL string
+	HTTPClient      *http.Client
+	UserAgent       string
+}
+
+// NewClient returns a Client for the real Minecraft services endpoints
+func NewClient() *Client {
+	return &Client{
+		AuthURL:         MinecraftAuthURL,
+		ProfileURL:      MinecraftProfileURL,
+		EntitlementsURL: MinecraftEntitlementsURL,
+		HTTPClient:      &http.Client{Timeout: 10 * time.Second},
+		UserAgent:       "Nix-Client-Launcher/1.0",
+	}
+}
+
 // AuthenticateMinecraft exchanges XSTS Token and User Hash for Minecraft Access Token
-func AuthenticateMinecraft(userHash, xstsToken string) (*MinecraftAuthResponse, error) {
+func (c *Client) AuthenticateMinecraft(ctx context.Context, userHash, xstsToken string) (*MinecraftAuthResponse, error) {
 	// Ensure the identityToken is formatted correctly: "XBL3.0 x=<user_hash>;<xsts_token>"
 	reqBody := MinecraftAuthRequest{
 		IdentityToken: fmt.Sprintf("XBL3.0 x=%s;%s", userHash, xstsToken),
@@ -64,16 +98,15 @@ func AuthenticateMinecraft(userHash, xstsToken string) (*MinecraftAuthResponse,
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", MinecraftAuthURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.AuthURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
-	req.Header.Set("User-Agent", "Nix-Client-Launcher/1.0")
+	req.Header.Set("User-Agent", c.UserAgent)
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -93,16 +126,8 @@ func AuthenticateMinecraft(userHash, xstsToken string) (*MinecraftAuthResponse,
 }
 
 // CheckOwnership verifies if the user owns Minecraft Java Edition
-func CheckOwnership(accessToken string) (bool, error) {
-	req, err := http.NewRequest("GET", MinecraftEntitlementsURL, nil)
-	if err != nil {
-		return false, err
-	}
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("User-Agent", "Nix-Client-Launcher/1.0")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+func (c *Client) CheckOwnership(ctx context.Context, accessToken string) (bool, error) {
+	resp, err := c.get(ctx, c.EntitlementsURL, accessToken)
 	if err != nil {
 		return false, err
 	}
@@ -129,16 +154,8 @@ func CheckOwnership(accessToken string) (bool, error) {
 }
 
 // GetProfile fetches the Minecraft profile (UUID, Username, Skins)
-func GetProfile(accessToken string) (*MinecraftProfile, error) {
-	req, err := http.NewRequest("GET", MinecraftProfileURL, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("User-Agent", "Nix-Client-Launcher/1.0")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+func (c *Client) GetProfile(ctx context.Context, accessToken string) (*MinecraftProfile, error) {
+	resp, err := c.get(ctx, c.ProfileURL, accessToken)
 	if err != nil {
 		return nil, err
 	}
@@ -155,3 +172,34 @@ func GetProfile(accessToken string) (*MinecraftProfile, error) {
 	}
 	return &profile, nil
 }
+
+// get sends an authenticated GET, the caller closes the body
+func (c *Client) get(ctx context.Context, endpoint, accessToken string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("User-Agent", c.UserAgent)
+	return c.HTTPClient.Do(req)
+}
+
+// XUIDFromToken reads the Xbox user id claim from a Minecraft access token,
+// newer game versions pass it to telemetry as ${auth_xuid}
+func XUIDFromToken(accessToken string) string {
+	parts := strings.Split(accessToken, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return ""
+	}
+	var claims struct {
+		XUID string `json:"xuid"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return ""
+	}
+	return claims.XUID
+}
@@ -1,24 +1,31 @@
 package pkce
 
 import (
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
-	"math/rand"
-	"time"
+	"fmt"
+	"io"
 )
 
-// GenerateVerifier generates a random code verifier for PKCE.
-// It returns a string between 43 and 128 characters long.
-func GenerateVerifier() string {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-._~"
-	const length = 128 // Maximum allowed length for better security
+// verifierBytes gives a 43 character verifier, the 256 bits of entropy
+// RFC 7636 section 7.1 recommends
+const verifierBytes = 32
 
-	seededRand := rand.New(rand.NewSource(time.Now().UnixNano()))
-	b := make([]byte, length)
-	for i := range b {
-		b[i] = charset[seededRand.Intn(len(charset))]
+const stateBytes = 32
+
+// random is the entropy source, crypto/rand outside of tests
+var random io.Reader = rand.Reader
+
+// GenerateVerifier generates a random code verifier for PKCE: 32 bytes from
+// crypto/rand, base64url encoded without padding as RFC 7636 section 4.1
+// suggests.
+func GenerateVerifier() (string, error) {
+	b, err := randomBytes(verifierBytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate pkce verifier: %v", err)
 	}
-	return string(b)
+	return encodeVerifier(b), nil
 }
 
 // GenerateChallenge generates the code challenge from the verifier using S256.
@@ -27,3 +34,25 @@ func GenerateChallenge(verifier string) string {
 	// Base64 URL encoding without padding
 	return base64.RawURLEncoding.EncodeToString(hash[:])
 }
+
+// GenerateState generates an unguessable OAuth state value, which doubles as
+// a nonce tying the redirect back to the login that was started
+func GenerateState() (string, error) {
+	b, err := randomBytes(stateBytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate login state: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func encodeVerifier(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(random, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,145 @@
+package pkce
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"regexp"
+	"testing"
+)
+
+// RFC 7636 appendix B: the octets of a verifier, its encoding, the SHA-256
+// of that and the resulting S256 challenge
+var (
+	appendixBOctets = []byte{
+		116, 24, 223, 180, 151, 153, 224, 37, 79, 250, 96, 125, 216, 173,
+		187, 186, 22, 212, 37, 77, 105, 214, 191, 240, 91, 88, 5, 88, 83,
+		132, 141, 121,
+	}
+	appendixBVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	appendixBHash     = []byte{
+		19, 211, 30, 150, 26, 26, 216, 236, 47, 22, 177, 12, 76, 152, 46,
+		8, 118, 168, 120, 173, 109, 241, 68, 86, 110, 225, 137, 74, 203,
+		112, 249, 195,
+	}
+	appendixBChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+// RFC 7636 section 4.1: code-verifier = 43*128unreserved
+var verifierPattern = regexp.MustCompile(`^[A-Za-z0-9\-._~]{43,128}$`)
+
+// RFC 7636 section 4.2: a base64url SHA-256 without padding is 43 characters
+var challengePattern = regexp.MustCompile(`^[A-Za-z0-9\-_]{43}$`)
+
+func withRandom(t *testing.T, r io.Reader) {
+	t.Helper()
+	old := random
+	random = r
+	t.Cleanup(func() { random = old })
+}
+
+func TestAppendixBVerifier(t *testing.T) {
+	if got := encodeVerifier(appendixBOctets); got != appendixBVerifier {
+		t.Fatalf("encodeVerifier = %q, want %q", got, appendixBVerifier)
+	}
+}
+
+func TestAppendixBHash(t *testing.T) {
+	hash := sha256.Sum256([]byte(appendixBVerifier))
+	if !bytes.Equal(hash[:], appendixBHash) {
+		t.Fatalf("SHA-256 of verifier = %v, want %v", hash, appendixBHash)
+	}
+}
+
+func TestAppendixBChallenge(t *testing.T) {
+	if got := GenerateChallenge(appendixBVerifier); got != appendixBChallenge {
+		t.Fatalf("GenerateChallenge = %q, want %q", got, appendixBChallenge)
+	}
+}
+
+func TestGenerateVerifierUsesRandomSource(t *testing.T) {
+	withRandom(t, bytes.NewReader(appendixBOctets))
+
+	got, err := GenerateVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != appendixBVerifier {
+		t.Fatalf("GenerateVerifier = %q, want %q", got, appendixBVerifier)
+	}
+	if challenge := GenerateChallenge(got); challenge != appendixBChallenge {
+		t.Fatalf("challenge = %q, want %q", challenge, appendixBChallenge)
+	}
+}
+
+func TestGenerateVerifierFormat(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		v, err := GenerateVerifier()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !verifierPattern.MatchString(v) {
+			t.Fatalf("verifier %q does not match RFC 7636 section 4.1", v)
+		}
+		if c := GenerateChallenge(v); !challengePattern.MatchString(c) {
+			t.Fatalf("challenge %q does not match RFC 7636 section 4.2", c)
+		}
+	}
+}
+
+func TestGenerateVerifierUnique(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		v, err := GenerateVerifier()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[v] {
+			t.Fatalf("verifier %q generated twice", v)
+		}
+		seen[v] = true
+	}
+}
+
+func TestGenerateState(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		s, err := GenerateState()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(s) < 43 {
+			t.Fatalf("state %q is shorter than 256 bits", s)
+		}
+		if seen[s] {
+			t.Fatalf("state %q generated twice", s)
+		}
+		seen[s] = true
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("entropy unavailable")
+}
+
+func TestRandomFailure(t *testing.T) {
+	withRandom(t, failingReader{})
+
+	if v, err := GenerateVerifier(); err == nil {
+		t.Fatalf("GenerateVerifier = %q, want an error", v)
+	}
+	if s, err := GenerateState(); err == nil {
+		t.Fatalf("GenerateState = %q, want an error", s)
+	}
+}
+
+func TestShortRandomRead(t *testing.T) {
+	withRandom(t, bytes.NewReader(appendixBOctets[:10]))
+
+	if v, err := GenerateVerifier(); err == nil {
+		t.Fatalf("GenerateVerifier = %q from 10 bytes, want an error", v)
+	}
+}
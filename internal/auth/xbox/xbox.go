@@ -2,6 +2,7 @@ package xbox
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -14,6 +15,12 @@ const (
 	XSTSAuthURL     = "https://xsts.auth.xboxlive.com/xsts/authorize"
 )
 
+// XSTS relying parties. Only Xbox Live tokens carry the gamertag claim.
+const (
+	MinecraftRelyingParty = "rp://api.minecraftservices.com/"
+	XboxLiveRelyingParty  = "http://xboxlive.com"
+)
+
 type XboxAuthRequest struct {
 	Properties XboxAuthProperties `json:"Properties"`
 	RelyingParty string           `json:"RelyingParty"`
@@ -33,6 +40,7 @@ type XboxAuthResponse struct {
 	DisplayClaims struct {
 		Xui []struct {
 			Uhs string `json:"uhs"`
+			Gtg string `json:"gtg,omitempty"` // gamertag, for the Xbox Live relying party only
 		} `json:"xui"`
 	} `json:"DisplayClaims"`
 }
@@ -48,8 +56,26 @@ type XSTSAuthProperties struct {
 	UserTokens []string `json:"UserTokens"`
 }
 
+// Client talks to the Xbox Live user and XSTS token services
+type Client struct {
+	UserAuthURL string
+	XSTSAuthURL string
+	HTTPClient  *http.Client
+	UserAgent   string
+}
+
+// NewClient returns a Client for the real Xbox Live endpoints
+func NewClient() *Client {
+	return &Client{
+		UserAuthURL: XboxLiveAuthURL,
+		XSTSAuthURL: XSTSAuthURL,
+		HTTPClient:  &http.Client{Timeout: 10 * time.Second},
+		UserAgent:   "Nix-Client-Launcher/1.0",
+	}
+}
+
 // AuthenticateXboxLive exchanges Microsoft Access Token for Xbox Live Token
-func AuthenticateXboxLive(msAccessToken string) (*XboxAuthResponse, error) {
+func (c *Client) AuthenticateXboxLive(ctx context.Context, msAccessToken string) (*XboxAuthResponse, error) {
 	reqBody := XboxAuthRequest{
 		Properties: XboxAuthProperties{
 			AuthMethod: "RPS",
@@ -60,64 +86,64 @@ func AuthenticateXboxLive(msAccessToken string) (*XboxAuthResponse, error) {
 		TokenType:    "JWT",
 	}
 
-	jsonData, err := json.Marshal(reqBody)
+	authResp, err := c.post(ctx, c.UserAuthURL, reqBody)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("xbox live auth failed: %v", err)
 	}
+	return authResp, nil
+}
 
-	req, err := http.NewRequest("POST", XboxLiveAuthURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("User-Agent", "Nix-Client-Launcher/1.0")
+// AuthenticateXSTS exchanges Xbox Live Token for XSTS Token
+func (c *Client) AuthenticateXSTS(ctx context.Context, xboxToken string) (*XboxAuthResponse, error) {
+	return c.authorize(ctx, xboxToken, MinecraftRelyingParty)
+}
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+// Gamertag looks up the player's gamertag with an Xbox Live XSTS token
+func (c *Client) Gamertag(ctx context.Context, xboxToken string) (string, error) {
+	authResp, err := c.authorize(ctx, xboxToken, XboxLiveRelyingParty)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("xbox live auth failed: %s - Body: %s", resp.Status, string(bodyBytes))
+	if len(authResp.DisplayClaims.Xui) == 0 || authResp.DisplayClaims.Xui[0].Gtg == "" {
+		return "", fmt.Errorf("no gamertag found in xsts response")
 	}
-
-	var authResp XboxAuthResponse
-	if err := json.NewDecoder(resp.Body).Decode(&authResp); err != nil {
-		return nil, err
-	}
-	return &authResp, nil
+	return authResp.DisplayClaims.Xui[0].Gtg, nil
 }
 
-// AuthenticateXSTS exchanges Xbox Live Token for XSTS Token
-func AuthenticateXSTS(xboxToken string) (*XboxAuthResponse, error) {
+// authorize requests an XSTS token for relyingParty
+func (c *Client) authorize(ctx context.Context, xboxToken, relyingParty string) (*XboxAuthResponse, error) {
 	reqBody := XSTSAuthRequest{
 		Properties: XSTSAuthProperties{
 			SandboxId:  "RETAIL",
 			UserTokens: []string{xboxToken},
 		},
-		RelyingParty: "rp://api.minecraftservices.com/",
+		RelyingParty: relyingParty,
 		TokenType:    "JWT",
 	}
 
+	authResp, err := c.post(ctx, c.XSTSAuthURL, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("xsts auth failed: %v", err)
+	}
+	return authResp, nil
+}
+
+// post sends reqBody as JSON to endpoint and decodes the token response
+func (c *Client) post(ctx context.Context, endpoint string, reqBody interface{}) (*XboxAuthResponse, error) {
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", XSTSAuthURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
-	req.Header.Set("User-Agent", "Nix-Client-Launcher/1.0")
+	req.Header.Set("User-Agent", c.UserAgent)
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -125,7 +151,7 @@ func AuthenticateXSTS(xboxToken string) (*XboxAuthResponse, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("xsts auth failed: %s - Body: %s", resp.Status, string(bodyBytes))
+		return nil, fmt.Errorf("%s - Body: %s", resp.Status, string(bodyBytes))
 	}
 
 	var authResp XboxAuthResponse
@@ -0,0 +1,449 @@
+package download
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const UserAgent = "Nix-Client-Launcher/1.0"
+
+// DefaultStallTimeout is how long a download may go without receiving a byte
+// before the attempt is abandoned and retried
+const DefaultStallTimeout = 30 * time.Second
+
+// DefaultClient is shared by every package that talks to Mojang, Fabric or
+// Modrinth so connections are pooled instead of rebuilt per request
+var DefaultClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   32,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		ForceAttemptHTTP2:     true,
+	},
+}
+
+// File is a single file to fetch. Hashes and Size are optional but every one
+// that is set is verified.
+type File struct {
+	URL        string
+	Path       string
+	SHA1       string
+	SHA512     string
+	Size       int64
+	Executable bool
+}
+
+type EventKind int
+
+const (
+	FileStarted EventKind = iota
+	FileSkipped
+	FileProgress
+	FileDone
+	FileFailed
+	FileRetry
+)
+
+// Event reports progress of a Download call. FilesDone/FilesTotal and
+// BytesDone/BytesTotal always describe the whole batch.
+type Event struct {
+	Kind       EventKind
+	File       File
+	Err        error
+	FilesDone  int
+	FilesTotal int
+	BytesDone  int64
+	BytesTotal int64
+}
+
+// Manager downloads batches of files with a bounded worker pool
+type Manager struct {
+	Client    *http.Client
+	Workers   int
+	Retries   int
+	Backoff   time.Duration
+	UserAgent string
+
+	// StallTimeout bounds the wait for each read of a response body, 0 uses
+	// DefaultStallTimeout
+	StallTimeout time.Duration
+}
+
+// NewManager returns a Manager with defaults suited to thousands of small asset files
+func NewManager() *Manager {
+	return &Manager{
+		Client:    DefaultClient,
+		Workers:   16,
+		Retries:   3,
+		Backoff:   500 * time.Millisecond,
+		UserAgent: UserAgent,
+	}
+}
+
+// batch tracks the counters shared by the workers of one Download call
+type batch struct {
+	events     chan<- Event
+	filesTotal int
+	bytesTotal int64
+	filesDone  int64
+	bytesDone  int64
+}
+
+func (b *batch) emit(kind EventKind, f File, err error) {
+	if b.events == nil {
+		return
+	}
+	b.events <- Event{
+		Kind:       kind,
+		File:       f,
+		Err:        err,
+		FilesDone:  int(atomic.LoadInt64(&b.filesDone)),
+		FilesTotal: b.filesTotal,
+		BytesDone:  atomic.LoadInt64(&b.bytesDone),
+		BytesTotal: b.bytesTotal,
+	}
+}
+
+// Download fetches every file, skipping ones already present with the right
+// size and hash. Events are sent to events if it is not nil; the caller must
+// keep draining it until Download returns. All failures are joined into the
+// returned error.
+func (m *Manager) Download(ctx context.Context, files []File, events chan<- Event) error {
+	files = dedupe(files)
+
+	b := &batch{events: events, filesTotal: len(files)}
+	for _, f := range files {
+		b.bytesTotal += f.Size
+	}
+
+	workers := m.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	if workers > len(files) {
+		workers = len(files)
+	}
+
+	jobs := make(chan File)
+	var (
+		mu   sync.Mutex
+		errs []error
+		wg   sync.WaitGroup
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range jobs {
+				if err := m.fetchWithRetry(ctx, f, b); err != nil {
+					mu.Lock()
+					errs = append(errs, fmt.Errorf("%s: %v", f.URL, err))
+					mu.Unlock()
+					b.emit(FileFailed, f, err)
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, f := range files {
+		select {
+		case <-ctx.Done():
+			break feed
+		case jobs <- f:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
+
+func (m *Manager) fetchWithRetry(ctx context.Context, f File, b *batch) error {
+	if err := verify(f.Path, f); err == nil {
+		atomic.AddInt64(&b.filesDone, 1)
+		atomic.AddInt64(&b.bytesDone, f.Size)
+		b.emit(FileSkipped, f, nil)
+		return nil
+	}
+
+	b.emit(FileStarted, f, nil)
+	var err error
+	for attempt := 0; attempt <= m.Retries; attempt++ {
+		if attempt > 0 {
+			b.emit(FileRetry, f, err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(m.Backoff << (attempt - 1)):
+			}
+		}
+
+		var written int64
+		written, err = m.fetch(ctx, f, b)
+		if err == nil {
+			atomic.AddInt64(&b.filesDone, 1)
+			b.emit(FileDone, f, nil)
+			return nil
+		}
+		// Roll back the bytes counted for this attempt so retries don't inflate progress
+		atomic.AddInt64(&b.bytesDone, -written)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var status *StatusError
+		if errors.As(err, &status) && !status.Temporary() {
+			return err
+		}
+	}
+	return err
+}
+
+// fetch downloads f into f.Path+".part", resuming a previous partial file
+// when the server supports ranges, then verifies and renames it into place.
+// It returns the number of bytes counted towards batch progress.
+func (m *Manager) fetch(ctx context.Context, f File, b *batch) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0755); err != nil {
+		return 0, err
+	}
+	partPath := f.Path + ".part"
+
+	var offset int64
+	if info, err := os.Stat(partPath); err == nil {
+		offset = info.Size()
+		if f.Size > 0 && offset >= f.Size {
+			os.Remove(partPath)
+			offset = 0
+		}
+	}
+
+	// Cancelled when no data arrives for a while, so a connection that hangs
+	// mid-body gets retried instead of holding the worker forever
+	stall := m.StallTimeout
+	if stall <= 0 {
+		stall = DefaultStallTimeout
+	}
+	reqCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	timer := time.AfterFunc(stall, cancel)
+	defer timer.Stop()
+	stalled := func(err error) error {
+		if ctx.Err() == nil && reqCtx.Err() != nil {
+			return fmt.Errorf("no data received for %v", stall)
+		}
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(reqCtx, "GET", f.URL, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", m.userAgent())
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+
+	client := m.Client
+	if client == nil {
+		client = DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, stalled(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0 {
+		resp.Body.Close()
+		// Usually the .part is already whole and a crash came between the
+		// last write and the rename
+		if (f.Size > 0 || f.SHA1 != "" || f.SHA512 != "") && verify(partPath, f) == nil {
+			atomic.AddInt64(&b.bytesDone, offset)
+			return offset, finish(partPath, f)
+		}
+		os.Remove(partPath)
+		return m.fetch(ctx, f, b)
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		flags |= os.O_APPEND
+	case http.StatusOK:
+		flags |= os.O_TRUNC
+		offset = 0
+	default:
+		os.Remove(partPath)
+		return 0, &StatusError{Code: resp.StatusCode, Status: resp.Status}
+	}
+
+	out, err := os.OpenFile(partPath, flags, 0644)
+	if err != nil {
+		return 0, err
+	}
+
+	// Resumed bytes count as progress too, they just didn't cost a request
+	counted := offset
+	atomic.AddInt64(&b.bytesDone, offset)
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, readErr := resp.Body.Read(buf)
+		timer.Reset(stall)
+		if n > 0 {
+			if _, err := out.Write(buf[:n]); err != nil {
+				out.Close()
+				return counted, err
+			}
+			counted += int64(n)
+			atomic.AddInt64(&b.bytesDone, int64(n))
+			b.emit(FileProgress, f, nil)
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			out.Close()
+			return counted, stalled(readErr)
+		}
+	}
+	if err := out.Close(); err != nil {
+		return counted, err
+	}
+
+	if err := verify(partPath, f); err != nil {
+		// A corrupt partial file would poison every retry, start over
+		os.Remove(partPath)
+		return counted, err
+	}
+	return counted, finish(partPath, f)
+}
+
+// finish moves a verified .part file into place
+func finish(partPath string, f File) error {
+	mode := os.FileMode(0644)
+	if f.Executable {
+		mode = 0755
+	}
+	if err := os.Chmod(partPath, mode); err != nil {
+		return err
+	}
+	return os.Rename(partPath, f.Path)
+}
+
+// StatusError is a response other than 200 or 206
+type StatusError struct {
+	Code   int
+	Status string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %s", e.Status)
+}
+
+// Temporary reports whether the request is worth retrying. Client errors
+// like 403 and 404 won't go away on their own, except for timeouts and rate
+// limiting.
+func (e *StatusError) Temporary() bool {
+	switch e.Code {
+	case http.StatusRequestTimeout, http.StatusTooEarly, http.StatusTooManyRequests:
+		return true
+	}
+	return e.Code < 400 || e.Code >= 500
+}
+
+func (m *Manager) userAgent() string {
+	if m.UserAgent != "" {
+		return m.UserAgent
+	}
+	return UserAgent
+}
+
+// Verify checks the file at path against the size and hashes set on f
+func Verify(path string, f File) error {
+	return verify(path, f)
+}
+
+func verify(path string, f File) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if f.Size > 0 && info.Size() != f.Size {
+		return fmt.Errorf("size mismatch: expected %d, got %d", f.Size, info.Size())
+	}
+	if f.SHA1 == "" && f.SHA512 == "" {
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var sha1Hash, sha512Hash hash.Hash
+	var writers []io.Writer
+	if f.SHA1 != "" {
+		sha1Hash = sha1.New()
+		writers = append(writers, sha1Hash)
+	}
+	if f.SHA512 != "" {
+		sha512Hash = sha512.New()
+		writers = append(writers, sha512Hash)
+	}
+	if _, err := io.Copy(io.MultiWriter(writers...), file); err != nil {
+		return err
+	}
+
+	if sha1Hash != nil {
+		if got := hex.EncodeToString(sha1Hash.Sum(nil)); got != f.SHA1 {
+			return fmt.Errorf("sha1 mismatch: expected %s, got %s", f.SHA1, got)
+		}
+	}
+	if sha512Hash != nil {
+		if got := hex.EncodeToString(sha512Hash.Sum(nil)); got != f.SHA512 {
+			return fmt.Errorf("sha512 mismatch: expected %s, got %s", f.SHA512, got)
+		}
+	}
+	return nil
+}
+
+// dedupe drops repeated destination paths, two workers writing the same
+// .part file would corrupt it
+func dedupe(files []File) []File {
+	seen := make(map[string]bool, len(files))
+	out := make([]File, 0, len(files))
+	for _, f := range files {
+		if seen[f.Path] {
+			continue
+		}
+		seen[f.Path] = true
+		out = append(out, f)
+	}
+	return out
+}
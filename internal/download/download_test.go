@@ -0,0 +1,256 @@
+package download
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const content = "hello, world"
+
+func contentSHA1() string {
+	sum := sha1.Sum([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func testManager(client *http.Client) *Manager {
+	m := NewManager()
+	m.Client = client
+	m.Backoff = time.Millisecond
+	return m
+}
+
+// collect drains events while Download runs and returns the last one
+func collect(events chan Event) (chan struct{}, *Event) {
+	done := make(chan struct{})
+	last := &Event{}
+	go func() {
+		defer close(done)
+		for e := range events {
+			*last = e
+		}
+	}()
+	return done, last
+}
+
+func TestDownload(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != UserAgent {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(content))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	files := []File{
+		{URL: srv.URL + "/a", Path: filepath.Join(dir, "a"), SHA1: contentSHA1(), Size: int64(len(content))},
+		// Unknown size, the bytes must still only be counted once
+		{URL: srv.URL + "/b", Path: filepath.Join(dir, "sub", "b")},
+	}
+	events := make(chan Event)
+	done, last := collect(events)
+	err := testManager(srv.Client()).Download(context.Background(), files, events)
+	close(events)
+	<-done
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	for _, f := range files {
+		if data, err := os.ReadFile(f.Path); err != nil || string(data) != content {
+			t.Errorf("%s = %q, %v", f.Path, data, err)
+		}
+	}
+	if last.FilesDone != 2 || last.BytesDone != 2*int64(len(content)) {
+		t.Errorf("last event = %d files, %d bytes, want 2 files, %d bytes", last.FilesDone, last.BytesDone, 2*len(content))
+	}
+}
+
+func TestDownloadHashMismatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("something else"))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "a")
+	err := testManager(srv.Client()).Download(context.Background(), []File{{URL: srv.URL, Path: path, SHA1: contentSHA1()}}, nil)
+	if err == nil {
+		t.Fatal("Download accepted a file with the wrong hash")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("the bad file was moved into place")
+	}
+	if _, err := os.Stat(path + ".part"); !os.IsNotExist(err) {
+		t.Error("the bad .part was kept")
+	}
+}
+
+func TestDownloadPermanentStatus(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	err := testManager(srv.Client()).Download(context.Background(), []File{{URL: srv.URL, Path: filepath.Join(t.TempDir(), "a")}}, nil)
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("Download = %v, want a 404", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("a 404 was requested %d times, want 1", n)
+	}
+}
+
+func TestDownloadRetriesServerErrors(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(content))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "a")
+	if err := testManager(srv.Client()).Download(context.Background(), []File{{URL: srv.URL, Path: path, SHA1: contentSHA1()}}, nil); err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 3 {
+		t.Errorf("requested %d times, want 3", n)
+	}
+}
+
+func TestDownloadResume(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "bytes=5-" {
+			t.Errorf("Range = %q, want bytes=5-", r.Header.Get("Range"))
+		}
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte(content[5:]))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "a")
+	if err := os.WriteFile(path+".part", []byte(content[:5]), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := testManager(srv.Client()).Download(context.Background(), []File{{URL: srv.URL, Path: path, SHA1: contentSHA1()}}, nil); err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != content {
+		t.Errorf("resumed file = %q, want %q", data, content)
+	}
+}
+
+// rangeServer answers every ranged request with 416 and plain ones with the
+// content, counting both
+func rangeServer(ranged, plain *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			atomic.AddInt32(ranged, 1)
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		atomic.AddInt32(plain, 1)
+		w.Write([]byte(content))
+	}))
+}
+
+func TestDownloadRangeNotSatisfiable(t *testing.T) {
+	tests := []struct {
+		name  string
+		part  string
+		file  File
+		plain int32 // requests without a Range expected
+	}{
+		{"complete part is kept", content, File{SHA1: contentSHA1()}, 0},
+		{"damaged part is fetched again", "hello, wrld!", File{SHA1: contentSHA1()}, 1},
+		{"unverifiable part is fetched again", content, File{}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ranged, plain int32
+			srv := rangeServer(&ranged, &plain)
+			defer srv.Close()
+
+			f := tt.file
+			f.URL = srv.URL
+			f.Path = filepath.Join(t.TempDir(), "a")
+			if err := os.WriteFile(f.Path+".part", []byte(tt.part), 0644); err != nil {
+				t.Fatal(err)
+			}
+			events := make(chan Event)
+			done, last := collect(events)
+			err := testManager(srv.Client()).Download(context.Background(), []File{f}, events)
+			close(events)
+			<-done
+			if err != nil {
+				t.Fatalf("Download: %v", err)
+			}
+			if data, _ := os.ReadFile(f.Path); string(data) != content {
+				t.Errorf("file = %q, want %q", data, content)
+			}
+			if ranged != 1 || plain != tt.plain {
+				t.Errorf("%d ranged and %d plain requests, want 1 and %d", ranged, plain, tt.plain)
+			}
+			if last.BytesDone != int64(len(content)) {
+				t.Errorf("BytesDone = %d, want %d", last.BytesDone, len(content))
+			}
+		})
+	}
+}
+
+func TestDownloadStall(t *testing.T) {
+	var requests int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			// Send half the body, then go quiet
+			w.Header().Set("Content-Length", "12")
+			w.Write([]byte(content[:6]))
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+			case <-release:
+			}
+			return
+		}
+		w.Write([]byte(content))
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	m := testManager(srv.Client())
+	m.StallTimeout = 50 * time.Millisecond
+	path := filepath.Join(t.TempDir(), "a")
+
+	result := make(chan error, 1)
+	go func() {
+		result <- m.Download(context.Background(), []File{{URL: srv.URL, Path: path, SHA1: contentSHA1()}}, nil)
+	}()
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("Download: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Download hung on a stalled connection")
+	}
+	if n := atomic.LoadInt32(&requests); n < 2 {
+		t.Errorf("requested %d times, want a retry after the stall", n)
+	}
+	if data, _ := os.ReadFile(path); string(data) != content {
+		t.Errorf("file = %q, want %q", data, content)
+	}
+}
@@ -0,0 +1,198 @@
+package assets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"Nix-Client-Launcher/internal/download"
+	"Nix-Client-Launcher/internal/game/version"
+)
+
+const ResourcesURL = "https://resources.download.minecraft.net"
+
+type Index struct {
+	Objects        map[string]Object `json:"objects"`
+	Virtual        bool              `json:"virtual,omitempty"`
+	MapToResources bool              `json:"map_to_resources,omitempty"`
+}
+
+type Object struct {
+	Hash string `json:"hash"`
+	Size int64  `json:"size"`
+}
+
+// Store is the content-addressed asset store shared by every instance,
+// laid out like the vanilla launcher's assets directory
+type Store struct {
+	Root string
+}
+
+// NewStore returns the store under <dataDir>/assets, next to accounts.json
+func NewStore(dataDir string) *Store {
+	return &Store{Root: filepath.Join(dataDir, "assets")}
+}
+
+func (s *Store) IndexPath(id string) string {
+	return filepath.Join(s.Root, "indexes", id+".json")
+}
+
+// ObjectPath returns objects/<first two hash chars>/<hash>. The hash must have
+// passed validHash, LoadIndex checks every hash in an index.
+func (s *Store) ObjectPath(hash string) string {
+	if len(hash) < 2 {
+		return filepath.Join(s.Root, "objects", hash)
+	}
+	return filepath.Join(s.Root, "objects", hash[:2], hash)
+}
+
+// VirtualDir is where legacy "virtual" indexes are materialised by name
+func (s *Store) VirtualDir(id string) string {
+	return filepath.Join(s.Root, "virtual", id)
+}
+
+// LoadIndex reads an already downloaded index and rejects one with hashes
+// that aren't SHA-1s or names that would escape the directory they are laid
+// out in
+func (s *Store) LoadIndex(id string) (*Index, error) {
+	file, err := os.Open(s.IndexPath(id))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var idx Index
+	if err := json.NewDecoder(file).Decode(&idx); err != nil {
+		return nil, fmt.Errorf("failed to parse asset index %s: %v", id, err)
+	}
+	for name, obj := range idx.Objects {
+		if !validHash(obj.Hash) {
+			return nil, fmt.Errorf("asset index %s: %s has an invalid hash %q", id, name, obj.Hash)
+		}
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			return nil, fmt.Errorf("asset index %s: invalid asset name %q", id, name)
+		}
+	}
+	return &idx, nil
+}
+
+// validHash reports whether hash is a lowercase hex SHA-1
+func validHash(hash string) bool {
+	if len(hash) != 40 {
+		return false
+	}
+	for _, c := range hash {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Install downloads the asset index for v and every object it lists. Objects
+// already in the store are verified and skipped, so versions sharing assets
+// only pay for them once. Legacy layouts are then materialised: virtual
+// indexes under virtual/<id> and map_to_resources into <gameDir>/resources.
+func (s *Store) Install(ctx context.Context, m *download.Manager, v *version.Version, gameDir string, events chan<- download.Event) (*Index, error) {
+	if v.AssetIndex == nil || v.AssetIndex.URL == "" {
+		return nil, fmt.Errorf("version %s has no asset index", v.ID)
+	}
+	ref := v.AssetIndex
+
+	indexFile := download.File{
+		URL:  ref.URL,
+		Path: s.IndexPath(ref.ID),
+		SHA1: ref.SHA1,
+		Size: ref.Size,
+	}
+	if err := m.Download(ctx, []download.File{indexFile}, nil); err != nil {
+		return nil, fmt.Errorf("failed to download asset index %s: %v", ref.ID, err)
+	}
+
+	idx, err := s.LoadIndex(ref.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]download.File, 0, len(idx.Objects))
+	for _, obj := range idx.Objects {
+		files = append(files, download.File{
+			URL:  ResourcesURL + "/" + obj.Hash[:2] + "/" + obj.Hash,
+			Path: s.ObjectPath(obj.Hash),
+			SHA1: obj.Hash,
+			Size: obj.Size,
+		})
+	}
+	if err := m.Download(ctx, files, events); err != nil {
+		return nil, fmt.Errorf("failed to download assets: %v", err)
+	}
+
+	if idx.Virtual {
+		if err := s.materialise(idx, s.VirtualDir(ref.ID)); err != nil {
+			return nil, fmt.Errorf("failed to build virtual assets: %v", err)
+		}
+	}
+	if idx.MapToResources && gameDir != "" {
+		if err := s.materialise(idx, filepath.Join(gameDir, "resources")); err != nil {
+			return nil, fmt.Errorf("failed to map assets to resources: %v", err)
+		}
+	}
+	return idx, nil
+}
+
+// GameAssetsDir returns the directory legacy versions expect as ${game_assets}
+func (s *Store) GameAssetsDir(id string, idx *Index, gameDir string) string {
+	switch {
+	case idx != nil && idx.MapToResources && gameDir != "":
+		return filepath.Join(gameDir, "resources")
+	case idx != nil && idx.Virtual:
+		return s.VirtualDir(id)
+	}
+	return s.Root
+}
+
+// materialise lays out every object under dir by its asset name, hard
+// linking where possible so the copy costs no extra space
+func (s *Store) materialise(idx *Index, dir string) error {
+	for name, obj := range idx.Objects {
+		if !validHash(obj.Hash) || !filepath.IsLocal(filepath.FromSlash(name)) {
+			return fmt.Errorf("invalid asset %q", name)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if info, err := os.Stat(target); err == nil && info.Size() == obj.Size {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		os.Remove(target)
+		if err := os.Link(s.ObjectPath(obj.Hash), target); err == nil {
+			continue
+		}
+		if err := copyFile(s.ObjectPath(obj.Hash), target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,233 @@
+package libraries
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"Nix-Client-Launcher/internal/download"
+	"Nix-Client-Launcher/internal/game/version"
+)
+
+const MojangLibrariesURL = "https://libraries.minecraft.net/"
+
+// Coordinate is a Maven group:artifact:version[:classifier][@extension] name
+type Coordinate struct {
+	Group      string
+	Artifact   string
+	Version    string
+	Classifier string
+	Extension  string
+}
+
+// ParseCoordinate splits a Maven name as used in libraries[].name
+func ParseCoordinate(name string) (Coordinate, error) {
+	var c Coordinate
+	c.Extension = "jar"
+	if i := strings.LastIndex(name, "@"); i >= 0 {
+		c.Extension = name[i+1:]
+		name = name[:i]
+	}
+
+	parts := strings.Split(name, ":")
+	if len(parts) < 3 || len(parts) > 4 {
+		return c, fmt.Errorf("invalid maven coordinate %q", name)
+	}
+	c.Group, c.Artifact, c.Version = parts[0], parts[1], parts[2]
+	if len(parts) == 4 {
+		c.Classifier = parts[3]
+	}
+	if c.Group == "" || c.Artifact == "" || c.Version == "" {
+		return c, fmt.Errorf("invalid maven coordinate %q", name)
+	}
+	return c, nil
+}
+
+// Path returns the repository-relative path, always with forward slashes
+func (c Coordinate) Path() string {
+	file := c.Artifact + "-" + c.Version
+	if c.Classifier != "" {
+		file += "-" + c.Classifier
+	}
+	file += "." + c.Extension
+	return path.Join(strings.ReplaceAll(c.Group, ".", "/"), c.Artifact, c.Version, file)
+}
+
+// Key identifies an artifact regardless of version, used to drop duplicates
+func (c Coordinate) Key() string {
+	return c.Group + ":" + c.Artifact + ":" + c.Classifier + "@" + c.Extension
+}
+
+func (c Coordinate) String() string {
+	s := c.Group + ":" + c.Artifact + ":" + c.Version
+	if c.Classifier != "" {
+		s += ":" + c.Classifier
+	}
+	if c.Extension != "jar" {
+		s += "@" + c.Extension
+	}
+	return s
+}
+
+// Resolved is a classpath library with everything needed to download it
+type Resolved struct {
+	Name       string
+	Coordinate Coordinate
+	Path       string // relative to the libraries directory, forward slashes
+	URL        string
+	SHA1       string
+	Size       int64
+}
+
+// Resolve picks the libraries that belong on the classpath in env. Libraries
+// without a downloads block are resolved from their Maven name against their
+// url (or Mojang's repository). When an artifact appears twice only the
+// newest version is kept, in the position of its first occurrence.
+// Natives-only entries are left to the natives package.
+func Resolve(libs []version.Library, env version.Environment) ([]Resolved, error) {
+	var out []Resolved
+	index := map[string]int{}
+
+	for _, lib := range libs {
+		if !version.Allows(lib.Rules, env) {
+			continue
+		}
+
+		r, ok, err := resolveArtifact(lib)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		key := r.Coordinate.Key()
+		if i, seen := index[key]; seen {
+			if CompareVersions(r.Coordinate.Version, out[i].Coordinate.Version) > 0 {
+				out[i] = r
+			}
+			continue
+		}
+		index[key] = len(out)
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+func resolveArtifact(lib version.Library) (Resolved, bool, error) {
+	coord, coordErr := ParseCoordinate(lib.Name)
+
+	if lib.Downloads != nil && lib.Downloads.Artifact != nil {
+		a := lib.Downloads.Artifact
+		p := a.Path
+		if p == "" {
+			if coordErr != nil {
+				return Resolved{}, false, coordErr
+			}
+			p = coord.Path()
+		}
+		if coordErr != nil {
+			// Keep odd names usable, they just can't be deduplicated by version
+			coord = Coordinate{Group: lib.Name, Artifact: p, Extension: "jar"}
+		}
+		return Resolved{Name: lib.Name, Coordinate: coord, Path: p, URL: a.URL, SHA1: a.SHA1, Size: a.Size}, true, nil
+	}
+
+	// Old style natives entries carry only classifiers
+	if len(lib.Natives) > 0 {
+		return Resolved{}, false, nil
+	}
+	if lib.Downloads != nil && len(lib.Downloads.Classifiers) > 0 {
+		return Resolved{}, false, nil
+	}
+
+	if coordErr != nil {
+		return Resolved{}, false, coordErr
+	}
+	base := lib.URL
+	if base == "" {
+		base = MojangLibrariesURL
+	}
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	p := coord.Path()
+	return Resolved{Name: lib.Name, Coordinate: coord, Path: p, URL: base + p, SHA1: lib.SHA1, Size: lib.Size}, true, nil
+}
+
+// Files returns the download list for resolved libraries
+func Files(librariesDir string, libs []Resolved) []download.File {
+	files := make([]download.File, 0, len(libs))
+	for _, lib := range libs {
+		if lib.URL == "" {
+			continue
+		}
+		files = append(files, download.File{
+			URL:  lib.URL,
+			Path: filepath.Join(librariesDir, filepath.FromSlash(lib.Path)),
+			SHA1: lib.SHA1,
+			Size: lib.Size,
+		})
+	}
+	return files
+}
+
+// Classpath returns the absolute classpath entries followed by the client jar
+func Classpath(librariesDir string, libs []Resolved, clientJar string) []string {
+	classpath := make([]string, 0, len(libs)+1)
+	for _, lib := range libs {
+		classpath = append(classpath, filepath.Join(librariesDir, filepath.FromSlash(lib.Path)))
+	}
+	if clientJar != "" {
+		classpath = append(classpath, clientJar)
+	}
+	return classpath
+}
+
+// CompareVersions compares dotted versions segment by segment, numerically
+// where both segments are numbers. It returns -1, 0 or 1.
+func CompareVersions(a, b string) int {
+	split := func(s string) []string {
+		return strings.FieldsFunc(s, func(r rune) bool {
+			return r == '.' || r == '-' || r == '_' || r == '+'
+		})
+	}
+	as, bs := split(a), split(b)
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		// A release is newer than its pre-release: 1.0 beats 1.0-beta
+		if i >= len(as) {
+			if _, err := strconv.Atoi(bs[i]); err != nil {
+				return 1
+			}
+			return -1
+		}
+		if i >= len(bs) {
+			if _, err := strconv.Atoi(as[i]); err != nil {
+				return -1
+			}
+			return 1
+		}
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				if an < bn {
+					return -1
+				}
+				return 1
+			}
+		case aErr == nil:
+			return 1
+		case bErr == nil:
+			return -1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return 0
+}
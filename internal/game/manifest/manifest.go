@@ -0,0 +1,193 @@
+package manifest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"Nix-Client-Launcher/internal/download"
+	"Nix-Client-Launcher/internal/storage"
+)
+
+const (
+	ManifestURL = "https://piston-meta.mojang.com/mc/game/version_manifest_v2.json"
+
+	TypeRelease  = "release"
+	TypeSnapshot = "snapshot"
+	TypeOldBeta  = "old_beta"
+	TypeOldAlpha = "old_alpha"
+)
+
+type Manifest struct {
+	Latest struct {
+		Release  string `json:"release"`
+		Snapshot string `json:"snapshot"`
+	} `json:"latest"`
+	Versions []Version `json:"versions"`
+}
+
+type Version struct {
+	ID              string    `json:"id"`
+	Type            string    `json:"type"`
+	URL             string    `json:"url"`
+	Time            time.Time `json:"time"`
+	ReleaseTime     time.Time `json:"releaseTime"`
+	SHA1            string    `json:"sha1"`
+	ComplianceLevel int       `json:"complianceLevel"`
+}
+
+// cacheMeta holds the validators used to revalidate the cached manifest
+type cacheMeta struct {
+	ETag         string `json:"etag"`
+	LastModified string `json:"last_modified"`
+}
+
+// Find looks up a version by id, "release" and "snapshot" resolve to the latest of each
+func (m *Manifest) Find(id string) (*Version, bool) {
+	switch id {
+	case TypeRelease:
+		id = m.Latest.Release
+	case TypeSnapshot:
+		id = m.Latest.Snapshot
+	}
+	for i := range m.Versions {
+		if m.Versions[i].ID == id {
+			return &m.Versions[i], true
+		}
+	}
+	return nil, false
+}
+
+// ByType returns the versions of one type, newest first as listed by Mojang
+func (m *Manifest) ByType(versionType string) []Version {
+	var versions []Version
+	for _, v := range m.Versions {
+		if v.Type == versionType {
+			versions = append(versions, v)
+		}
+	}
+	return versions
+}
+
+func (m *Manifest) Releases() []Version  { return m.ByType(TypeRelease) }
+func (m *Manifest) Snapshots() []Version { return m.ByType(TypeSnapshot) }
+func (m *Manifest) OldBetas() []Version  { return m.ByType(TypeOldBeta) }
+func (m *Manifest) OldAlphas() []Version { return m.ByType(TypeOldAlpha) }
+
+// Fetch returns the version manifest, revalidating the on-disk copy with
+// If-None-Match/If-Modified-Since. The cached copy is used when Mojang is unreachable.
+func Fetch(ctx context.Context) (*Manifest, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return nil, err
+	}
+	manifestPath := filepath.Join(dir, "version_manifest_v2.json")
+	metaPath := filepath.Join(dir, "version_manifest_v2.meta.json")
+
+	cached, cacheErr := readManifest(manifestPath)
+	var meta cacheMeta
+	if cacheErr == nil {
+		if data, err := os.ReadFile(metaPath); err == nil {
+			json.Unmarshal(data, &meta)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", ManifestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", download.UserAgent)
+	if cacheErr == nil {
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+	}
+
+	resp, err := download.DefaultClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if cacheErr == nil {
+			return cached, nil
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cacheErr == nil {
+		return cached, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		if cacheErr == nil {
+			return cached, nil
+		}
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to fetch version manifest: %s - %s", resp.Status, string(body))
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse version manifest: %v", err)
+	}
+
+	// Only cache what parsed, a broken cache is worse than none
+	if err := os.WriteFile(manifestPath, data, 0644); err == nil {
+		meta = cacheMeta{
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+		}
+		if metaData, err := json.MarshalIndent(meta, "", "  "); err == nil {
+			os.WriteFile(metaPath, metaData, 0644)
+		}
+	}
+
+	return &m, nil
+}
+
+// LoadCached returns the manifest from disk without touching the network
+func LoadCached() (*Manifest, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return readManifest(filepath.Join(dir, "version_manifest_v2.json"))
+}
+
+func readManifest(path string) (*Manifest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var m Manifest
+	if err := json.NewDecoder(file).Decode(&m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func cacheDir() (string, error) {
+	configDir, err := storage.GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(configDir, "cache")
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return "", err
+	}
+	return path, nil
+}
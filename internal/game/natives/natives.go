@@ -0,0 +1,279 @@
+package natives
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"Nix-Client-Launcher/internal/download"
+	"Nix-Client-Launcher/internal/game/libraries"
+	"Nix-Client-Launcher/internal/game/version"
+)
+
+const MavenCentralURL = "https://repo1.maven.org/maven2/"
+
+// Native is a classifier jar whose shared libraries must be extracted before launch
+type Native struct {
+	Name    string
+	Path    string // relative to the libraries directory, forward slashes
+	URL     string
+	SHA1    string
+	Size    int64
+	Exclude []string
+}
+
+// Resolve returns the natives jars pre-1.19 versions declare through the
+// libraries[].natives map. Newer versions put natives on the classpath and
+// LWJGL unpacks them itself, so they produce nothing here.
+func Resolve(libs []version.Library, env version.Environment) ([]Native, error) {
+	var out []Native
+	for _, lib := range libs {
+		if len(lib.Natives) == 0 || !version.Allows(lib.Rules, env) {
+			continue
+		}
+		classifier, ok := lib.Natives[env.OS]
+		if !ok {
+			continue
+		}
+		classifier = strings.ReplaceAll(classifier, "${arch}", archBits(env.Arch))
+
+		var exclude []string
+		if lib.Extract != nil {
+			exclude = lib.Extract.Exclude
+		}
+
+		if lib.Downloads != nil {
+			if a, ok := lib.Downloads.Classifiers[classifier]; ok {
+				out = append(out, Native{Name: lib.Name, Path: a.Path, URL: a.URL, SHA1: a.SHA1, Size: a.Size, Exclude: exclude})
+				continue
+			}
+		}
+
+		// No downloads block, build the path from the Maven name
+		coord, err := libraries.ParseCoordinate(lib.Name)
+		if err != nil {
+			return nil, err
+		}
+		coord.Classifier = classifier
+		base := lib.URL
+		if base == "" {
+			base = libraries.MojangLibrariesURL
+		}
+		if !strings.HasSuffix(base, "/") {
+			base += "/"
+		}
+		out = append(out, Native{Name: coord.String(), Path: coord.Path(), URL: base + coord.Path(), Exclude: exclude})
+	}
+	return out, nil
+}
+
+// SubstituteARM rewrites LWJGL 3 natives for aarch64 Linux. Mojang only
+// publishes x86 natives, but LWJGL publishes natives-linux-arm64 builds of
+// the same version on Maven Central. LWJGL 2 has no upstream ARM builds and
+// is left untouched. Libraries are returned unchanged on other platforms.
+// The substitutes carry no checksum, PinChecksums adds it before download.
+func SubstituteARM(libs []version.Library, env version.Environment) []version.Library {
+	if env.OS != "linux" || env.Arch != "arm64" {
+		return libs
+	}
+
+	out := make([]version.Library, 0, len(libs))
+	for _, lib := range libs {
+		coord, err := libraries.ParseCoordinate(lib.Name)
+		if err != nil || coord.Group != "org.lwjgl" || !strings.HasPrefix(coord.Version, "3.") {
+			out = append(out, lib)
+			continue
+		}
+
+		switch {
+		case coord.Classifier == "natives-linux":
+			// 1.19+ style: natives are their own classpath library
+			coord.Classifier = "natives-linux-arm64"
+			out = append(out, mavenCentralLibrary(coord, lib.Rules))
+		case lib.Natives["linux"] != "":
+			// Pre-1.19 style: the java jar stays, the natives classifier moves
+			sub := lib
+			sub.Natives = map[string]string{"linux": "natives-linux-arm64"}
+			if lib.Downloads != nil {
+				downloads := *lib.Downloads
+				arm := coord
+				arm.Classifier = "natives-linux-arm64"
+				downloads.Classifiers = map[string]version.Artifact{
+					"natives-linux-arm64": {Path: arm.Path(), URL: MavenCentralURL + arm.Path()},
+				}
+				sub.Downloads = &downloads
+			} else {
+				sub.URL = MavenCentralURL
+			}
+			out = append(out, sub)
+		default:
+			out = append(out, lib)
+		}
+	}
+	return out
+}
+
+// PinChecksums fills in the SHA1 of files from Maven Central that have none,
+// which is every ARM substitute, from the .sha1 file published next to each
+// artifact. Without it any body, an error page included, would pass as a jar.
+// Files already on disk are left as they are.
+func PinChecksums(ctx context.Context, files []download.File) error {
+	return pinChecksums(ctx, download.DefaultClient, MavenCentralURL, files)
+}
+
+func pinChecksums(ctx context.Context, client *http.Client, base string, files []download.File) error {
+	for i := range files {
+		f := &files[i]
+		if f.SHA1 != "" || !strings.HasPrefix(f.URL, base) {
+			continue
+		}
+		if _, err := os.Stat(f.Path); err == nil {
+			continue
+		}
+		sum, err := fetchSHA1(ctx, client, f.URL+".sha1")
+		if err != nil {
+			return fmt.Errorf("failed to fetch the checksum of %s: %v", f.URL, err)
+		}
+		f.SHA1 = sum
+	}
+	return nil
+}
+
+// fetchSHA1 reads a Maven .sha1 file, the hash optionally followed by a name
+func fetchSHA1(ctx context.Context, client *http.Client, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", download.UserAgent)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty checksum file")
+	}
+	sum := strings.ToLower(fields[0])
+	if b, err := hex.DecodeString(sum); err != nil || len(b) != 20 {
+		return "", fmt.Errorf("invalid checksum %q", fields[0])
+	}
+	return sum, nil
+}
+
+func mavenCentralLibrary(coord libraries.Coordinate, rules []version.Rule) version.Library {
+	return version.Library{
+		Name: coord.String(),
+		Downloads: &version.LibraryDownloads{
+			Artifact: &version.Artifact{Path: coord.Path(), URL: MavenCentralURL + coord.Path()},
+		},
+		Rules: rules,
+	}
+}
+
+// Files returns the download list for natives jars
+func Files(librariesDir string, natives []Native) []download.File {
+	files := make([]download.File, 0, len(natives))
+	for _, n := range natives {
+		files = append(files, download.File{
+			URL:  n.URL,
+			Path: filepath.Join(librariesDir, filepath.FromSlash(n.Path)),
+			SHA1: n.SHA1,
+			Size: n.Size,
+		})
+	}
+	return files
+}
+
+// ExtractTemp creates a fresh directory under baseDir and extracts every
+// natives jar into it. The caller removes the directory once the game exits.
+func ExtractTemp(baseDir, librariesDir string, natives []Native) (string, error) {
+	if err := os.MkdirAll(baseDir, 0755); err != nil {
+		return "", err
+	}
+	dir, err := os.MkdirTemp(baseDir, "natives-")
+	if err != nil {
+		return "", err
+	}
+	for _, n := range natives {
+		jar := filepath.Join(librariesDir, filepath.FromSlash(n.Path))
+		if err := Extract(jar, dir, n.Exclude); err != nil {
+			os.RemoveAll(dir)
+			return "", fmt.Errorf("failed to extract %s: %v", n.Name, err)
+		}
+	}
+	return dir, nil
+}
+
+// Extract unpacks jar into dir, skipping entries under any exclude prefix
+func Extract(jar, dir string, exclude []string) error {
+	r, err := zip.OpenReader(jar)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+entries:
+	for _, f := range r.File {
+		for _, prefix := range exclude {
+			if strings.HasPrefix(f.Name, prefix) {
+				continue entries
+			}
+		}
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(f.Name))
+		if !strings.HasPrefix(target, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return fmt.Errorf("illegal path %s in jar", f.Name)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := extractFile(f, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractFile(f *zip.File, target string) error {
+	in, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func archBits(arch string) string {
+	switch arch {
+	case "x86", "arm32":
+		return "32"
+	}
+	return "64"
+}
@@ -0,0 +1,75 @@
+package natives
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"Nix-Client-Launcher/internal/download"
+)
+
+const jarSHA1 = "0123456789abcdef0123456789abcdef01234567"
+
+func TestPinChecksums(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/lwjgl.jar.sha1":
+			w.Write([]byte(strings.ToUpper(jarSHA1) + "  lwjgl.jar\n"))
+		default:
+			t.Errorf("unexpected request for %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	present := filepath.Join(dir, "present.jar")
+	if err := os.WriteFile(present, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	files := []download.File{
+		{URL: srv.URL + "/lwjgl.jar", Path: filepath.Join(dir, "lwjgl.jar")},
+		{URL: srv.URL + "/pinned.jar", Path: filepath.Join(dir, "pinned.jar"), SHA1: "known"},
+		{URL: srv.URL + "/present.jar", Path: present},
+		{URL: "https://libraries.minecraft.net/other.jar", Path: filepath.Join(dir, "other.jar")},
+	}
+	if err := pinChecksums(context.Background(), srv.Client(), srv.URL+"/", files); err != nil {
+		t.Fatalf("pinChecksums: %v", err)
+	}
+	for i, want := range []string{jarSHA1, "known", "", ""} {
+		if files[i].SHA1 != want {
+			t.Errorf("%s SHA1 = %q, want %q", files[i].URL, files[i].SHA1, want)
+		}
+	}
+}
+
+func TestPinChecksumsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"missing", "", http.StatusNotFound},
+		{"empty", "", http.StatusOK},
+		{"not a hash", "<html>error</html>", http.StatusOK},
+		{"short hash", "0123abcd", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.code)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			files := []download.File{{URL: srv.URL + "/lwjgl.jar", Path: filepath.Join(t.TempDir(), "lwjgl.jar")}}
+			if err := pinChecksums(context.Background(), srv.Client(), srv.URL+"/", files); err == nil {
+				t.Errorf("pinChecksums accepted %q, SHA1 = %q", tt.body, files[0].SHA1)
+			}
+		})
+	}
+}
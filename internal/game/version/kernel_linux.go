@@ -0,0 +1,14 @@
+package version
+
+import (
+	"os"
+	"strings"
+)
+
+func kernelVersion() string {
+	data, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
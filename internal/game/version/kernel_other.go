@@ -0,0 +1,7 @@
+//go:build !linux
+
+package version
+
+func kernelVersion() string {
+	return ""
+}
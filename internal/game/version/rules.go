@@ -0,0 +1,151 @@
+package version
+
+import (
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+type Rule struct {
+	Action   string          `json:"action"`
+	OS       *OSRule         `json:"os,omitempty"`
+	Features map[string]bool `json:"features,omitempty"`
+}
+
+type OSRule struct {
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+	Arch    string `json:"arch,omitempty"`
+}
+
+// Environment is what rules are evaluated against
+type Environment struct {
+	OS        string // Mojang OS name: linux, osx or windows
+	OSVersion string
+	Arch      string // Mojang arch name: x86, x86_64, arm64...
+	Features  map[string]bool
+}
+
+// CurrentEnvironment describes the machine the launcher runs on
+func CurrentEnvironment() Environment {
+	return Environment{
+		OS:        OSName(runtime.GOOS),
+		OSVersion: kernelVersion(),
+		Arch:      ArchName(runtime.GOARCH),
+		Features:  map[string]bool{},
+	}
+}
+
+// OSName maps a GOOS value to the name used in version JSON rules
+func OSName(goos string) string {
+	switch goos {
+	case "darwin":
+		return "osx"
+	default:
+		return goos
+	}
+}
+
+// ArchName maps a GOARCH value to the name used in version JSON rules
+func ArchName(goarch string) string {
+	switch goarch {
+	case "386":
+		return "x86"
+	case "amd64":
+		return "x86_64"
+	case "arm64":
+		return "arm64"
+	case "arm":
+		return "arm32"
+	default:
+		return goarch
+	}
+}
+
+// Allows evaluates rules the way the vanilla launcher does: with no rules
+// everything is allowed, otherwise the last matching rule decides.
+func Allows(rules []Rule, env Environment) bool {
+	if len(rules) == 0 {
+		return true
+	}
+	allowed := false
+	for _, rule := range rules {
+		if rule.matches(env) {
+			allowed = rule.Action == "allow"
+		}
+	}
+	return allowed
+}
+
+func (r Rule) matches(env Environment) bool {
+	if r.OS != nil {
+		if r.OS.Name != "" && r.OS.Name != env.OS {
+			return false
+		}
+		if r.OS.Arch != "" && !archMatches(r.OS.Arch, env.Arch) {
+			return false
+		}
+		if r.OS.Version != "" {
+			re, err := regexp.Compile(r.OS.Version)
+			if err != nil || !re.MatchString(env.OSVersion) {
+				return false
+			}
+		}
+	}
+	for feature, want := range r.Features {
+		if env.Features[feature] != want {
+			return false
+		}
+	}
+	return true
+}
+
+// archMatches treats Mojang's "x86" rule as 32-bit only and accepts the
+// common aliases for 64-bit Intel and ARM
+func archMatches(rule, arch string) bool {
+	switch rule {
+	case "x86_64", "amd64":
+		return arch == "x86_64"
+	case "arm64", "aarch64":
+		return arch == "arm64"
+	}
+	return strings.EqualFold(rule, arch)
+}
+
+// Values returns the argument values allowed in env
+func Values(args []Argument, env Environment) []string {
+	var values []string
+	for _, arg := range args {
+		if Allows(arg.Rules, env) {
+			values = append(values, arg.Value...)
+		}
+	}
+	return values
+}
+
+// GameArguments returns the game arguments for env, splitting the legacy
+// minecraftArguments string when the version predates arguments.game
+func (v *Version) GameArguments(env Environment) []string {
+	if v.Arguments != nil && len(v.Arguments.Game) > 0 {
+		return Values(v.Arguments.Game, env)
+	}
+	return strings.Fields(v.MinecraftArguments)
+}
+
+// legacyJVMArguments are the defaults the vanilla launcher used for versions
+// from before arguments.jvm
+var legacyJVMArguments = []string{
+	"-Djava.library.path=${natives_directory}",
+	"-cp",
+	"${classpath}",
+}
+
+// JVMArguments returns the JVM arguments for env. Legacy versions have none,
+// so the same defaults the vanilla launcher used are returned for them. Merge
+// adds them for children of a legacy version that bring their own.
+func (v *Version) JVMArguments(env Environment) []string {
+	if v.Arguments != nil && len(v.Arguments.JVM) > 0 {
+		return Values(v.Arguments.JVM, env)
+	}
+	return append([]string(nil), legacyJVMArguments...)
+}
@@ -0,0 +1,289 @@
+package version
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Version is a per-version client JSON as found in versions/<id>/<id>.json
+type Version struct {
+	ID                     string                   `json:"id"`
+	InheritsFrom           string                   `json:"inheritsFrom,omitempty"`
+	Type                   string                   `json:"type"`
+	MainClass              string                   `json:"mainClass"`
+	MinecraftArguments     string                   `json:"minecraftArguments,omitempty"`
+	Arguments              *Arguments               `json:"arguments,omitempty"`
+	AssetIndex             *AssetIndex              `json:"assetIndex,omitempty"`
+	Assets                 string                   `json:"assets,omitempty"`
+	Downloads              map[string]Download      `json:"downloads,omitempty"`
+	Libraries              []Library                `json:"libraries"`
+	JavaVersion            *JavaVersion             `json:"javaVersion,omitempty"`
+	Logging                map[string]LoggingConfig `json:"logging,omitempty"`
+	ComplianceLevel        int                      `json:"complianceLevel,omitempty"`
+	MinimumLauncherVersion int                      `json:"minimumLauncherVersion,omitempty"`
+	ReleaseTime            string                   `json:"releaseTime,omitempty"`
+	Time                   string                   `json:"time,omitempty"`
+}
+
+type Arguments struct {
+	Game []Argument `json:"game,omitempty"`
+	JVM  []Argument `json:"jvm,omitempty"`
+}
+
+type AssetIndex struct {
+	ID        string `json:"id"`
+	SHA1      string `json:"sha1"`
+	Size      int64  `json:"size"`
+	TotalSize int64  `json:"totalSize"`
+	URL       string `json:"url"`
+}
+
+type Download struct {
+	SHA1 string `json:"sha1"`
+	Size int64  `json:"size"`
+	URL  string `json:"url"`
+}
+
+type JavaVersion struct {
+	Component    string `json:"component"`
+	MajorVersion int    `json:"majorVersion"`
+}
+
+type LoggingConfig struct {
+	Argument string `json:"argument"`
+	File     struct {
+		ID   string `json:"id"`
+		SHA1 string `json:"sha1"`
+		Size int64  `json:"size"`
+		URL  string `json:"url"`
+	} `json:"file"`
+	Type string `json:"type"`
+}
+
+type Library struct {
+	Name      string            `json:"name"`
+	Downloads *LibraryDownloads `json:"downloads,omitempty"`
+	URL       string            `json:"url,omitempty"`
+	Rules     []Rule            `json:"rules,omitempty"`
+	Natives   map[string]string `json:"natives,omitempty"`
+	Extract   *Extract          `json:"extract,omitempty"`
+	SHA1      string            `json:"sha1,omitempty"`
+	Size      int64             `json:"size,omitempty"`
+}
+
+type LibraryDownloads struct {
+	Artifact    *Artifact           `json:"artifact,omitempty"`
+	Classifiers map[string]Artifact `json:"classifiers,omitempty"`
+}
+
+type Artifact struct {
+	Path string `json:"path"`
+	SHA1 string `json:"sha1"`
+	Size int64  `json:"size"`
+	URL  string `json:"url"`
+}
+
+type Extract struct {
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// Argument is one entry of arguments.game/arguments.jvm. Plain strings in the
+// JSON become an Argument without rules.
+type Argument struct {
+	Value []string
+	Rules []Rule
+}
+
+func (a *Argument) UnmarshalJSON(data []byte) error {
+	var plain string
+	if err := json.Unmarshal(data, &plain); err == nil {
+		a.Value = []string{plain}
+		a.Rules = nil
+		return nil
+	}
+
+	var obj struct {
+		Rules []Rule          `json:"rules"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return fmt.Errorf("invalid argument %s: %v", string(data), err)
+	}
+	a.Rules = obj.Rules
+
+	if err := json.Unmarshal(obj.Value, &plain); err == nil {
+		a.Value = []string{plain}
+		return nil
+	}
+	var values []string
+	if err := json.Unmarshal(obj.Value, &values); err != nil {
+		return fmt.Errorf("invalid argument value %s: %v", string(obj.Value), err)
+	}
+	a.Value = values
+	return nil
+}
+
+func (a Argument) MarshalJSON() ([]byte, error) {
+	if len(a.Rules) == 0 && len(a.Value) == 1 {
+		return json.Marshal(a.Value[0])
+	}
+	return json.Marshal(struct {
+		Rules []Rule   `json:"rules,omitempty"`
+		Value []string `json:"value"`
+	}{a.Rules, a.Value})
+}
+
+// Parse decodes a version JSON document
+func Parse(data []byte) (*Version, error) {
+	var v Version
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// Load reads and decodes a version JSON file
+func Load(path string) (*Version, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	v, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return v, nil
+}
+
+// Path returns versions/<id>/<id>.json inside the data directory
+func Path(dataDir, id string) string {
+	return filepath.Join(dataDir, "versions", id, id+".json")
+}
+
+// LoadInstalled loads a version from the data directory and merges its
+// inheritsFrom chain
+func LoadInstalled(dataDir, id string) (*Version, error) {
+	return Resolve(id, func(id string) (*Version, error) {
+		return Load(Path(dataDir, id))
+	})
+}
+
+// Resolve loads a version via load and merges every inheritsFrom parent into it
+func Resolve(id string, load func(id string) (*Version, error)) (*Version, error) {
+	seen := map[string]bool{}
+	var chain []*Version
+	for id != "" {
+		if seen[id] {
+			return nil, fmt.Errorf("inheritsFrom loop at %s", id)
+		}
+		seen[id] = true
+
+		v, err := load(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load version %s: %v", id, err)
+		}
+		chain = append(chain, v)
+		id = v.InheritsFrom
+	}
+
+	merged := chain[len(chain)-1]
+	for i := len(chain) - 2; i >= 0; i-- {
+		merged = Merge(merged, chain[i])
+	}
+	return merged, nil
+}
+
+// Merge layers child on top of parent. Scalars set in the child win, child
+// libraries come before the parent's so loaders can override vanilla jars, and
+// arguments are appended after the parent's, or after a legacy parent's
+// defaults.
+func Merge(parent, child *Version) *Version {
+	merged := *parent
+	merged.ID = child.ID
+	merged.InheritsFrom = ""
+
+	if child.Type != "" {
+		merged.Type = child.Type
+	}
+	if child.MainClass != "" {
+		merged.MainClass = child.MainClass
+	}
+	if child.MinecraftArguments != "" {
+		merged.MinecraftArguments = child.MinecraftArguments
+	}
+	if child.AssetIndex != nil {
+		merged.AssetIndex = child.AssetIndex
+	}
+	if child.Assets != "" {
+		merged.Assets = child.Assets
+	}
+	if child.JavaVersion != nil {
+		merged.JavaVersion = child.JavaVersion
+	}
+	if child.ReleaseTime != "" {
+		merged.ReleaseTime = child.ReleaseTime
+	}
+	if child.Time != "" {
+		merged.Time = child.Time
+	}
+	if child.ComplianceLevel != 0 {
+		merged.ComplianceLevel = child.ComplianceLevel
+	}
+	if child.MinimumLauncherVersion > merged.MinimumLauncherVersion {
+		merged.MinimumLauncherVersion = child.MinimumLauncherVersion
+	}
+
+	merged.Downloads = map[string]Download{}
+	for k, d := range parent.Downloads {
+		merged.Downloads[k] = d
+	}
+	for k, d := range child.Downloads {
+		merged.Downloads[k] = d
+	}
+
+	merged.Logging = map[string]LoggingConfig{}
+	for k, l := range parent.Logging {
+		merged.Logging[k] = l
+	}
+	for k, l := range child.Logging {
+		merged.Logging[k] = l
+	}
+
+	merged.Libraries = append(append([]Library{}, child.Libraries...), parent.Libraries...)
+
+	if parent.Arguments != nil || child.Arguments != nil {
+		args := &Arguments{}
+		if parent.Arguments != nil {
+			args.Game = append(args.Game, parent.Arguments.Game...)
+			args.JVM = append(args.JVM, parent.Arguments.JVM...)
+		} else {
+			// A legacy parent's arguments only exist as defaults, which the
+			// child's would otherwise replace instead of adding to
+			args.JVM = append(args.JVM, Argument{Value: append([]string(nil), legacyJVMArguments...)})
+			if len(child.Arguments.Game) > 0 {
+				args.Game = append(args.Game, Argument{Value: strings.Fields(merged.MinecraftArguments)})
+			}
+		}
+		if child.Arguments != nil {
+			args.Game = append(args.Game, child.Arguments.Game...)
+			args.JVM = append(args.JVM, child.Arguments.JVM...)
+		}
+		merged.Arguments = args
+	}
+
+	return &merged
+}
+
+// AssetsID returns the asset index id, falling back to the legacy assets field
+func (v *Version) AssetsID() string {
+	if v.AssetIndex != nil && v.AssetIndex.ID != "" {
+		return v.AssetIndex.ID
+	}
+	if v.Assets != "" {
+		return v.Assets
+	}
+	return "legacy"
+}
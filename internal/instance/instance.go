@@ -0,0 +1,385 @@
+package instance
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const configFile = "instance.json"
+
+// Instance is a named game profile with its own game directory and settings
+type Instance struct {
+	ID               string    `json:"id"`
+	Name             string    `json:"name"`
+	MinecraftVersion string    `json:"minecraft_version"`
+	Loader           string    `json:"loader,omitempty"` // "" for vanilla, or "fabric"
+	LoaderVersion    string    `json:"loader_version,omitempty"`
+	VersionID        string    `json:"version_id,omitempty"`    // installed profile to launch, e.g. fabric-loader-0.16.14-1.21.11
+	GameDir          string    `json:"game_dir,omitempty"`      // custom game dir, defaults to <instance>/minecraft
+	JavaPath         string    `json:"java_path,omitempty"`     // empty picks the Mojang runtime
+	MinMemoryMB      int       `json:"min_memory_mb,omitempty"` // -Xms, 0 leaves it to the JVM
+	MaxMemoryMB      int       `json:"max_memory_mb,omitempty"` // -Xmx, 0 leaves it to the JVM
+	JVMArgs          []string  `json:"jvm_args,omitempty"`
+	Width            int       `json:"width,omitempty"`
+	Height           int       `json:"height,omitempty"`
+	Icon             string    `json:"icon,omitempty"`
+	Mods             []Mod     `json:"mods,omitempty"` // mods installed from Modrinth
+	Created          time.Time `json:"created"`
+	LastPlayed       time.Time `json:"last_played,omitempty"`
+}
+
+// Clone returns a deep copy, for handing to a goroutine while the GUI keeps
+// using the original
+func (i *Instance) Clone() *Instance {
+	c := *i
+	c.JVMArgs = append([]string(nil), i.JVMArgs...)
+	c.Mods = append([]Mod(nil), i.Mods...)
+	return &c
+}
+
+// LaunchVersion returns the version id to install and launch
+func (i *Instance) LaunchVersion() string {
+	if i.VersionID != "" {
+		return i.VersionID
+	}
+	return i.MinecraftVersion
+}
+
+// Mod records which Modrinth project and version a jar in mods/ came from.
+// Pinned jars are left alone by the update checker, they may have no project
+// when the jar was added by hand.
+type Mod struct {
+	FileName  string `json:"file"`
+	ProjectID string `json:"project_id,omitempty"`
+	VersionID string `json:"version_id,omitempty"`
+	Pinned    bool   `json:"pinned,omitempty"`
+}
+
+// FindMod returns the record for a Modrinth project
+func (i *Instance) FindMod(projectID string) (*Mod, bool) {
+	for n := range i.Mods {
+		if i.Mods[n].ProjectID == projectID {
+			return &i.Mods[n], true
+		}
+	}
+	return nil, false
+}
+
+// FindModFile returns the record for a jar, named without .disabled
+func (i *Instance) FindModFile(fileName string) (*Mod, bool) {
+	for n := range i.Mods {
+		if i.Mods[n].FileName == fileName {
+			return &i.Mods[n], true
+		}
+	}
+	return nil, false
+}
+
+// SetMod adds or replaces the record for mod's project, or for its file when
+// it has no project
+func (i *Instance) SetMod(mod Mod) {
+	existing, ok := i.FindModFile(mod.FileName)
+	if mod.ProjectID != "" {
+		existing, ok = i.FindMod(mod.ProjectID)
+	}
+	if ok {
+		*existing = mod
+		return
+	}
+	i.Mods = append(i.Mods, mod)
+}
+
+// RemoveMod forgets the record for a jar, once it has been deleted
+func (i *Instance) RemoveMod(fileName string) {
+	kept := i.Mods[:0]
+	for _, m := range i.Mods {
+		if m.FileName != fileName {
+			kept = append(kept, m)
+		}
+	}
+	i.Mods = kept
+}
+
+// IsPinned reports whether updates should skip a jar
+func (i *Instance) IsPinned(fileName string) bool {
+	m, ok := i.FindModFile(fileName)
+	return ok && m.Pinned
+}
+
+// SetPinned pins or unpins a jar, named without .disabled
+func (i *Instance) SetPinned(fileName string, pinned bool) {
+	if m, ok := i.FindModFile(fileName); ok {
+		m.Pinned = pinned
+		if !pinned && m.ProjectID == "" {
+			i.RemoveMod(fileName)
+		}
+		return
+	}
+	if pinned {
+		i.Mods = append(i.Mods, Mod{FileName: fileName, Pinned: true})
+	}
+}
+
+// Store keeps instances as <Root>/<id>/instance.json
+type Store struct {
+	Root string
+}
+
+// NewStore returns the store under <dataDir>/instances
+func NewStore(dataDir string) *Store {
+	return &Store{Root: filepath.Join(dataDir, "instances")}
+}
+
+// Dir returns the directory holding an instance's config and default game dir
+func (s *Store) Dir(id string) string {
+	return filepath.Join(s.Root, id)
+}
+
+// GameDir returns the game directory the instance launches in
+func (s *Store) GameDir(inst *Instance) string {
+	if inst.GameDir != "" {
+		return inst.GameDir
+	}
+	return filepath.Join(s.Dir(inst.ID), "minecraft")
+}
+
+// List returns every instance, most recently played first
+func (s *Store) List() ([]*Instance, error) {
+	entries, err := os.ReadDir(s.Root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var instances []*Instance
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		inst, err := s.Get(e.Name())
+		if err != nil {
+			// A half deleted or hand edited instance shouldn't hide the rest
+			continue
+		}
+		instances = append(instances, inst)
+	}
+
+	sort.SliceStable(instances, func(a, b int) bool {
+		if !instances[a].LastPlayed.Equal(instances[b].LastPlayed) {
+			return instances[a].LastPlayed.After(instances[b].LastPlayed)
+		}
+		return strings.ToLower(instances[a].Name) < strings.ToLower(instances[b].Name)
+	})
+	return instances, nil
+}
+
+// Get loads one instance
+func (s *Store) Get(id string) (*Instance, error) {
+	if err := validID(id); err != nil {
+		return nil, err
+	}
+	file, err := os.Open(filepath.Join(s.Dir(id), configFile))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var inst Instance
+	if err := json.NewDecoder(file).Decode(&inst); err != nil {
+		return nil, fmt.Errorf("failed to parse instance %s: %v", id, err)
+	}
+	inst.ID = id
+	return &inst, nil
+}
+
+// Create stores a new instance, deriving a unique id from its name
+func (s *Store) Create(inst Instance) (*Instance, error) {
+	if strings.TrimSpace(inst.Name) == "" {
+		return nil, fmt.Errorf("instance name is required")
+	}
+	if inst.MinecraftVersion == "" {
+		return nil, fmt.Errorf("minecraft version is required")
+	}
+
+	id, err := s.uniqueID(inst.Name)
+	if err != nil {
+		return nil, err
+	}
+	inst.ID = id
+	if inst.Created.IsZero() {
+		inst.Created = time.Now()
+	}
+	if err := os.MkdirAll(s.GameDir(&inst), 0755); err != nil {
+		return nil, err
+	}
+	if err := s.Save(&inst); err != nil {
+		return nil, err
+	}
+	return &inst, nil
+}
+
+// Save writes an edited instance back to disk
+func (s *Store) Save(inst *Instance) error {
+	if err := validID(inst.ID); err != nil {
+		return err
+	}
+	dir := s.Dir(inst.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(inst, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write then rename so a crash never leaves a truncated instance.json
+	tmp := filepath.Join(dir, configFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, configFile))
+}
+
+// Duplicate copies an instance, including its game directory, under a new name
+func (s *Store) Duplicate(id, name string) (*Instance, error) {
+	src, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	dup := *src
+	dup.Name = name
+	dup.GameDir = ""
+	dup.Created = time.Now()
+	dup.LastPlayed = time.Time{}
+	dup.JVMArgs = append([]string(nil), src.JVMArgs...)
+	dup.Mods = append([]Mod(nil), src.Mods...)
+
+	created, err := s.Create(dup)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(s.GameDir(src)); os.IsNotExist(err) {
+		return created, nil
+	}
+	if err := copyDir(s.GameDir(src), s.GameDir(created)); err != nil {
+		s.Delete(created.ID)
+		return nil, fmt.Errorf("failed to copy game directory: %v", err)
+	}
+	if src.Icon != "" && !filepath.IsAbs(src.Icon) {
+		copyFile(filepath.Join(s.Dir(src.ID), src.Icon), filepath.Join(s.Dir(created.ID), src.Icon))
+	}
+	return created, nil
+}
+
+// Delete removes an instance and its default game directory. A custom game
+// directory is left alone, it may be shared with another launcher.
+func (s *Store) Delete(id string) error {
+	if err := validID(id); err != nil {
+		return err
+	}
+	return os.RemoveAll(s.Dir(id))
+}
+
+// MarkPlayed records that an instance was just launched
+func (s *Store) MarkPlayed(inst *Instance) error {
+	inst.LastPlayed = time.Now()
+	return s.Save(inst)
+}
+
+func (s *Store) uniqueID(name string) (string, error) {
+	base := slug(name)
+	id := base
+	for n := 2; ; n++ {
+		if _, err := os.Stat(s.Dir(id)); os.IsNotExist(err) {
+			return id, nil
+		} else if err != nil {
+			return "", err
+		}
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+// slug turns a display name into a directory-safe id
+func slug(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	id := strings.TrimRight(b.String(), "-")
+	if id == "" {
+		id = "instance"
+	}
+	return id
+}
+
+func validID(id string) error {
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return fmt.Errorf("invalid instance id %q", id)
+	}
+	return nil
+}
+
+func copyDir(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			return copyFile(path, target)
+		}
+		return nil
+	})
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,212 @@
+package java
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Installation is a Java runtime found on the system
+type Installation struct {
+	Path    string `json:"path"` // the java binary
+	Home    string `json:"home"`
+	Version string `json:"version"`
+	Major   int    `json:"major"`
+	Vendor  string `json:"vendor"`
+	Arch    string `json:"arch"`
+	Source  string `json:"source"` // where it was found: JAVA_HOME, PATH, /usr/lib/jvm...
+}
+
+// searchRoots are directories whose children are JDK/JRE homes
+func searchRoots() []struct{ dir, source string } {
+	home, _ := os.UserHomeDir()
+	roots := []struct{ dir, source string }{
+		{"/usr/lib/jvm", "/usr/lib/jvm"},
+		{"/usr/lib64/jvm", "/usr/lib64/jvm"},
+		{"/opt", "/opt"},
+		{"/opt/java", "/opt"},
+		{"/opt/jdk", "/opt"},
+	}
+	if home != "" {
+		roots = append(roots,
+			struct{ dir, source string }{filepath.Join(home, ".sdkman", "candidates", "java"), "SDKMAN"},
+		)
+	}
+	if sdkman := os.Getenv("SDKMAN_CANDIDATES_DIR"); sdkman != "" {
+		roots = append(roots, struct{ dir, source string }{filepath.Join(sdkman, "java"), "SDKMAN"})
+	}
+
+	// Flatpak OpenJDK extensions, both inside the sandbox and installed on the host
+	flatpakGlobs := []string{
+		"/usr/lib/sdk/openjdk*/jvm",
+		"/var/lib/flatpak/runtime/org.freedesktop.Sdk.Extension.openjdk*/*/*/active/files/jvm",
+	}
+	if home != "" {
+		flatpakGlobs = append(flatpakGlobs, filepath.Join(home, ".local/share/flatpak/runtime/org.freedesktop.Sdk.Extension.openjdk*/*/*/active/files/jvm"))
+	}
+	for _, pattern := range flatpakGlobs {
+		matches, _ := filepath.Glob(pattern)
+		for _, m := range matches {
+			roots = append(roots, struct{ dir, source string }{m, "Flatpak"})
+		}
+	}
+	return roots
+}
+
+// Discover scans JAVA_HOME, PATH and the usual install locations for Java
+// runtimes. Each home is probed once, even if reached through several
+// symlinks, and results are sorted newest major version first.
+func Discover() []Installation {
+	type candidate struct{ path, source string }
+	var candidates []candidate
+
+	if home := os.Getenv("JAVA_HOME"); home != "" {
+		candidates = append(candidates, candidate{filepath.Join(home, "bin", "java"), "JAVA_HOME"})
+	}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		candidates = append(candidates, candidate{filepath.Join(dir, "java"), "PATH"})
+	}
+	for _, root := range searchRoots() {
+		entries, err := os.ReadDir(root.dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			candidates = append(candidates, candidate{filepath.Join(root.dir, e.Name(), "bin", "java"), root.source})
+		}
+	}
+
+	seen := map[string]bool{}
+	var found []Installation
+	for _, c := range candidates {
+		real, err := filepath.EvalSymlinks(c.path)
+		if err != nil {
+			continue
+		}
+		if info, err := os.Stat(real); err != nil || info.IsDir() || info.Mode()&0111 == 0 {
+			continue
+		}
+		if seen[real] {
+			continue
+		}
+		seen[real] = true
+
+		inst, err := Probe(real)
+		if err != nil {
+			continue
+		}
+		inst.Source = c.source
+		found = append(found, *inst)
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].Major > found[j].Major
+	})
+	return found
+}
+
+// Probe identifies the java binary at path, reading the home's release file
+// and falling back to asking the JVM itself
+func Probe(path string) (*Installation, error) {
+	// <home>/bin/java, or <home>/jre/bin/java on old JDKs
+	home := filepath.Dir(filepath.Dir(path))
+	inst := &Installation{Path: path, Home: home}
+
+	release := parseRelease(filepath.Join(home, "release"))
+	if release == nil && filepath.Base(home) == "jre" {
+		release = parseRelease(filepath.Join(filepath.Dir(home), "release"))
+	}
+	if release != nil && release["JAVA_VERSION"] != "" {
+		inst.Version = release["JAVA_VERSION"]
+		inst.Vendor = release["IMPLEMENTOR"]
+		inst.Arch = release["OS_ARCH"]
+	} else {
+		props, err := queryProperties(path)
+		if err != nil {
+			return nil, err
+		}
+		inst.Version = props["java.version"]
+		inst.Vendor = props["java.vendor"]
+		inst.Arch = props["os.arch"]
+	}
+
+	inst.Major = MajorVersion(inst.Version)
+	if inst.Major == 0 {
+		return nil, fmt.Errorf("could not determine java version of %s", path)
+	}
+	return inst, nil
+}
+
+// parseRelease reads KEY="value" lines from a JDK release file
+func parseRelease(path string) map[string]string {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	values := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		values[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+	return values
+}
+
+// queryProperties runs java -XshowSettings:properties -version, which prints
+// "    key = value" lines to stderr
+func queryProperties(path string) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, path, "-XshowSettings:properties", "-version").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run %s: %v", path, err)
+	}
+
+	props := map[string]string{}
+	for _, line := range strings.Split(string(out), "\n") {
+		key, value, ok := strings.Cut(line, " = ")
+		if !ok {
+			continue
+		}
+		props[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return props, nil
+}
+
+// MajorVersion turns "1.8.0_392" into 8 and "21.0.2" into 21
+func MajorVersion(v string) int {
+	v = strings.TrimPrefix(v, "1.")
+	end := strings.IndexFunc(v, func(r rune) bool { return r < '0' || r > '9' })
+	if end >= 0 {
+		v = v[:end]
+	}
+	major, _ := strconv.Atoi(v)
+	return major
+}
+
+// Check compares an installation against the major version a Minecraft
+// version requires. An older Java can't load the game's classes, so that is
+// an error; a newer one usually works but breaks some older versions and
+// mods, so that is returned as a warning.
+func Check(inst *Installation, required int) (warning string, err error) {
+	if required == 0 || inst.Major == required {
+		return "", nil
+	}
+	if inst.Major < required {
+		return "", fmt.Errorf("java %d at %s is too old, this version needs java %d", inst.Major, inst.Path, required)
+	}
+	return fmt.Sprintf("java %d at %s is newer than the java %d this version expects", inst.Major, inst.Path, required), nil
+}
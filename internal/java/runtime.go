@@ -0,0 +1,230 @@
+package java
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"Nix-Client-Launcher/internal/download"
+	"Nix-Client-Launcher/internal/game/version"
+)
+
+const (
+	RuntimeManifestURL = "https://launchermeta.mojang.com/v1/products/java-runtime/2ec0cc96c44e5a76b9c8b7c39df7210883d12871/all.json"
+
+	// DefaultComponent is what versions without javaVersion (pre-1.17) ran on
+	DefaultComponent = "jre-legacy"
+)
+
+// RuntimeIndex is Mojang's all.json: platform -> component -> builds
+type RuntimeIndex map[string]map[string][]RuntimeEntry
+
+type RuntimeEntry struct {
+	Availability struct {
+		Group    int `json:"group"`
+		Progress int `json:"progress"`
+	} `json:"availability"`
+	Manifest struct {
+		SHA1 string `json:"sha1"`
+		Size int64  `json:"size"`
+		URL  string `json:"url"`
+	} `json:"manifest"`
+	Version struct {
+		Name     string `json:"name"`
+		Released string `json:"released"`
+	} `json:"version"`
+}
+
+// RuntimeManifest lists every file of one runtime build
+type RuntimeManifest struct {
+	Files map[string]RuntimeFile `json:"files"`
+}
+
+type RuntimeFile struct {
+	Type       string `json:"type"` // file, directory or link
+	Executable bool   `json:"executable,omitempty"`
+	Target     string `json:"target,omitempty"`
+	Downloads  struct {
+		Raw *struct {
+			SHA1 string `json:"sha1"`
+			Size int64  `json:"size"`
+			URL  string `json:"url"`
+		} `json:"raw,omitempty"`
+	} `json:"downloads"`
+}
+
+// Platform returns Mojang's runtime platform name for this machine
+func Platform() (string, error) {
+	switch runtime.GOOS + "/" + runtime.GOARCH {
+	case "linux/amd64":
+		return "linux", nil
+	case "linux/386":
+		return "linux-i386", nil
+	case "darwin/amd64":
+		return "mac-os", nil
+	case "darwin/arm64":
+		return "mac-os-arm64", nil
+	case "windows/amd64":
+		return "windows-x64", nil
+	case "windows/386":
+		return "windows-x86", nil
+	case "windows/arm64":
+		return "windows-arm64", nil
+	}
+	return "", fmt.Errorf("mojang does not publish java runtimes for %s/%s", runtime.GOOS, runtime.GOARCH)
+}
+
+// ComponentFor returns the runtime component a version asks for
+func ComponentFor(v *version.Version) string {
+	if v != nil && v.JavaVersion != nil && v.JavaVersion.Component != "" {
+		return v.JavaVersion.Component
+	}
+	return DefaultComponent
+}
+
+// RuntimeDir is <dataDir>/runtime/<component>
+func RuntimeDir(dataDir, component string) string {
+	return filepath.Join(dataDir, "runtime", component)
+}
+
+// RuntimeJavaPath returns the java binary of an installed runtime
+func RuntimeJavaPath(dataDir, component string) string {
+	return filepath.Join(RuntimeDir(dataDir, component), "bin", "java")
+}
+
+// RuntimeInstalled reports whether component has been installed
+func RuntimeInstalled(dataDir, component string) bool {
+	_, err := os.Stat(RuntimeJavaPath(dataDir, component))
+	return err == nil
+}
+
+// FetchRuntimeIndex downloads Mojang's runtime index
+func FetchRuntimeIndex(ctx context.Context) (RuntimeIndex, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", RuntimeManifestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", download.UserAgent)
+
+	resp, err := download.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to fetch java runtime index: %s - %s", resp.Status, string(body))
+	}
+
+	var index RuntimeIndex
+	if err := json.NewDecoder(resp.Body).Decode(&index); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// InstallRuntime installs or updates a Mojang runtime component and returns
+// its java binary. Builds are tracked by manifest hash, so an unchanged
+// runtime costs one index request; a changed one only re-downloads the files
+// whose hashes differ.
+func InstallRuntime(ctx context.Context, m *download.Manager, dataDir, component string, events chan<- download.Event) (string, error) {
+	platform, err := Platform()
+	if err != nil {
+		return "", err
+	}
+
+	index, err := FetchRuntimeIndex(ctx)
+	if err != nil {
+		// Offline with a runtime already installed is fine
+		if RuntimeInstalled(dataDir, component) {
+			return RuntimeJavaPath(dataDir, component), nil
+		}
+		return "", err
+	}
+	entries := index[platform][component]
+	if len(entries) == 0 {
+		return "", fmt.Errorf("java runtime %s is not available for %s", component, platform)
+	}
+	entry := entries[0]
+
+	dir := RuntimeDir(dataDir, component)
+	versionFile := filepath.Join(dataDir, "runtime", component+".sha1")
+	if current, err := os.ReadFile(versionFile); err == nil && strings.TrimSpace(string(current)) == entry.Manifest.SHA1 && RuntimeInstalled(dataDir, component) {
+		return RuntimeJavaPath(dataDir, component), nil
+	}
+
+	manifestPath := filepath.Join(dataDir, "runtime", component+".json")
+	manifestFile := download.File{URL: entry.Manifest.URL, Path: manifestPath, SHA1: entry.Manifest.SHA1, Size: entry.Manifest.Size}
+	if err := m.Download(ctx, []download.File{manifestFile}, nil); err != nil {
+		return "", fmt.Errorf("failed to download java runtime manifest: %v", err)
+	}
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return "", err
+	}
+	var manifest RuntimeManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return "", fmt.Errorf("failed to parse java runtime manifest: %v", err)
+	}
+
+	var files []download.File
+	var links []string
+	for name, f := range manifest.Files {
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if !strings.HasPrefix(target, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return "", fmt.Errorf("illegal path %s in java runtime manifest", name)
+		}
+		switch f.Type {
+		case "directory":
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return "", err
+			}
+		case "file":
+			if f.Downloads.Raw == nil {
+				continue
+			}
+			files = append(files, download.File{
+				URL:        f.Downloads.Raw.URL,
+				Path:       target,
+				SHA1:       f.Downloads.Raw.SHA1,
+				Size:       f.Downloads.Raw.Size,
+				Executable: f.Executable,
+			})
+		case "link":
+			links = append(links, name)
+		}
+	}
+
+	if err := m.Download(ctx, files, events); err != nil {
+		return "", fmt.Errorf("failed to download java runtime %s: %v", component, err)
+	}
+	// Files skipped as already valid keep whatever mode they had
+	for _, f := range files {
+		if f.Executable {
+			os.Chmod(f.Path, 0755)
+		}
+	}
+
+	for _, name := range links {
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return "", err
+		}
+		os.Remove(target)
+		if err := os.Symlink(manifest.Files[name].Target, target); err != nil {
+			return "", err
+		}
+	}
+
+	if err := os.WriteFile(versionFile, []byte(entry.Manifest.SHA1), 0644); err != nil {
+		return "", err
+	}
+	return RuntimeJavaPath(dataDir, component), nil
+}
@@ -0,0 +1,123 @@
+package args
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"Nix-Client-Launcher/internal/auth/microsoft"
+	"Nix-Client-Launcher/internal/storage"
+)
+
+const (
+	LauncherName    = "Nix-Client-Launcher"
+	LauncherVersion = "1.0"
+)
+
+var placeholder = regexp.MustCompile(`\$\{([A-Za-z0-9_]+)\}`)
+
+// Settings are the per-launch values that don't come from the account
+type Settings struct {
+	VersionName     string
+	VersionType     string
+	GameDir         string
+	AssetsRoot      string
+	AssetIndex      string
+	GameAssets      string // legacy virtual/resources dir, defaults to AssetsRoot
+	NativesDir      string
+	LibrariesDir    string
+	Classpath       []string
+	Width           int
+	Height          int
+	QuickPlayPath   string
+	QuickPlayWorld  string
+	QuickPlayServer string
+	QuickPlayRealms string
+}
+
+// Values maps placeholder names (without ${}) to their substitutions
+type Values map[string]string
+
+// NewValues fills every placeholder the vanilla launcher knows about from
+// account and s
+func NewValues(account *storage.AccountData, s Settings) Values {
+	gameAssets := s.GameAssets
+	if gameAssets == "" {
+		gameAssets = s.AssetsRoot
+	}
+	versionType := s.VersionType
+	if versionType == "" {
+		versionType = "release"
+	}
+
+	v := Values{
+		"version_name":          s.VersionName,
+		"version_type":          versionType,
+		"game_directory":        s.GameDir,
+		"assets_root":           s.AssetsRoot,
+		"assets_index_name":     s.AssetIndex,
+		"game_assets":           gameAssets,
+		"natives_directory":     s.NativesDir,
+		"library_directory":     s.LibrariesDir,
+		"classpath":             strings.Join(s.Classpath, string(os.PathListSeparator)),
+		"classpath_separator":   string(os.PathListSeparator),
+		"launcher_name":         LauncherName,
+		"launcher_version":      LauncherVersion,
+		"clientid":              microsoft.ClientID,
+		"user_type":             "msa",
+		"user_properties":       "{}",
+		"resolution_width":      strconv.Itoa(s.Width),
+		"resolution_height":     strconv.Itoa(s.Height),
+		"quickPlayPath":         s.QuickPlayPath,
+		"quickPlaySingleplayer": s.QuickPlayWorld,
+		"quickPlayMultiplayer":  s.QuickPlayServer,
+		"quickPlayRealms":       s.QuickPlayRealms,
+	}
+
+	if account != nil {
+		v["auth_player_name"] = account.Profile.Name
+		v["auth_uuid"] = account.Profile.ID
+		v["auth_access_token"] = account.Tokens.MinecraftAccessToken
+		v["auth_xuid"] = account.Profile.XUID
+		// Pre-1.6 clients take the session as a single token
+		v["auth_session"] = fmt.Sprintf("token:%s:%s", account.Tokens.MinecraftAccessToken, account.Profile.ID)
+	}
+	return v
+}
+
+// Expand substitutes placeholders in every argument. Unknown placeholders
+// are never passed through to the game: they are reported as warnings and
+// the argument is dropped, together with the flag before it when the
+// argument was that flag's value.
+func (v Values) Expand(in []string) (out []string, warnings []string) {
+	out = make([]string, 0, len(in))
+	for _, arg := range in {
+		var unknown []string
+		expanded := placeholder.ReplaceAllStringFunc(arg, func(m string) string {
+			name := m[2 : len(m)-1]
+			value, ok := v[name]
+			if !ok {
+				unknown = append(unknown, name)
+			}
+			return value
+		})
+
+		if len(unknown) > 0 {
+			for _, name := range unknown {
+				warnings = append(warnings, fmt.Sprintf("unknown launch placeholder ${%s} in %q", name, arg))
+			}
+			if n := len(out); n > 0 && isFlag(out[n-1]) && !isFlag(arg) {
+				out = out[:n-1]
+			}
+			continue
+		}
+		out = append(out, expanded)
+	}
+	return out, warnings
+}
+
+func isFlag(arg string) bool {
+	return strings.HasPrefix(arg, "--")
+}
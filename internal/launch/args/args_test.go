@@ -0,0 +1,268 @@
+package args
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"Nix-Client-Launcher/internal/game/version"
+	"Nix-Client-Launcher/internal/storage"
+)
+
+var testAccount = &storage.AccountData{
+	Tokens: storage.AuthTokens{MinecraftAccessToken: "mc-token"},
+	Profile: storage.MinecraftProfile{
+		ID:   "069a79f444e94726a5befca90e38aaf5",
+		Name: "Notch",
+		XUID: "2535405290120195",
+	},
+}
+
+var testSettings = Settings{
+	VersionName:  "1.21.11",
+	GameDir:      "/games/nix",
+	AssetsRoot:   "/data/assets",
+	AssetIndex:   "26",
+	NativesDir:   "/data/natives",
+	LibrariesDir: "/data/libraries",
+	Classpath:    []string{"/data/libraries/a.jar", "/data/versions/1.21.11/1.21.11.jar"},
+	Width:        1280,
+	Height:       720,
+}
+
+func TestExpandPlaceholders(t *testing.T) {
+	v := NewValues(testAccount, testSettings)
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"${auth_player_name}", "Notch"},
+		{"${auth_uuid}", "069a79f444e94726a5befca90e38aaf5"},
+		{"${auth_access_token}", "mc-token"},
+		{"${auth_xuid}", "2535405290120195"},
+		{"${auth_session}", "token:mc-token:069a79f444e94726a5befca90e38aaf5"},
+		{"${user_type}", "msa"},
+		{"${version_name}", "1.21.11"},
+		{"${version_type}", "release"},
+		{"${game_directory}", "/games/nix"},
+		{"${assets_root}", "/data/assets"},
+		{"${game_assets}", "/data/assets"},
+		{"${assets_index_name}", "26"},
+		{"-Djava.library.path=${natives_directory}", "-Djava.library.path=/data/natives"},
+		{"${classpath}", "/data/libraries/a.jar:/data/versions/1.21.11/1.21.11.jar"},
+		{"${resolution_width}x${resolution_height}", "1280x720"},
+		{"${launcher_name}/${launcher_version}", "Nix-Client-Launcher/1.0"},
+		{"no placeholders", "no placeholders"},
+		// An empty value is still a known placeholder
+		{"${quickPlayPath}", ""},
+	}
+	for _, tt := range tests {
+		out, warnings := v.Expand([]string{tt.in})
+		if len(warnings) != 0 {
+			t.Errorf("Expand(%q) warned: %v", tt.in, warnings)
+		}
+		if len(out) != 1 || out[0] != tt.want {
+			t.Errorf("Expand(%q) = %q, want %q", tt.in, out, tt.want)
+		}
+	}
+}
+
+func TestExpandWithoutAccount(t *testing.T) {
+	_, warnings := NewValues(nil, testSettings).Expand([]string{"--username", "${auth_player_name}"})
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "auth_player_name") {
+		t.Errorf("warnings = %q, want one about auth_player_name", warnings)
+	}
+}
+
+func TestExpandUnknownPlaceholder(t *testing.T) {
+	v := NewValues(testAccount, testSettings)
+
+	tests := []struct {
+		name string
+		in   []string
+		want []string
+		warn int
+	}{
+		{
+			name: "value drops its flag",
+			in:   []string{"--username", "${auth_player_name}", "--clientId", "${unknown_thing}", "--version", "${version_name}"},
+			want: []string{"--username", "Notch", "--version", "1.21.11"},
+			warn: 1,
+		},
+		{
+			name: "flag with a known value before it is kept",
+			in:   []string{"--demo", "--foo=${unknown_thing}"},
+			want: []string{"--demo"},
+			warn: 1,
+		},
+		{
+			name: "standalone argument is dropped alone",
+			in:   []string{"-Xss1M", "-Dfoo=${unknown_thing}", "-cp", "${classpath}"},
+			want: []string{"-Xss1M", "-cp", "/data/libraries/a.jar:/data/versions/1.21.11/1.21.11.jar"},
+			warn: 1,
+		},
+		{
+			name: "every unknown name is reported",
+			in:   []string{"--mix", "${one}-${two}"},
+			want: []string{},
+			warn: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, warnings := v.Expand(tt.in)
+			if !reflect.DeepEqual(out, tt.want) {
+				t.Errorf("Expand = %q, want %q", out, tt.want)
+			}
+			if len(warnings) != tt.warn {
+				t.Errorf("got %d warnings %q, want %d", len(warnings), warnings, tt.warn)
+			}
+			for _, w := range warnings {
+				if !strings.Contains(w, "unknown launch placeholder") {
+					t.Errorf("warning %q doesn't name the problem", w)
+				}
+			}
+		})
+	}
+}
+
+// A trimmed arguments block in the shape Mojang ships since 1.13
+const ruledVersion = `{
+	"id": "1.21.11",
+	"arguments": {
+		"game": [
+			"--username", "${auth_player_name}",
+			"--gameDir", "${game_directory}",
+			{"rules": [{"action": "allow", "features": {"is_demo_user": true}}], "value": "--demo"},
+			{"rules": [{"action": "allow", "features": {"has_custom_resolution": true}}],
+			 "value": ["--width", "${resolution_width}", "--height", "${resolution_height}"]},
+			{"rules": [{"action": "allow", "features": {"has_quick_plays_support": true}}],
+			 "value": ["--quickPlayPath", "${quickPlayPath}"]}
+		],
+		"jvm": [
+			{"rules": [{"action": "allow", "os": {"name": "osx"}}], "value": ["-XstartOnFirstThread"]},
+			{"rules": [{"action": "allow", "os": {"arch": "x86"}}], "value": "-Xss1M"},
+			"-Djava.library.path=${natives_directory}",
+			"-cp", "${classpath}"
+		]
+	}
+}`
+
+func TestRuleGatedArguments(t *testing.T) {
+	v, err := version.Parse([]byte(ruledVersion))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	values := NewValues(testAccount, testSettings)
+
+	tests := []struct {
+		name string
+		env  version.Environment
+		game []string
+		jvm  []string
+	}{
+		{
+			name: "plain linux",
+			env:  version.Environment{OS: "linux", Arch: "x86_64"},
+			game: []string{"--username", "Notch", "--gameDir", "/games/nix"},
+			jvm:  []string{"-Djava.library.path=/data/natives", "-cp", "/data/libraries/a.jar:/data/versions/1.21.11/1.21.11.jar"},
+		},
+		{
+			name: "custom resolution on macOS",
+			env:  version.Environment{OS: "osx", Arch: "arm64", Features: map[string]bool{"has_custom_resolution": true}},
+			game: []string{"--username", "Notch", "--gameDir", "/games/nix", "--width", "1280", "--height", "720"},
+			jvm:  []string{"-XstartOnFirstThread", "-Djava.library.path=/data/natives", "-cp", "/data/libraries/a.jar:/data/versions/1.21.11/1.21.11.jar"},
+		},
+		{
+			name: "demo user on 32-bit",
+			env:  version.Environment{OS: "linux", Arch: "x86", Features: map[string]bool{"is_demo_user": true}},
+			game: []string{"--username", "Notch", "--gameDir", "/games/nix", "--demo"},
+			jvm:  []string{"-Xss1M", "-Djava.library.path=/data/natives", "-cp", "/data/libraries/a.jar:/data/versions/1.21.11/1.21.11.jar"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			game, warnings := values.Expand(v.GameArguments(tt.env))
+			if len(warnings) != 0 {
+				t.Errorf("game warnings: %q", warnings)
+			}
+			if !reflect.DeepEqual(game, tt.game) {
+				t.Errorf("game args = %q, want %q", game, tt.game)
+			}
+			jvm, warnings := values.Expand(v.JVMArguments(tt.env))
+			if len(warnings) != 0 {
+				t.Errorf("jvm warnings: %q", warnings)
+			}
+			if !reflect.DeepEqual(jvm, tt.jvm) {
+				t.Errorf("jvm args = %q, want %q", jvm, tt.jvm)
+			}
+		})
+	}
+}
+
+func TestLegacyArguments(t *testing.T) {
+	v, err := version.Parse([]byte(`{"id": "1.6.4", "minecraftArguments": "--username ${auth_player_name} --session ${auth_session} --assetsDir ${game_assets}"}`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	settings := testSettings
+	settings.GameAssets = "/data/assets/virtual/legacy"
+
+	out, warnings := NewValues(testAccount, settings).Expand(v.GameArguments(version.Environment{OS: "linux", Arch: "x86_64"}))
+	want := []string{"--username", "Notch", "--session", "token:mc-token:069a79f444e94726a5befca90e38aaf5", "--assetsDir", "/data/assets/virtual/legacy"}
+	if len(warnings) != 0 || !reflect.DeepEqual(out, want) {
+		t.Errorf("Expand = %q (warnings %q), want %q", out, warnings, want)
+	}
+}
+
+func TestLegacyParentArguments(t *testing.T) {
+	parent, err := version.Parse([]byte(`{"id": "1.12.2", "minecraftArguments": "--username ${auth_player_name}"}`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	env := version.Environment{OS: "linux", Arch: "x86_64"}
+	values := NewValues(testAccount, testSettings)
+	legacyJVM := []string{"-Djava.library.path=/data/natives", "-cp", "/data/libraries/a.jar:/data/versions/1.21.11/1.21.11.jar"}
+
+	tests := []struct {
+		name  string
+		child string
+		game  []string
+		jvm   []string
+	}{
+		{
+			name:  "child without arguments",
+			child: `{"id": "child", "inheritsFrom": "1.12.2"}`,
+			game:  []string{"--username", "Notch"},
+			jvm:   legacyJVM,
+		},
+		{
+			name:  "child adding jvm arguments",
+			child: `{"id": "child", "inheritsFrom": "1.12.2", "arguments": {"jvm": ["-Dfoo=bar"]}}`,
+			game:  []string{"--username", "Notch"},
+			jvm:   append(append([]string(nil), legacyJVM...), "-Dfoo=bar"),
+		},
+		{
+			name:  "child adding game arguments",
+			child: `{"id": "child", "inheritsFrom": "1.12.2", "arguments": {"game": ["--tweakClass", "x"]}}`,
+			game:  []string{"--username", "Notch", "--tweakClass", "x"},
+			jvm:   legacyJVM,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			child, err := version.Parse([]byte(tt.child))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			v := version.Merge(parent, child)
+			if game, _ := values.Expand(v.GameArguments(env)); !reflect.DeepEqual(game, tt.game) {
+				t.Errorf("game args = %q, want %q", game, tt.game)
+			}
+			if jvm, _ := values.Expand(v.JVMArguments(env)); !reflect.DeepEqual(jvm, tt.jvm) {
+				t.Errorf("jvm args = %q, want %q", jvm, tt.jvm)
+			}
+		})
+	}
+}
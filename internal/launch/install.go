@@ -0,0 +1,135 @@
+package launch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"Nix-Client-Launcher/internal/download"
+	"Nix-Client-Launcher/internal/game/assets"
+	"Nix-Client-Launcher/internal/game/libraries"
+	"Nix-Client-Launcher/internal/game/manifest"
+	"Nix-Client-Launcher/internal/game/natives"
+	"Nix-Client-Launcher/internal/game/version"
+	"Nix-Client-Launcher/internal/java"
+)
+
+// Install makes sure versionID, its inheritsFrom parents, the client jar,
+// libraries, assets and the Java runtime it asks for are all present in
+// dataDir. Progress for the bulk downloads is sent to events, which may be
+// nil.
+func Install(ctx context.Context, dataDir, versionID, gameDir string, events chan<- download.Event) (*version.Version, error) {
+	m := download.NewManager()
+
+	if err := ensureVersionJSON(ctx, m, dataDir, versionID, nil); err != nil {
+		return nil, err
+	}
+	v, err := version.LoadInstalled(dataDir, versionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []download.File
+	if client, ok := v.Downloads["client"]; ok {
+		files = append(files, download.File{
+			URL:  client.URL,
+			Path: clientJarPath(dataDir, versionID),
+			SHA1: client.SHA1,
+			Size: client.Size,
+		})
+	}
+
+	env := version.CurrentEnvironment()
+	libs := natives.SubstituteARM(v.Libraries, env)
+	resolved, err := libraries.Resolve(libs, env)
+	if err != nil {
+		return nil, err
+	}
+	nativeJars, err := natives.Resolve(libs, env)
+	if err != nil {
+		return nil, err
+	}
+	librariesDir := filepath.Join(dataDir, "libraries")
+	files = append(files, libraries.Files(librariesDir, resolved)...)
+	files = append(files, natives.Files(librariesDir, nativeJars)...)
+	if err := natives.PinChecksums(ctx, files); err != nil {
+		return nil, err
+	}
+
+	if logging, ok := v.Logging["client"]; ok && logging.File.URL != "" {
+		files = append(files, download.File{
+			URL:  logging.File.URL,
+			Path: loggingConfigPath(dataDir, logging.File.ID),
+			SHA1: logging.File.SHA1,
+			Size: logging.File.Size,
+		})
+	}
+
+	if err := m.Download(ctx, files, events); err != nil {
+		return nil, fmt.Errorf("failed to download libraries: %v", err)
+	}
+
+	if _, err := assets.NewStore(dataDir).Install(ctx, m, v, gameDir, events); err != nil {
+		return nil, err
+	}
+
+	// Platforms Mojang doesn't build runtimes for fall back to a system java
+	if _, err := java.Platform(); err == nil {
+		if _, err := java.InstallRuntime(ctx, m, dataDir, java.ComponentFor(v), events); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// ensureVersionJSON downloads versions/<id>/<id>.json from the Mojang manifest
+// unless it is already present, then does the same for its parent. Loader
+// profiles are never in the manifest so they must be installed beforehand.
+func ensureVersionJSON(ctx context.Context, m *download.Manager, dataDir, id string, mf *manifest.Manifest) error {
+	path := version.Path(dataDir, id)
+	if _, err := os.Stat(path); err != nil {
+		if mf == nil {
+			var err error
+			if mf, err = manifest.Fetch(ctx); err != nil {
+				return fmt.Errorf("failed to fetch version manifest: %v", err)
+			}
+		}
+		entry, ok := mf.Find(id)
+		if !ok {
+			return fmt.Errorf("unknown Minecraft version %s", id)
+		}
+		file := download.File{URL: entry.URL, Path: path, SHA1: entry.SHA1}
+		if err := m.Download(ctx, []download.File{file}, nil); err != nil {
+			return fmt.Errorf("failed to download version %s: %v", id, err)
+		}
+	}
+
+	v, err := version.Load(path)
+	if err != nil {
+		return err
+	}
+	if v.InheritsFrom != "" {
+		return ensureVersionJSON(ctx, m, dataDir, v.InheritsFrom, mf)
+	}
+	return nil
+}
+
+// clientJarPath returns the jar of the vanilla version at the root of the
+// inheritsFrom chain, loader profiles don't ship their own
+func clientJarPath(dataDir, versionID string) string {
+	id := versionID
+	for i := 0; i < 16; i++ {
+		v, err := version.Load(version.Path(dataDir, id))
+		if err != nil || v.InheritsFrom == "" {
+			break
+		}
+		id = v.InheritsFrom
+	}
+	return filepath.Join(dataDir, "versions", id, id+".jar")
+}
+
+// loggingConfigPath is where the vanilla launcher keeps log4j configs
+func loggingConfigPath(dataDir, id string) string {
+	return filepath.Join(dataDir, "assets", "log_configs", id)
+}
@@ -0,0 +1,276 @@
+package launch
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"Nix-Client-Launcher/internal/game/assets"
+	"Nix-Client-Launcher/internal/game/libraries"
+	"Nix-Client-Launcher/internal/game/natives"
+	"Nix-Client-Launcher/internal/game/version"
+	"Nix-Client-Launcher/internal/instance"
+	"Nix-Client-Launcher/internal/java"
+	"Nix-Client-Launcher/internal/launch/args"
+	"Nix-Client-Launcher/internal/launch/process"
+	"Nix-Client-Launcher/internal/storage"
+)
+
+// Options describes everything needed to build the java command line
+type Options struct {
+	JavaPath     string
+	GameDir      string
+	AssetsDir    string
+	AssetIndex   string
+	GameAssets   string // legacy virtual or resources dir for ${game_assets}
+	NativesDir   string // per-launch natives directories are created in here
+	LibrariesDir string
+	Natives      []natives.Native
+	LoggingFile  string // log4j config passed through the version's logging argument
+	VersionID    string
+	VersionType  string
+	MainClass    string
+	Classpath    []string
+	Version      *version.Version
+	Width        int
+	Height       int
+	JVMArgs      []string // user JVM args, placed before the version's own
+	GameArgs     []string // user game args, appended after the version's own
+}
+
+// LoadInstalled reads versions/<id>/<id>.json (and its inheritsFrom parents)
+// from the data directory and fills in the Options needed to launch it in
+// gameDir with the default directory layout
+func LoadInstalled(dataDir, versionID, gameDir string) (*Options, error) {
+	v, err := version.LoadInstalled(dataDir, versionID)
+	if err != nil {
+		return nil, fmt.Errorf("version %s is not installed: %v", versionID, err)
+	}
+	if v.MainClass == "" {
+		return nil, fmt.Errorf("version %s has no main class", versionID)
+	}
+
+	env := version.CurrentEnvironment()
+	libs := natives.SubstituteARM(v.Libraries, env)
+	resolved, err := libraries.Resolve(libs, env)
+	if err != nil {
+		return nil, err
+	}
+	nativeJars, err := natives.Resolve(libs, env)
+	if err != nil {
+		return nil, err
+	}
+	librariesDir := filepath.Join(dataDir, "libraries")
+	classpath := libraries.Classpath(librariesDir, resolved, clientJarPath(dataDir, versionID))
+
+	store := assets.NewStore(dataDir)
+	// Legacy versions need the index to find their sounds and textures, so a
+	// missing one means the install is incomplete
+	idx, err := store.LoadIndex(v.AssetsID())
+	if err != nil {
+		return nil, fmt.Errorf("asset index %s is missing or damaged, reinstall version %s: %v", v.AssetsID(), versionID, err)
+	}
+
+	var loggingFile string
+	if logging, ok := v.Logging["client"]; ok && logging.File.ID != "" {
+		loggingFile = loggingConfigPath(dataDir, logging.File.ID)
+	}
+
+	javaPath := DefaultJavaPath()
+	if component := java.ComponentFor(v); java.RuntimeInstalled(dataDir, component) {
+		javaPath = java.RuntimeJavaPath(dataDir, component)
+	}
+
+	return &Options{
+		JavaPath:     javaPath,
+		GameDir:      gameDir,
+		AssetsDir:    store.Root,
+		AssetIndex:   v.AssetsID(),
+		GameAssets:   store.GameAssetsDir(v.AssetsID(), idx, gameDir),
+		NativesDir:   filepath.Join(dataDir, "natives"),
+		LibrariesDir: librariesDir,
+		Natives:      nativeJars,
+		LoggingFile:  loggingFile,
+		VersionID:    versionID,
+		VersionType:  v.Type,
+		MainClass:    v.MainClass,
+		Classpath:    classpath,
+		Version:      v,
+	}, nil
+}
+
+// ApplyInstance layers an instance's own settings over the version defaults
+func (o *Options) ApplyInstance(inst *instance.Instance) {
+	if inst.JavaPath != "" {
+		o.JavaPath = inst.JavaPath
+	}
+	if inst.MinMemoryMB > 0 {
+		o.JVMArgs = append(o.JVMArgs, fmt.Sprintf("-Xms%dM", inst.MinMemoryMB))
+	}
+	if inst.MaxMemoryMB > 0 {
+		o.JVMArgs = append(o.JVMArgs, fmt.Sprintf("-Xmx%dM", inst.MaxMemoryMB))
+	}
+	o.JVMArgs = append(o.JVMArgs, inst.JVMArgs...)
+	if inst.Width > 0 && inst.Height > 0 {
+		o.Width = inst.Width
+		o.Height = inst.Height
+	}
+}
+
+// DefaultJavaPath prefers $JAVA_HOME/bin/java and falls back to java on PATH
+func DefaultJavaPath() string {
+	if home := os.Getenv("JAVA_HOME"); home != "" {
+		path := filepath.Join(home, "bin", "java")
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return "java"
+}
+
+// defaultGameArgs is used when no version JSON is attached to the Options
+var defaultGameArgs = []string{
+	"--username", "${auth_player_name}",
+	"--version", "${version_name}",
+	"--gameDir", "${game_directory}",
+	"--assetsDir", "${assets_root}",
+	"--assetIndex", "${assets_index_name}",
+	"--uuid", "${auth_uuid}",
+	"--accessToken", "${auth_access_token}",
+	"--userType", "${user_type}",
+	"--versionType", "${version_type}",
+}
+
+// Command builds the java command for the given account without starting
+// it. Warnings about unknown placeholders in the version's arguments are
+// returned alongside the command.
+func Command(account *storage.AccountData, opts Options) (*exec.Cmd, []string, error) {
+	if account == nil || account.Tokens.MinecraftAccessToken == "" {
+		return nil, nil, fmt.Errorf("no logged in account")
+	}
+	if opts.MainClass == "" {
+		return nil, nil, fmt.Errorf("no main class set")
+	}
+	if len(opts.Classpath) == 0 {
+		return nil, nil, fmt.Errorf("classpath is empty")
+	}
+
+	javaPath := opts.JavaPath
+	if javaPath == "" {
+		javaPath = DefaultJavaPath()
+	}
+
+	env := version.CurrentEnvironment()
+	env.Features["is_demo_user"] = false
+	env.Features["has_custom_resolution"] = opts.Width > 0 && opts.Height > 0
+
+	var jvmTemplates, gameTemplates []string
+	if opts.Version != nil {
+		jvmTemplates = opts.Version.JVMArguments(env)
+		gameTemplates = opts.Version.GameArguments(env)
+		if logging, ok := opts.Version.Logging["client"]; ok && logging.Argument != "" && opts.LoggingFile != "" {
+			jvmTemplates = append(jvmTemplates, strings.ReplaceAll(logging.Argument, "${path}", opts.LoggingFile))
+		}
+	} else {
+		jvmTemplates = []string{"-Djava.library.path=${natives_directory}", "-cp", "${classpath}"}
+		gameTemplates = defaultGameArgs
+	}
+
+	values := args.NewValues(account, args.Settings{
+		VersionName:  opts.VersionID,
+		VersionType:  opts.VersionType,
+		GameDir:      opts.GameDir,
+		AssetsRoot:   opts.AssetsDir,
+		AssetIndex:   opts.AssetIndex,
+		GameAssets:   opts.GameAssets,
+		NativesDir:   opts.NativesDir,
+		LibrariesDir: opts.LibrariesDir,
+		Classpath:    opts.Classpath,
+		Width:        opts.Width,
+		Height:       opts.Height,
+	})
+	jvmArgs, warnings := values.Expand(jvmTemplates)
+	gameArgs, gameWarnings := values.Expand(gameTemplates)
+	warnings = append(warnings, gameWarnings...)
+
+	var cmdArgs []string
+	cmdArgs = append(cmdArgs, opts.JVMArgs...)
+	cmdArgs = append(cmdArgs, jvmArgs...)
+	cmdArgs = append(cmdArgs, opts.MainClass)
+	cmdArgs = append(cmdArgs, gameArgs...)
+	cmdArgs = append(cmdArgs, opts.GameArgs...)
+
+	cmd := exec.Command(javaPath, cmdArgs...)
+	cmd.Dir = opts.GameDir
+	return cmd, warnings, nil
+}
+
+// Game is a prepared Minecraft process. Subscribe to its events before
+// calling Start so no output is missed.
+type Game struct {
+	*process.Supervisor
+	Warnings   []string
+	nativesDir string
+}
+
+// Prepare creates the game directory, extracts natives into a fresh
+// directory for this launch and builds the supervised java process
+func Prepare(account *storage.AccountData, opts Options) (*Game, error) {
+	if opts.GameDir != "" {
+		if err := os.MkdirAll(opts.GameDir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	var nativesDir string
+	if opts.NativesDir != "" {
+		dir, err := natives.ExtractTemp(opts.NativesDir, opts.LibrariesDir, opts.Natives)
+		if err != nil {
+			return nil, err
+		}
+		nativesDir = dir
+		opts.NativesDir = dir
+	}
+
+	cmd, warnings, err := Command(account, opts)
+	if err != nil {
+		os.RemoveAll(nativesDir)
+		return nil, err
+	}
+	javaWarning, err := checkJava(cmd.Path, opts.Version)
+	if err != nil {
+		os.RemoveAll(nativesDir)
+		return nil, err
+	}
+	if javaWarning != "" {
+		warnings = append(warnings, javaWarning)
+	}
+	return &Game{Supervisor: process.New(cmd), Warnings: warnings, nativesDir: nativesDir}, nil
+}
+
+// Start starts the game. The natives directory is removed once it exits.
+func (g *Game) Start() error {
+	err := g.Supervisor.Start()
+	go func() {
+		<-g.Done()
+		if g.nativesDir != "" {
+			os.RemoveAll(g.nativesDir)
+		}
+	}()
+	return err
+}
+
+// checkJava refuses a java older than the version requires and warns about
+// a newer one or one that can't be identified
+func checkJava(javaPath string, v *version.Version) (string, error) {
+	if v == nil || v.JavaVersion == nil {
+		return "", nil
+	}
+	inst, err := java.Probe(javaPath)
+	if err != nil {
+		return fmt.Sprintf("could not check java version: %v", err), nil
+	}
+	return java.Check(inst, v.JavaVersion.MajorVersion)
+}
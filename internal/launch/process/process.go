@@ -0,0 +1,303 @@
+package process
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type State int
+
+const (
+	Starting State = iota
+	Running
+	Exited
+	Crashed
+)
+
+func (s State) String() string {
+	switch s {
+	case Starting:
+		return "starting"
+	case Running:
+		return "running"
+	case Exited:
+		return "exited"
+	case Crashed:
+		return "crashed"
+	}
+	return "unknown"
+}
+
+type Stream int
+
+const (
+	Stdout Stream = iota
+	Stderr
+)
+
+// Line is one line of game output
+type Line struct {
+	Stream Stream
+	Text   string
+	Time   time.Time
+}
+
+// ExitStatus records how the game ended
+type ExitStatus struct {
+	Code     int
+	Signal   string // set when the process was killed by a signal
+	Stopped  bool   // the launcher asked it to stop
+	Err      error
+	Duration time.Duration
+}
+
+// Event is delivered to subscribers, either a state change or an output line
+type Event struct {
+	State State
+	Line  *Line
+	Exit  *ExitStatus
+}
+
+// tailSize is how many output lines are kept for crash reports
+const tailSize = 200
+
+// drainTimeout bounds how long output is still read once java has exited
+var drainTimeout = 2 * time.Second
+
+// Supervisor owns a child java process: it captures output line by line,
+// tracks its state and records how it exited
+type Supervisor struct {
+	cmd *exec.Cmd
+
+	mu          sync.Mutex
+	state       State
+	exit        *ExitStatus
+	stopping    bool
+	tail        []Line
+	subscribers map[int]func(Event)
+	nextID      int
+	started     time.Time
+	done        chan struct{}
+}
+
+// New wraps cmd, which must not have been started and must not have its
+// Stdout or Stderr set
+func New(cmd *exec.Cmd) *Supervisor {
+	return &Supervisor{
+		cmd:         cmd,
+		state:       Starting,
+		subscribers: map[int]func(Event){},
+		done:        make(chan struct{}),
+	}
+}
+
+// Subscribe registers f for every event from now on. f is called from the
+// supervisor's goroutines and must not block. The returned func unsubscribes.
+func (s *Supervisor) Subscribe(f func(Event)) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.nextID
+	s.nextID++
+	s.subscribers[id] = f
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.subscribers, id)
+	}
+}
+
+// Start launches the process and begins capturing its output
+func (s *Supervisor) Start() error {
+	// The pipes are made here rather than with StdoutPipe so Wait returns
+	// when java exits, not when every copy of the write ends is closed.
+	// Every failure has to finish, or Wait would block forever.
+	stdout, stdoutW, err := os.Pipe()
+	if err != nil {
+		s.finish(Crashed, &ExitStatus{Code: -1, Err: err})
+		return err
+	}
+	stderr, stderrW, err := os.Pipe()
+	if err != nil {
+		stdout.Close()
+		stdoutW.Close()
+		s.finish(Crashed, &ExitStatus{Code: -1, Err: err})
+		return err
+	}
+	s.cmd.Stdout = stdoutW
+	s.cmd.Stderr = stderrW
+
+	s.publish(Event{State: Starting})
+	err = s.cmd.Start()
+	// The child has its own copies of the write ends now
+	stdoutW.Close()
+	stderrW.Close()
+	if err != nil {
+		stdout.Close()
+		stderr.Close()
+		status := &ExitStatus{Code: -1, Err: err}
+		s.finish(Crashed, status)
+		return fmt.Errorf("failed to start java: %v", err)
+	}
+
+	s.mu.Lock()
+	s.started = time.Now()
+	s.mu.Unlock()
+	s.setState(Running, nil)
+
+	var readers sync.WaitGroup
+	readers.Add(2)
+	go s.capture(stdout, Stdout, &readers)
+	go s.capture(stderr, Stderr, &readers)
+	drained := make(chan struct{})
+	go func() {
+		readers.Wait()
+		close(drained)
+	}()
+
+	go func() {
+		err := s.cmd.Wait()
+		// Anything the game started, a crash reporter say, may hold the
+		// pipes open long after java is gone. What is already buffered gets
+		// a moment to drain, then the pipes are closed under it.
+		select {
+		case <-drained:
+		case <-time.After(drainTimeout):
+		}
+		stdout.Close()
+		stderr.Close()
+		<-drained
+		s.finish(s.classify(err))
+	}()
+	return nil
+}
+
+func (s *Supervisor) capture(r io.Reader, stream Stream, wg *sync.WaitGroup) {
+	defer wg.Done()
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := Line{Stream: stream, Text: scanner.Text(), Time: time.Now()}
+		s.mu.Lock()
+		s.tail = append(s.tail, line)
+		if len(s.tail) > tailSize {
+			s.tail = s.tail[len(s.tail)-tailSize:]
+		}
+		s.mu.Unlock()
+		s.publish(Event{State: Running, Line: &line})
+	}
+}
+
+func (s *Supervisor) classify(err error) (State, *ExitStatus) {
+	s.mu.Lock()
+	status := &ExitStatus{Stopped: s.stopping, Duration: time.Since(s.started)}
+	s.mu.Unlock()
+
+	if ps := s.cmd.ProcessState; ps != nil {
+		status.Code = ps.ExitCode()
+		if ws, ok := ps.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			status.Signal = ws.Signal().String()
+		}
+	}
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			status.Err = err
+		}
+	}
+
+	if status.Stopped || (status.Code == 0 && status.Signal == "" && status.Err == nil) {
+		return Exited, status
+	}
+	return Crashed, status
+}
+
+func (s *Supervisor) finish(state State, status *ExitStatus) {
+	s.setState(state, status)
+	close(s.done)
+}
+
+func (s *Supervisor) setState(state State, status *ExitStatus) {
+	s.mu.Lock()
+	s.state = state
+	if status != nil {
+		s.exit = status
+	}
+	s.mu.Unlock()
+	s.publish(Event{State: state, Exit: status})
+}
+
+func (s *Supervisor) publish(ev Event) {
+	s.mu.Lock()
+	subs := make([]func(Event), 0, len(s.subscribers))
+	for _, f := range s.subscribers {
+		subs = append(subs, f)
+	}
+	s.mu.Unlock()
+	for _, f := range subs {
+		f(ev)
+	}
+}
+
+// State returns the current state
+func (s *Supervisor) State() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// Tail returns the last lines of output, useful for crash reports
+func (s *Supervisor) Tail() []Line {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Line(nil), s.tail...)
+}
+
+// Wait blocks until the process has exited and returns how it ended
+func (s *Supervisor) Wait() *ExitStatus {
+	<-s.done
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.exit
+}
+
+// Done is closed once the process has exited
+func (s *Supervisor) Done() <-chan struct{} {
+	return s.done
+}
+
+// Stop asks the game to quit with SIGTERM, which lets Minecraft save the
+// world, and kills it if it is still running after timeout
+func (s *Supervisor) Stop(timeout time.Duration) error {
+	if s.State() != Running {
+		return nil
+	}
+	s.mu.Lock()
+	s.stopping = true
+	s.mu.Unlock()
+
+	if err := s.cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		return s.Kill()
+	}
+	select {
+	case <-s.done:
+		return nil
+	case <-time.After(timeout):
+		return s.Kill()
+	}
+}
+
+// Kill terminates the game immediately
+func (s *Supervisor) Kill() error {
+	if s.State() != Running {
+		return nil
+	}
+	s.mu.Lock()
+	s.stopping = true
+	s.mu.Unlock()
+	return s.cmd.Process.Kill()
+}
@@ -0,0 +1,239 @@
+package process
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestHelperProcess is the child the tests supervise, not a test itself
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	args := os.Args
+	for len(args) > 0 && args[0] != "--" {
+		args = args[1:]
+	}
+	switch args[1] {
+	case "exit":
+		fmt.Println("to stdout")
+		fmt.Fprintln(os.Stderr, "to stderr")
+		code, _ := strconv.Atoi(args[2])
+		os.Exit(code)
+	case "lines":
+		n, _ := strconv.Atoi(args[2])
+		for i := 0; i < n; i++ {
+			fmt.Printf("line %d\n", i)
+		}
+		os.Exit(0)
+	case "signal":
+		syscall.Kill(os.Getpid(), syscall.SIGKILL)
+	case "sleep":
+		time.Sleep(time.Minute)
+		os.Exit(0)
+	case "daemon":
+		// Leaves a child behind that holds stdout and stderr open
+		daemon := helperCommand("sleep")
+		daemon.Stdout = os.Stdout
+		daemon.Stderr = os.Stderr
+		if err := daemon.Start(); err != nil {
+			os.Exit(2)
+		}
+		fmt.Printf("daemon %d\n", daemon.Process.Pid)
+		os.Exit(0)
+	}
+	os.Exit(2)
+}
+
+func helperCommand(args ...string) *exec.Cmd {
+	cmd := exec.Command(os.Args[0], append([]string{"-test.run=^TestHelperProcess$", "--"}, args...)...)
+	cmd.Env = append(os.Environ(), "GO_WANT_HELPER_PROCESS=1")
+	return cmd
+}
+
+// states records the state changes a supervisor publishes
+type states struct {
+	mu   sync.Mutex
+	seen []State
+}
+
+func (st *states) record(ev Event) {
+	if ev.Line != nil {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.seen = append(st.seen, ev.State)
+}
+
+func (st *states) String() string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var names []string
+	for _, s := range st.seen {
+		names = append(names, s.String())
+	}
+	return strings.Join(names, " ")
+}
+
+// wait is Supervisor.Wait with a deadline so a hang fails the test
+func wait(t *testing.T, s *Supervisor) *ExitStatus {
+	t.Helper()
+	select {
+	case <-s.Done():
+		return s.Wait()
+	case <-time.After(10 * time.Second):
+		s.Kill()
+		t.Fatal("the process didn't finish")
+		return nil
+	}
+}
+
+func TestSupervisor(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		stop    func(s *Supervisor)
+		states  string
+		code    int
+		signal  string
+		stopped bool
+	}{
+		{name: "clean exit", args: []string{"exit", "0"}, states: "starting running exited"},
+		{name: "exit code", args: []string{"exit", "3"}, states: "starting running crashed", code: 3},
+		{name: "killed by a signal", args: []string{"signal"}, states: "starting running crashed", code: -1, signal: "killed"},
+		{
+			name:   "stopped",
+			args:   []string{"sleep"},
+			stop:   func(s *Supervisor) { s.Stop(5 * time.Second) },
+			states: "starting running exited", code: -1, signal: "terminated", stopped: true,
+		},
+		{
+			name:   "killed",
+			args:   []string{"sleep"},
+			stop:   func(s *Supervisor) { s.Kill() },
+			states: "starting running exited", code: -1, signal: "killed", stopped: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(helperCommand(tt.args...))
+			st := &states{}
+			s.Subscribe(st.record)
+			if err := s.Start(); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+			if tt.stop != nil {
+				tt.stop(s)
+			}
+			exit := wait(t, s)
+
+			if got := st.String(); got != tt.states {
+				t.Errorf("states = %s, want %s", got, tt.states)
+			}
+			if exit.Code != tt.code || exit.Signal != tt.signal || exit.Stopped != tt.stopped || exit.Err != nil {
+				t.Errorf("exit = %+v, want code %d, signal %q, stopped %v", exit, tt.code, tt.signal, tt.stopped)
+			}
+			if exit.Duration <= 0 {
+				t.Errorf("duration = %v", exit.Duration)
+			}
+		})
+	}
+}
+
+func TestSupervisorStartFailure(t *testing.T) {
+	s := New(exec.Command("/nonexistent/java"))
+	st := &states{}
+	s.Subscribe(st.record)
+	if err := s.Start(); err == nil {
+		t.Fatal("Start succeeded without a binary")
+	}
+	exit := wait(t, s)
+	if s.State() != Crashed || exit.Code != -1 || exit.Err == nil {
+		t.Errorf("state %s, exit %+v, want a crash with the start error", s.State(), exit)
+	}
+	if got := st.String(); got != "starting crashed" {
+		t.Errorf("states = %s, want starting crashed", got)
+	}
+}
+
+func TestSupervisorOutput(t *testing.T) {
+	s := New(helperCommand("exit", "0"))
+	var mu sync.Mutex
+	var published []Line
+	s.Subscribe(func(ev Event) {
+		if ev.Line != nil {
+			mu.Lock()
+			published = append(published, *ev.Line)
+			mu.Unlock()
+		}
+	})
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	wait(t, s)
+
+	want := map[string]Stream{"to stdout": Stdout, "to stderr": Stderr}
+	tail := s.Tail()
+	if len(tail) != len(want) {
+		t.Fatalf("tail = %+v, want %d lines", tail, len(want))
+	}
+	for _, line := range tail {
+		if stream, ok := want[line.Text]; !ok || stream != line.Stream {
+			t.Errorf("line %q on stream %d", line.Text, line.Stream)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(published) != len(tail) {
+		t.Errorf("%d lines published, %d in the tail", len(published), len(tail))
+	}
+}
+
+func TestSupervisorTailIsBounded(t *testing.T) {
+	s := New(helperCommand("lines", strconv.Itoa(tailSize+100)))
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	wait(t, s)
+
+	tail := s.Tail()
+	if len(tail) != tailSize {
+		t.Fatalf("tail has %d lines, want %d", len(tail), tailSize)
+	}
+	if tail[0].Text != "line 100" || tail[len(tail)-1].Text != fmt.Sprintf("line %d", tailSize+99) {
+		t.Errorf("tail runs from %q to %q", tail[0].Text, tail[len(tail)-1].Text)
+	}
+}
+
+func TestSupervisorDaemonHoldingPipes(t *testing.T) {
+	old := drainTimeout
+	drainTimeout = 100 * time.Millisecond
+	defer func() { drainTimeout = old }()
+
+	s := New(helperCommand("daemon"))
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	exit := wait(t, s)
+
+	tail := s.Tail()
+	if len(tail) == 1 {
+		if pid, err := strconv.Atoi(strings.TrimPrefix(tail[0].Text, "daemon ")); err == nil {
+			syscall.Kill(pid, syscall.SIGKILL)
+		}
+	}
+	if s.State() != Exited || exit.Code != 0 {
+		t.Errorf("state %s, exit %+v, want a clean exit", s.State(), exit)
+	}
+	if len(tail) != 1 || !strings.HasPrefix(tail[0].Text, "daemon ") {
+		t.Errorf("tail = %+v, want the line printed before exiting", tail)
+	}
+}
@@ -0,0 +1,171 @@
+package fabric
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"Nix-Client-Launcher/internal/download"
+	"Nix-Client-Launcher/internal/game/libraries"
+	"Nix-Client-Launcher/internal/game/version"
+)
+
+const MetaURL = "https://meta.fabricmc.net/v2"
+
+type LoaderVersion struct {
+	Loader struct {
+		Separator string `json:"separator"`
+		Build     int    `json:"build"`
+		Maven     string `json:"maven"`
+		Version   string `json:"version"`
+		Stable    bool   `json:"stable"`
+	} `json:"loader"`
+	Intermediary struct {
+		Maven   string `json:"maven"`
+		Version string `json:"version"`
+		Stable  bool   `json:"stable"`
+	} `json:"intermediary"`
+}
+
+// Client talks to Fabric Meta. BaseURL can point at a mirror or a local stand-in.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+func NewClient() *Client {
+	return &Client{BaseURL: MetaURL, HTTPClient: download.DefaultClient}
+}
+
+// LoaderVersions lists the loader builds compatible with a Minecraft version,
+// newest first as returned by Fabric Meta
+func (c *Client) LoaderVersions(ctx context.Context, minecraftVersion string) ([]LoaderVersion, error) {
+	var versions []LoaderVersion
+	if err := c.get(ctx, "/versions/loader/"+url.PathEscape(minecraftVersion), &versions); err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("fabric does not support Minecraft %s", minecraftVersion)
+	}
+	return versions, nil
+}
+
+// LatestStable returns the newest stable loader for a Minecraft version,
+// or the newest build at all if none is marked stable
+func (c *Client) LatestStable(ctx context.Context, minecraftVersion string) (string, error) {
+	versions, err := c.LoaderVersions(ctx, minecraftVersion)
+	if err != nil {
+		return "", err
+	}
+	for _, v := range versions {
+		if v.Loader.Stable {
+			return v.Loader.Version, nil
+		}
+	}
+	return versions[0].Loader.Version, nil
+}
+
+// Profile fetches the launcher profile JSON, which inherits from vanilla
+func (c *Client) Profile(ctx context.Context, minecraftVersion, loaderVersion string) (*version.Version, []byte, error) {
+	path := fmt.Sprintf("/versions/loader/%s/%s/profile/json", url.PathEscape(minecraftVersion), url.PathEscape(loaderVersion))
+	var raw json.RawMessage
+	if err := c.get(ctx, path, &raw); err != nil {
+		return nil, nil, err
+	}
+	v, err := version.Parse(raw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse fabric profile: %v", err)
+	}
+	if v.InheritsFrom == "" {
+		return nil, nil, fmt.Errorf("fabric profile %s does not inherit from a vanilla version", v.ID)
+	}
+	// Both name directories under versions/
+	for _, id := range []string{v.ID, v.InheritsFrom} {
+		if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+			return nil, nil, fmt.Errorf("invalid fabric profile id %q", id)
+		}
+	}
+	return v, raw, nil
+}
+
+// Install writes the Fabric profile for minecraftVersion into
+// versions/<id>/<id>.json and downloads the loader libraries. An empty
+// loaderVersion picks the latest stable loader. The vanilla parent is left to
+// the regular version install. It returns the profile's version id.
+func (c *Client) Install(ctx context.Context, m *download.Manager, dataDir, minecraftVersion, loaderVersion string, events chan<- download.Event) (string, error) {
+	if loaderVersion == "" {
+		latest, err := c.LatestStable(ctx, minecraftVersion)
+		if err != nil {
+			return "", err
+		}
+		loaderVersion = latest
+	}
+
+	profile, raw, err := c.Profile(ctx, minecraftVersion, loaderVersion)
+	if err != nil {
+		return "", err
+	}
+
+	path := version.Path(dataDir, profile.ID)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, raw, 0644); err != nil {
+		return "", err
+	}
+
+	libs, err := libraries.Resolve(profile.Libraries, version.CurrentEnvironment())
+	if err != nil {
+		return "", err
+	}
+	if err := m.Download(ctx, libraries.Files(filepath.Join(dataDir, "libraries"), libs), events); err != nil {
+		return "", fmt.Errorf("failed to download fabric libraries: %v", err)
+	}
+	return profile.ID, nil
+}
+
+// LoaderFromProfileID returns the loader version in a profile id such as
+// fabric-loader-0.16.14-1.21.11, or "" if id isn't a Fabric profile for
+// minecraftVersion
+func LoaderFromProfileID(id, minecraftVersion string) string {
+	rest, ok := strings.CutPrefix(id, "fabric-loader-")
+	if !ok {
+		return ""
+	}
+	loader, ok := strings.CutSuffix(rest, "-"+minecraftVersion)
+	if !ok {
+		return ""
+	}
+	return loader
+}
+
+func (c *Client) get(ctx context.Context, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.BaseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", download.UserAgent)
+
+	client := c.HTTPClient
+	if client == nil {
+		client = download.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("fabric meta request failed: %s - %s", resp.Status, string(body))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,32 @@
+package fabric
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"Nix-Client-Launcher/internal/download"
+)
+
+func TestInstallRejectsBadProfileIDs(t *testing.T) {
+	for _, id := range []string{"", ".", "..", "../../escape", `..\escape`, "fabric/loader"} {
+		t.Run(id, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "inheritsFrom": "1.21.11", "libraries": []string{}})
+			}))
+			defer srv.Close()
+
+			dataDir := t.TempDir()
+			c := &Client{BaseURL: srv.URL, HTTPClient: srv.Client()}
+			if _, err := c.Install(context.Background(), download.NewManager(), dataDir, "1.21.11", "0.17.2", nil); err == nil {
+				t.Fatal("Install accepted the profile")
+			}
+			if entries, _ := os.ReadDir(dataDir); len(entries) != 0 {
+				t.Errorf("Install wrote %v", entries)
+			}
+		})
+	}
+}
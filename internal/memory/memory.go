@@ -0,0 +1,80 @@
+package memory
+
+import (
+	"fmt"
+)
+
+// Info is what the system has to give a JVM, in MiB. LimitMB is the cgroup
+// memory limit the launcher runs under, 0 when there is none.
+type Info struct {
+	TotalMB     int
+	AvailableMB int
+	LimitMB     int
+}
+
+// Read returns the system memory, or an error where it can't be determined
+func Read() (*Info, error) {
+	return read()
+}
+
+// UsableMB is the most memory the game can actually get, the smaller of
+// physical RAM and the cgroup limit
+func (i *Info) UsableMB() int {
+	if i.LimitMB > 0 && i.LimitMB < i.TotalMB {
+		return i.LimitMB
+	}
+	return i.TotalMB
+}
+
+// DefaultHeapMB is the max heap a JVM picks when no -Xmx is given, a quarter
+// of the memory it can see
+func (i *Info) DefaultHeapMB() int {
+	return i.UsableMB() / 4
+}
+
+// Validate rejects -Xms/-Xmx settings the JVM would refuse to start with.
+// Zero means unset.
+func Validate(minMB, maxMB int) error {
+	if minMB < 0 || maxMB < 0 {
+		return fmt.Errorf("memory settings can't be negative")
+	}
+	if maxMB > 0 && maxMB < 128 {
+		return fmt.Errorf("maximum memory of %d MB is too small to run Minecraft", maxMB)
+	}
+	if minMB > 0 && maxMB > 0 && minMB > maxMB {
+		return fmt.Errorf("minimum memory (%d MB) is larger than maximum memory (%d MB)", minMB, maxMB)
+	}
+	return nil
+}
+
+// Check returns warnings for giving a new game maxMB of heap while games
+// using runningMB between them are already running. A zero maxMB is taken to
+// be the JVM default.
+func (i *Info) Check(maxMB int, runningMB []int) []string {
+	var warnings []string
+	if maxMB == 0 {
+		maxMB = i.DefaultHeapMB()
+	}
+
+	if i.LimitMB > 0 && i.LimitMB < i.TotalMB && maxMB > i.LimitMB {
+		warnings = append(warnings, fmt.Sprintf("%d MB is more than the %d MB memory limit the launcher runs under", maxMB, i.LimitMB))
+	} else if maxMB > i.TotalMB {
+		warnings = append(warnings, fmt.Sprintf("%d MB is more than the %d MB of RAM in this computer", maxMB, i.TotalMB))
+	} else if len(runningMB) == 0 && i.AvailableMB > 0 && maxMB > i.AvailableMB {
+		warnings = append(warnings, fmt.Sprintf("%d MB is more than the %d MB of memory currently available", maxMB, i.AvailableMB))
+	}
+
+	if len(runningMB) > 0 {
+		total := maxMB
+		for _, mb := range runningMB {
+			if mb == 0 {
+				mb = i.DefaultHeapMB()
+			}
+			total += mb
+		}
+		if total > i.UsableMB() {
+			warnings = append(warnings, fmt.Sprintf("this game and the %d already running may use %d MB between them, more than the %d MB available", len(runningMB), total, i.UsableMB()))
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,114 @@
+package memory
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// cgroup v1 reports "no limit" as a page aligned number close to MaxInt64
+const unlimited = 1 << 60
+
+func read() (*Info, error) {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var info Info
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		kb, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			info.TotalMB = kb / 1024
+		case "MemAvailable:":
+			info.AvailableMB = kb / 1024
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if info.TotalMB == 0 {
+		return nil, fmt.Errorf("no MemTotal in /proc/meminfo")
+	}
+	info.LimitMB = cgroupLimitMB()
+	return &info, nil
+}
+
+// cgroupLimitMB returns the tightest memory limit on this process's cgroup
+// and its parents, for both cgroup v2 and v1, or 0 when unlimited
+func cgroupLimitMB() int {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return 0
+	}
+
+	var candidates []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		switch {
+		case parts[0] == "0" && parts[1] == "":
+			candidates = append(candidates, parentFiles("/sys/fs/cgroup", parts[2], "memory.max")...)
+		case hasController(parts[1], "memory"):
+			candidates = append(candidates, parentFiles("/sys/fs/cgroup/memory", parts[2], "memory.limit_in_bytes")...)
+		}
+	}
+
+	limit := 0
+	for _, path := range candidates {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		value := strings.TrimSpace(string(data))
+		if value == "max" {
+			continue
+		}
+		bytes, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || bytes <= 0 || bytes >= unlimited {
+			continue
+		}
+		if mb := int(bytes / (1024 * 1024)); limit == 0 || mb < limit {
+			limit = mb
+		}
+	}
+	return limit
+}
+
+// parentFiles lists name in the cgroup directory and each of its parents up
+// to root. Inside a container the path may not exist, root is still tried.
+func parentFiles(root, cgroup, name string) []string {
+	var files []string
+	dir := filepath.Clean("/" + cgroup)
+	for {
+		files = append(files, filepath.Join(root, dir, name))
+		if dir == "/" {
+			return files
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+func hasController(list, name string) bool {
+	for _, c := range strings.Split(list, ",") {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package memory
+
+import "fmt"
+
+func read() (*Info, error) {
+	return nil, fmt.Errorf("reading system memory is only supported on Linux")
+}
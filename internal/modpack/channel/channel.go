@@ -0,0 +1,273 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"Nix-Client-Launcher/internal/download"
+	"Nix-Client-Launcher/internal/loader/fabric"
+	"Nix-Client-Launcher/internal/modpack/mrpack"
+)
+
+const (
+	// DefaultURL serves the same read/version.json that ships with the launcher
+	DefaultURL = "https://raw.githubusercontent.com/jaynetblacemen/Nix-Client-Launcher/main/read/version.json"
+
+	// URLEnv overrides DefaultURL, handy for testing unreleased packs
+	URLEnv = "NIX_CLIENT_CHANNEL_URL"
+
+	markerFile = ".nix-modpack.json"
+)
+
+// Manifest is the modpack channel, read/version.json
+type Manifest struct {
+	Modpacks []Modpack `json:"modpacks"`
+}
+
+type Modpack struct {
+	Version   string `json:"version"`
+	Minecraft string `json:"minecraft"`
+	Loader    string `json:"loader"`
+	URL       string `json:"url"`
+	SHA1      string `json:"sha1,omitempty"`
+	SHA512    string `json:"sha512,omitempty"`
+	Size      int64  `json:"size,omitempty"`
+}
+
+// hasHash reports whether the channel gave a hash to check the download with
+func (p *Modpack) hasHash() bool {
+	return p.SHA1 != "" || p.SHA512 != ""
+}
+
+// Find returns the pack published for a minecraft/loader pair. When several
+// are listed the first one wins, the channel lists newest first.
+func (m *Manifest) Find(minecraft, loader string) (*Modpack, bool) {
+	for i := range m.Modpacks {
+		if m.Modpacks[i].Minecraft == minecraft && m.Modpacks[i].Loader == loader {
+			return &m.Modpacks[i], true
+		}
+	}
+	return nil, false
+}
+
+// Channel fetches the manifest from URL, falling back to the copy bundled
+// with the launcher at BundledPath when offline
+type Channel struct {
+	URL         string
+	BundledPath string
+	HTTPClient  *http.Client
+}
+
+// New returns a Channel for the default URL, or $NIX_CLIENT_CHANNEL_URL if set
+func New(bundledPath string) *Channel {
+	u := os.Getenv(URLEnv)
+	if u == "" {
+		u = DefaultURL
+	}
+	return &Channel{URL: u, BundledPath: bundledPath, HTTPClient: download.DefaultClient}
+}
+
+// Fetch returns the remote manifest, or the bundled one if that fails
+func (c *Channel) Fetch(ctx context.Context) (*Manifest, error) {
+	m, err := c.fetchRemote(ctx)
+	if err == nil {
+		return m, nil
+	}
+	if c.BundledPath == "" {
+		return nil, err
+	}
+	bundled, bundledErr := LoadFile(c.BundledPath)
+	if bundledErr != nil {
+		return nil, fmt.Errorf("failed to fetch modpack channel (%v) and bundled copy (%v)", err, bundledErr)
+	}
+	return bundled, nil
+}
+
+func (c *Channel) fetchRemote(ctx context.Context) (*Manifest, error) {
+	if c.URL == "" {
+		return nil, fmt.Errorf("no modpack channel url")
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", c.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", download.UserAgent)
+
+	client := c.HTTPClient
+	if client == nil {
+		client = download.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to fetch modpack channel: %s - %s", resp.Status, string(body))
+	}
+
+	var m Manifest
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		return nil, fmt.Errorf("failed to parse modpack channel: %v", err)
+	}
+	return &m, nil
+}
+
+// LoadFile reads a manifest from disk
+func LoadFile(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return &m, nil
+}
+
+// Installed records which pack is in a game directory, written next to the
+// pack's files as .nix-modpack.json
+type Installed struct {
+	Modpack
+	VersionID string   `json:"version_id"` // loader profile to launch
+	Files     []string `json:"files"`      // pack files, removed again on update
+}
+
+// LoadInstalled returns the pack installed in gameDir, or nil if there is none
+func LoadInstalled(gameDir string) (*Installed, error) {
+	data, err := os.ReadFile(filepath.Join(gameDir, markerFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var inst Installed
+	if err := json.Unmarshal(data, &inst); err != nil {
+		return nil, err
+	}
+	return &inst, nil
+}
+
+type Status int
+
+const (
+	NotInstalled Status = iota
+	UpToDate
+	UpdateAvailable
+	Unavailable // the channel has no pack for this minecraft/loader pair
+)
+
+// Check compares what is installed in gameDir with the channel
+func Check(m *Manifest, gameDir, minecraft, loader string) (Status, *Modpack, error) {
+	pack, ok := m.Find(minecraft, loader)
+	if !ok {
+		return Unavailable, nil, nil
+	}
+	inst, err := LoadInstalled(gameDir)
+	if err != nil {
+		return NotInstalled, pack, err
+	}
+	if inst == nil || inst.Minecraft != minecraft || inst.Loader != loader {
+		return NotInstalled, pack, nil
+	}
+	if inst.Version != pack.Version || inst.URL != pack.URL || (pack.SHA512 != "" && inst.SHA512 != pack.SHA512) {
+		return UpdateAvailable, pack, nil
+	}
+	return UpToDate, pack, nil
+}
+
+// Install downloads pack, installs its loader into dataDir and its files into
+// gameDir, removing files left over from a previously installed version. It
+// returns the version id to launch.
+func Install(ctx context.Context, m *download.Manager, dataDir, gameDir string, pack *Modpack, events chan<- download.Event) (string, error) {
+	packPath := filepath.Join(dataDir, "cache", "modpacks", packFileName(pack))
+	if !pack.hasHash() {
+		// Without a hash a truncated cached copy would look complete forever
+		os.Remove(packPath)
+	}
+	file := download.File{URL: pack.URL, Path: packPath, SHA1: pack.SHA1, SHA512: pack.SHA512, Size: pack.Size}
+	if err := m.Download(ctx, []download.File{file}, nil); err != nil {
+		return "", fmt.Errorf("failed to download modpack: %v", err)
+	}
+
+	p, err := mrpack.Open(packPath)
+	if err != nil {
+		return "", err
+	}
+	defer p.Close()
+
+	if mc := p.Index.MinecraftVersion(); mc != pack.Minecraft {
+		return "", fmt.Errorf("modpack is for Minecraft %s, channel says %s", mc, pack.Minecraft)
+	}
+
+	versionID := pack.Minecraft
+	loader, loaderVersion := p.Index.Loader()
+	switch loader {
+	case "":
+	case mrpack.DependencyFabric:
+		versionID, err = fabric.NewClient().Install(ctx, m, dataDir, pack.Minecraft, loaderVersion, events)
+		if err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("modpack needs %s, which is not supported yet", loader)
+	}
+
+	previous, _ := LoadInstalled(gameDir)
+
+	if err := p.Install(ctx, m, gameDir, events); err != nil {
+		return "", err
+	}
+
+	var files []string
+	keep := map[string]bool{}
+	for _, f := range p.Index.ClientFiles() {
+		files = append(files, f.Path)
+		keep[f.Path] = true
+	}
+	if previous != nil {
+		for _, old := range previous.Files {
+			clean := path.Clean(old)
+			if keep[old] || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+				continue
+			}
+			os.Remove(filepath.Join(gameDir, filepath.FromSlash(clean)))
+		}
+	}
+
+	inst := Installed{Modpack: *pack, VersionID: versionID, Files: files}
+	data, err := json.MarshalIndent(inst, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(gameDir, markerFile), data, 0644); err != nil {
+		return "", err
+	}
+	return versionID, nil
+}
+
+// packFileName derives a cache file name from the pack URL
+func packFileName(pack *Modpack) string {
+	name := pack.Version + ".mrpack"
+	if u, err := url.Parse(pack.URL); err == nil {
+		if base := path.Base(u.Path); base != "." && base != "/" {
+			if unescaped, err := url.PathUnescape(base); err == nil {
+				base = unescaped
+			}
+			name = pack.Version + "-" + base
+		}
+	}
+	return filepath.Base(filepath.Clean(name))
+}
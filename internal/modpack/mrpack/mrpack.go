@@ -0,0 +1,279 @@
+package mrpack
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"Nix-Client-Launcher/internal/download"
+)
+
+// Dependency keys allowed in modrinth.index.json
+const (
+	DependencyMinecraft = "minecraft"
+	DependencyFabric    = "fabric-loader"
+	DependencyQuilt     = "quilt-loader"
+	DependencyForge     = "forge"
+	DependencyNeoForge  = "neoforge"
+)
+
+const (
+	indexFile          = "modrinth.index.json"
+	overridesDir       = "overrides/"
+	clientOverridesDir = "client-overrides/"
+	envUnsupported     = "unsupported"
+	formatVersion      = 1
+)
+
+type Index struct {
+	FormatVersion int               `json:"formatVersion"`
+	Game          string            `json:"game"`
+	VersionID     string            `json:"versionId"`
+	Name          string            `json:"name"`
+	Summary       string            `json:"summary,omitempty"`
+	Files         []File            `json:"files"`
+	Dependencies  map[string]string `json:"dependencies"`
+}
+
+type File struct {
+	Path   string `json:"path"`
+	Hashes struct {
+		SHA1   string `json:"sha1"`
+		SHA512 string `json:"sha512"`
+	} `json:"hashes"`
+	Env *struct {
+		Client string `json:"client"`
+		Server string `json:"server"`
+	} `json:"env,omitempty"`
+	Downloads []string `json:"downloads"`
+	FileSize  int64    `json:"fileSize"`
+}
+
+// Loader returns the mod loader dependency and its version, if any
+func (idx *Index) Loader() (string, string) {
+	for _, key := range []string{DependencyFabric, DependencyQuilt, DependencyForge, DependencyNeoForge} {
+		if v, ok := idx.Dependencies[key]; ok {
+			return key, v
+		}
+	}
+	return "", ""
+}
+
+// MinecraftVersion returns the minecraft dependency
+func (idx *Index) MinecraftVersion() string {
+	return idx.Dependencies[DependencyMinecraft]
+}
+
+// Pack is an opened .mrpack file
+type Pack struct {
+	Index  *Index
+	reader *zip.ReadCloser
+}
+
+// Open reads and validates the index of a .mrpack file
+func Open(packPath string) (*Pack, error) {
+	r, err := zip.OpenReader(packPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var idx *Index
+	for _, f := range r.File {
+		if f.Name != indexFile {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		idx = &Index{}
+		err = json.NewDecoder(rc).Decode(idx)
+		rc.Close()
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("failed to parse %s: %v", indexFile, err)
+		}
+		break
+	}
+	if idx == nil {
+		r.Close()
+		return nil, fmt.Errorf("%s has no %s", packPath, indexFile)
+	}
+	if err := idx.validate(); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return &Pack{Index: idx, reader: r}, nil
+}
+
+func (p *Pack) Close() error {
+	return p.reader.Close()
+}
+
+func (idx *Index) validate() error {
+	if idx.FormatVersion != formatVersion {
+		return fmt.Errorf("unsupported mrpack format version %d", idx.FormatVersion)
+	}
+	if idx.Game != "minecraft" {
+		return fmt.Errorf("unsupported mrpack game %q", idx.Game)
+	}
+	if idx.MinecraftVersion() == "" {
+		return fmt.Errorf("mrpack does not declare a minecraft dependency")
+	}
+	loaders := 0
+	for key := range idx.Dependencies {
+		switch key {
+		case DependencyMinecraft:
+		case DependencyFabric, DependencyQuilt, DependencyForge, DependencyNeoForge:
+			loaders++
+		default:
+			return fmt.Errorf("unknown mrpack dependency %q", key)
+		}
+	}
+	if loaders > 1 {
+		return fmt.Errorf("mrpack declares more than one mod loader")
+	}
+	for _, f := range idx.Files {
+		if _, err := safePath(f.Path); err != nil {
+			return err
+		}
+		if f.Hashes.SHA512 == "" {
+			return fmt.Errorf("mrpack file %s has no sha512 hash", f.Path)
+		}
+		if len(f.Downloads) == 0 {
+			return fmt.Errorf("mrpack file %s has no downloads", f.Path)
+		}
+	}
+	return nil
+}
+
+// ClientFiles returns the files that belong on a client, dropping those
+// whose env.client is "unsupported". Optional files are kept.
+func (idx *Index) ClientFiles() []File {
+	var files []File
+	for _, f := range idx.Files {
+		if f.Env != nil && f.Env.Client == envUnsupported {
+			continue
+		}
+		files = append(files, f)
+	}
+	return files
+}
+
+// Install downloads every client file into gameDir, verifying SHA-512 (and
+// SHA-1 when given), then applies overrides/ followed by client-overrides/ so
+// client specific files win. Mirrors listed after the first download URL are
+// tried for any file that failed.
+func (p *Pack) Install(ctx context.Context, m *download.Manager, gameDir string, events chan<- download.Event) error {
+	files := p.Index.ClientFiles()
+
+	// failed holds files that ran out of mirrors, they fail the install even
+	// when a later batch for other files succeeds
+	pending := files
+	var failed []File
+	var lastErr error
+	for attempt := 0; len(pending) > 0; attempt++ {
+		var batch []download.File
+		var next []File
+		for _, f := range pending {
+			if attempt >= len(f.Downloads) {
+				failed = append(failed, f)
+				continue
+			}
+			next = append(next, f)
+			target, _ := safePath(f.Path)
+			batch = append(batch, download.File{
+				URL:    f.Downloads[attempt],
+				Path:   filepath.Join(gameDir, target),
+				SHA1:   f.Hashes.SHA1,
+				SHA512: f.Hashes.SHA512,
+				Size:   f.FileSize,
+			})
+		}
+		if len(batch) == 0 {
+			break
+		}
+		if err := m.Download(ctx, batch, events); err != nil {
+			lastErr = err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// Retry only what is still missing or corrupt, from the next mirror
+		pending = nil
+		for i, f := range next {
+			if download.Verify(batch[i].Path, batch[i]) != nil {
+				pending = append(pending, f)
+			}
+		}
+	}
+	if len(failed) > 0 {
+		var paths []string
+		for _, f := range failed {
+			paths = append(paths, f.Path)
+		}
+		return fmt.Errorf("failed to download modpack files %s: %v", strings.Join(paths, ", "), lastErr)
+	}
+
+	if err := p.extractOverrides(overridesDir, gameDir); err != nil {
+		return err
+	}
+	return p.extractOverrides(clientOverridesDir, gameDir)
+}
+
+func (p *Pack) extractOverrides(prefix, gameDir string) error {
+	for _, f := range p.reader.File {
+		if !strings.HasPrefix(f.Name, prefix) || f.FileInfo().IsDir() {
+			continue
+		}
+		rel, err := safePath(strings.TrimPrefix(f.Name, prefix))
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(gameDir, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := extractFile(f, target); err != nil {
+			return fmt.Errorf("failed to extract %s: %v", f.Name, err)
+		}
+	}
+	return nil
+}
+
+func extractFile(f *zip.File, target string) error {
+	in, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// safePath rejects absolute paths and anything escaping the game directory,
+// which a malicious pack could otherwise use to overwrite arbitrary files
+func safePath(p string) (string, error) {
+	p = strings.ReplaceAll(p, "\\", "/")
+	clean := path.Clean(p)
+	if p == "" || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") || strings.Contains(clean, ":") {
+		return "", fmt.Errorf("unsafe path %q in modpack", p)
+	}
+	return filepath.FromSlash(clean), nil
+}
@@ -0,0 +1,202 @@
+package modrinth
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"Nix-Client-Launcher/internal/download"
+	"Nix-Client-Launcher/internal/instance"
+	"Nix-Client-Launcher/internal/mods"
+)
+
+// Plan is what installing a project takes: its chosen version and those of
+// every required dependency not already in the instance
+type Plan struct {
+	Versions []*Version
+	// Replaces are the requested project's jars already in the mods folder,
+	// recorded or found by hash, which the install takes the place of
+	Replaces []string
+}
+
+// Resolve picks the version of projectID to install into inst, then walks
+// its required dependencies. Dependencies already in the mods folder, whether
+// installed from Modrinth, by a modpack or by hand, are kept as they are. The
+// requested project itself is always (re)picked so Resolve doubles as an
+// update, and its jars found by hash are replaced along with recorded ones.
+func (c *Client) Resolve(ctx context.Context, store *instance.Store, inst *instance.Instance, projectID string) (*Plan, error) {
+	if inst.Loader == "" {
+		return nil, fmt.Errorf("%s has no mod loader", inst.Name)
+	}
+	var installed map[string][]string
+	installedJars := func(projectID string) ([]string, error) {
+		if installed == nil {
+			var err error
+			if installed, err = c.installedProjects(ctx, store, inst); err != nil {
+				return nil, err
+			}
+		}
+		return installed[projectID], nil
+	}
+
+	plan := &Plan{}
+	seen := map[string]bool{}
+	type pending struct {
+		projectID string
+		versionID string
+		root      bool
+	}
+	queue := []pending{{projectID: projectID, root: true}}
+
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+
+		var v *Version
+		if next.versionID != "" {
+			got, err := c.Version(ctx, next.versionID)
+			if err != nil {
+				return nil, err
+			}
+			v = got
+		} else {
+			versions, err := c.Versions(ctx, next.projectID, inst.MinecraftVersion, inst.Loader)
+			if err != nil {
+				return nil, err
+			}
+			if v, err = PickVersion(versions, inst.MinecraftVersion, inst.Loader); err != nil {
+				return nil, fmt.Errorf("%s: %v", next.projectID, err)
+			}
+		}
+		if seen[v.ProjectID] {
+			continue
+		}
+		seen[v.ProjectID] = true
+		jars, err := installedJars(v.ProjectID)
+		if err != nil {
+			return nil, err
+		}
+		if next.root {
+			plan.Replaces = jars
+		} else if len(jars) > 0 {
+			continue
+		}
+		plan.Versions = append(plan.Versions, v)
+
+		for _, dep := range v.Dependencies {
+			switch dep.DependencyType {
+			case DependencyRequired:
+				if dep.ProjectID != "" && seen[dep.ProjectID] {
+					continue
+				}
+				if dep.ProjectID == "" && dep.VersionID == "" {
+					continue
+				}
+				queue = append(queue, pending{projectID: dep.ProjectID, versionID: dep.VersionID})
+			case DependencyIncompatible:
+				if dep.ProjectID == "" {
+					continue
+				}
+				jars, err := installedJars(dep.ProjectID)
+				if err != nil {
+					return nil, err
+				}
+				if len(jars) > 0 {
+					return nil, fmt.Errorf("%s is incompatible with %s, which is already installed", v.Name, dep.ProjectID)
+				}
+			}
+		}
+	}
+	return plan, nil
+}
+
+// installedProjects maps the Modrinth projects of the enabled jars in the
+// instance's mods folder to their file names. Jars without a record are looked
+// up by SHA-1, ones Modrinth doesn't know can't be matched to a project.
+func (c *Client) installedProjects(ctx context.Context, store *instance.Store, inst *instance.Instance) (map[string][]string, error) {
+	jars, err := mods.List(store.GameDir(inst))
+	if err != nil {
+		return nil, err
+	}
+
+	projects := map[string][]string{}
+	byHash := map[string][]string{}
+	var hashes []string
+	for _, m := range jars {
+		if !m.Enabled {
+			continue
+		}
+		if record, ok := inst.FindModFile(baseName(m)); ok && record.ProjectID != "" {
+			projects[record.ProjectID] = append(projects[record.ProjectID], m.FileName)
+			continue
+		}
+		hash, err := hashFile(m.Path)
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := byHash[hash]; !dup {
+			hashes = append(hashes, hash)
+		}
+		byHash[hash] = append(byHash[hash], m.FileName)
+	}
+	if len(hashes) == 0 {
+		return projects, nil
+	}
+
+	versions, err := c.VersionsByHash(ctx, hashes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to identify installed mods: %v", err)
+	}
+	for hash, v := range versions {
+		projects[v.ProjectID] = append(projects[v.ProjectID], byHash[hash]...)
+	}
+	return projects, nil
+}
+
+// Install downloads a resolved plan into the instance's mods folder, replacing
+// jars of older versions of the same projects, and records every installed
+// project and version on inst before saving it
+func (p *Plan) Install(ctx context.Context, m *download.Manager, store *instance.Store, inst *instance.Instance, events chan<- download.Event) error {
+	modsDir := mods.Dir(store.GameDir(inst))
+
+	var files []download.File
+	var records []instance.Mod
+	for _, v := range p.Versions {
+		f, name, err := v.jarFile()
+		if err != nil {
+			return err
+		}
+		files = append(files, download.File{
+			URL:    f.URL,
+			Path:   filepath.Join(modsDir, name),
+			SHA1:   f.Hashes.SHA1,
+			SHA512: f.Hashes.SHA512,
+			Size:   f.Size,
+		})
+		records = append(records, instance.Mod{FileName: name, ProjectID: v.ProjectID, VersionID: v.ID})
+	}
+
+	if err := m.Download(ctx, files, events); err != nil {
+		return fmt.Errorf("failed to download mods: %v", err)
+	}
+
+	installed := map[string]bool{}
+	for _, record := range records {
+		installed[record.FileName] = true
+	}
+	for _, name := range p.Replaces {
+		if !installed[name] {
+			os.Remove(filepath.Join(modsDir, name))
+			inst.RemoveMod(name)
+		}
+	}
+	for _, record := range records {
+		if old, ok := inst.FindMod(record.ProjectID); ok && old.FileName != record.FileName {
+			os.Remove(filepath.Join(modsDir, old.FileName))
+			os.Remove(filepath.Join(modsDir, old.FileName+mods.DisabledSuffix))
+		}
+		inst.SetMod(record)
+	}
+	return store.Save(inst)
+}
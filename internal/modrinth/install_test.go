@@ -0,0 +1,408 @@
+package modrinth
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"Nix-Client-Launcher/internal/download"
+	"Nix-Client-Launcher/internal/instance"
+	"Nix-Client-Launcher/internal/mods"
+)
+
+// fakeAPI is a local stand-in for the parts of the Modrinth API that
+// installing uses, serving jars from /files/
+type fakeAPI struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	versions []Version
+	files    map[string][]byte
+	requests []string
+}
+
+func newFakeAPI(t *testing.T) *fakeAPI {
+	t.Helper()
+	api := &fakeAPI{files: map[string][]byte{}}
+	api.Server = httptest.NewServer(http.HandlerFunc(api.serve))
+	t.Cleanup(api.Close)
+	return api
+}
+
+func (api *fakeAPI) client() *Client {
+	return &Client{BaseURL: api.URL, HTTPClient: api.Client()}
+}
+
+// addVersion publishes a version of projectID with one jar holding content
+func (api *fakeAPI) addVersion(projectID, id, content string, published time.Time, deps ...Dependency) Version {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	name := projectID + "-" + id + ".jar"
+	api.files[name] = []byte(content)
+	f := File{URL: api.URL + "/files/" + name, Filename: name, Primary: true, Size: int64(len(content))}
+	f.Hashes.SHA1 = sha1Hex(content)
+	sum := sha512.Sum512([]byte(content))
+	f.Hashes.SHA512 = hex.EncodeToString(sum[:])
+
+	v := Version{
+		ID:            id,
+		ProjectID:     projectID,
+		Name:          projectID + " " + id,
+		VersionNumber: id,
+		VersionType:   "release",
+		GameVersions:  []string{"1.21.11"},
+		Loaders:       []string{"fabric"},
+		Files:         []File{f},
+		Dependencies:  deps,
+		DatePublished: published,
+	}
+	api.versions = append(api.versions, v)
+	return v
+}
+
+// edit changes a published version in place
+func (api *fakeAPI) edit(id string, change func(v *Version)) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	for i := range api.versions {
+		if api.versions[i].ID == id {
+			change(&api.versions[i])
+		}
+	}
+}
+
+// requested reports whether any request path started with prefix
+func (api *fakeAPI) requested(prefix string) bool {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	for _, r := range api.requests {
+		if strings.HasPrefix(r, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (api *fakeAPI) serve(w http.ResponseWriter, r *http.Request) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.requests = append(api.requests, r.Method+" "+r.URL.Path)
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 2 && parts[0] == "files":
+		data, ok := api.files[parts[1]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	case len(parts) == 3 && parts[0] == "project" && parts[2] == "version":
+		var gameVersions, loaders []string
+		json.Unmarshal([]byte(r.URL.Query().Get("game_versions")), &gameVersions)
+		json.Unmarshal([]byte(r.URL.Query().Get("loaders")), &loaders)
+		list := []Version{}
+		for _, v := range api.versions {
+			if v.ProjectID == parts[1] && v.Supports(gameVersions[0], loaders[0]) {
+				list = append(list, v)
+			}
+		}
+		json.NewEncoder(w).Encode(list)
+	case len(parts) == 2 && parts[0] == "version":
+		for _, v := range api.versions {
+			if v.ID == parts[1] {
+				json.NewEncoder(w).Encode(v)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	case len(parts) == 1 && parts[0] == "version_files" && r.Method == "POST":
+		var body struct {
+			Hashes    []string `json:"hashes"`
+			Algorithm string   `json:"algorithm"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Algorithm != "sha1" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		result := map[string]Version{}
+		for _, hash := range body.Hashes {
+			for _, v := range api.versions {
+				if len(v.Files) > 0 && v.Files[0].Hashes.SHA1 == hash {
+					result[hash] = v
+				}
+			}
+		}
+		json.NewEncoder(w).Encode(result)
+	case len(parts) == 2 && parts[0] == "version_files" && parts[1] == "update" && r.Method == "POST":
+		var body struct {
+			Hashes       []string `json:"hashes"`
+			Algorithm    string   `json:"algorithm"`
+			Loaders      []string `json:"loaders"`
+			GameVersions []string `json:"game_versions"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Algorithm != "sha1" ||
+			len(body.Loaders) != 1 || len(body.GameVersions) != 1 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		result := map[string]Version{}
+		for _, hash := range body.Hashes {
+			for _, v := range api.versions {
+				if len(v.Files) == 0 || v.Files[0].Hashes.SHA1 != hash {
+					continue
+				}
+				var latest *Version
+				for i, other := range api.versions {
+					if other.ProjectID == v.ProjectID && other.Supports(body.GameVersions[0], body.Loaders[0]) &&
+						(latest == nil || other.DatePublished.After(latest.DatePublished)) {
+						latest = &api.versions[i]
+					}
+				}
+				if latest != nil {
+					result[hash] = *latest
+				}
+			}
+		}
+		json.NewEncoder(w).Encode(result)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func sha1Hex(content string) string {
+	sum := sha1.Sum([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func newInstance(t *testing.T) (*instance.Store, *instance.Instance) {
+	t.Helper()
+	store := instance.NewStore(t.TempDir())
+	inst, err := store.Create(instance.Instance{Name: "Test", MinecraftVersion: "1.21.11", Loader: "fabric"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	return store, inst
+}
+
+// addJar drops a jar into the instance's mods folder as if by hand
+func addJar(t *testing.T, store *instance.Store, inst *instance.Instance, name, content string) {
+	t.Helper()
+	dir := mods.Dir(store.GameDir(inst))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func planIDs(plan *Plan) []string {
+	var ids []string
+	for _, v := range plan.Versions {
+		ids = append(ids, v.ID)
+	}
+	return ids
+}
+
+var day = time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func TestResolve(t *testing.T) {
+	api := newFakeAPI(t)
+	api.addVersion("fabric-api", "api-old", "api 1", day)
+	api.addVersion("fabric-api", "api-new", "api 2", day.Add(24*time.Hour))
+	api.addVersion("cloth", "cloth-1", "cloth", day)
+	api.addVersion("modmenu", "modmenu-1", "modmenu", day)
+	api.addVersion("sodium", "sodium-1", "sodium", day,
+		Dependency{ProjectID: "fabric-api", DependencyType: DependencyRequired},
+		Dependency{VersionID: "cloth-1", DependencyType: DependencyRequired},
+		Dependency{ProjectID: "modmenu", DependencyType: DependencyOptional},
+	)
+	store, inst := newInstance(t)
+
+	plan, err := api.client().Resolve(context.Background(), store, inst, "sodium")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if got, want := planIDs(plan), []string{"sodium-1", "api-new", "cloth-1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("plan = %v, want %v", got, want)
+	}
+	if api.requested("POST /version_files") {
+		t.Error("an empty mods folder was looked up by hash")
+	}
+}
+
+func TestResolveSkipsInstalledDependencies(t *testing.T) {
+	api := newFakeAPI(t)
+	api.addVersion("fabric-api", "api-1", "api", day)
+	api.addVersion("cloth", "cloth-1", "cloth", day)
+	api.addVersion("lithium", "lithium-1", "lithium", day)
+	api.addVersion("sodium", "sodium-1", "sodium", day,
+		Dependency{ProjectID: "fabric-api", DependencyType: DependencyRequired},
+		Dependency{ProjectID: "cloth", DependencyType: DependencyRequired},
+		Dependency{ProjectID: "lithium", DependencyType: DependencyRequired},
+	)
+	store, inst := newInstance(t)
+
+	// Fabric API came with a modpack under another name, cloth was installed
+	// from Modrinth and lithium is there but disabled
+	addJar(t, store, inst, "fabric-api-from-pack.jar", "api")
+	addJar(t, store, inst, "cloth.jar", "cloth, recorded")
+	inst.SetMod(instance.Mod{FileName: "cloth.jar", ProjectID: "cloth", VersionID: "cloth-0"})
+	addJar(t, store, inst, "lithium.jar.disabled", "lithium")
+
+	plan, err := api.client().Resolve(context.Background(), store, inst, "sodium")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if got, want := planIDs(plan), []string{"sodium-1", "lithium-1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("plan = %v, want %v", got, want)
+	}
+}
+
+func TestResolveIncompatible(t *testing.T) {
+	api := newFakeAPI(t)
+	api.addVersion("optifabric", "optifabric-1", "optifabric", day)
+	api.addVersion("sodium", "sodium-1", "sodium", day,
+		Dependency{ProjectID: "optifabric", DependencyType: DependencyIncompatible},
+	)
+	store, inst := newInstance(t)
+	addJar(t, store, inst, "OptiFabric.jar", "optifabric")
+
+	_, err := api.client().Resolve(context.Background(), store, inst, "sodium")
+	if err == nil || !strings.Contains(err.Error(), "incompatible") {
+		t.Fatalf("Resolve = %v, want an incompatibility error", err)
+	}
+}
+
+func TestResolveWithoutLoader(t *testing.T) {
+	api := newFakeAPI(t)
+	store, inst := newInstance(t)
+	inst.Loader = ""
+
+	if _, err := api.client().Resolve(context.Background(), store, inst, "sodium"); err == nil {
+		t.Fatal("Resolve accepted a vanilla instance")
+	}
+}
+
+func testManager() *download.Manager {
+	m := download.NewManager()
+	m.Backoff = time.Millisecond
+	return m
+}
+
+func TestPlanInstall(t *testing.T) {
+	api := newFakeAPI(t)
+	api.addVersion("fabric-api", "api-1", "api", day)
+	api.addVersion("sodium", "sodium-2", "sodium 2", day,
+		Dependency{ProjectID: "fabric-api", DependencyType: DependencyRequired},
+	)
+	store, inst := newInstance(t)
+	addJar(t, store, inst, "sodium-old.jar", "sodium 1")
+	inst.SetMod(instance.Mod{FileName: "sodium-old.jar", ProjectID: "sodium", VersionID: "sodium-1"})
+
+	plan, err := api.client().Resolve(context.Background(), store, inst, "sodium")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if err := plan.Install(context.Background(), testManager(), store, inst, nil); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+
+	dir := mods.Dir(store.GameDir(inst))
+	for name, want := range map[string]string{"sodium-sodium-2.jar": "sodium 2", "fabric-api-api-1.jar": "api"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v, want %q", name, data, err, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sodium-old.jar")); !os.IsNotExist(err) {
+		t.Error("the jar of the replaced version is still there")
+	}
+
+	want := []instance.Mod{
+		{FileName: "sodium-sodium-2.jar", ProjectID: "sodium", VersionID: "sodium-2"},
+		{FileName: "fabric-api-api-1.jar", ProjectID: "fabric-api", VersionID: "api-1"},
+	}
+	saved, err := store.Get(inst.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !reflect.DeepEqual(saved.Mods, want) {
+		t.Errorf("saved mods = %+v, want %+v", saved.Mods, want)
+	}
+}
+
+func TestPlanInstallReplacesUnrecordedJars(t *testing.T) {
+	api := newFakeAPI(t)
+	api.addVersion("sodium", "sodium-1", "sodium 1", day)
+	api.addVersion("sodium", "sodium-2", "sodium 2", day.Add(24*time.Hour))
+	store, inst := newInstance(t)
+	// Dropped in by hand and pinned, then installed from Modrinth anyway
+	addJar(t, store, inst, "Sodium by hand.jar", "sodium 1")
+	inst.SetPinned("Sodium by hand.jar", true)
+	addJar(t, store, inst, "unknown.jar", "not on modrinth")
+
+	plan, err := api.client().Resolve(context.Background(), store, inst, "sodium")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if !reflect.DeepEqual(plan.Replaces, []string{"Sodium by hand.jar"}) {
+		t.Errorf("plan replaces %q, want the jar dropped in by hand", plan.Replaces)
+	}
+	if err := plan.Install(context.Background(), testManager(), store, inst, nil); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+
+	jars, err := mods.List(store.GameDir(inst))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, m := range jars {
+		names = append(names, m.FileName)
+	}
+	if want := []string{"sodium-sodium-2.jar", "unknown.jar"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("mods = %v, want %v", names, want)
+	}
+	want := []instance.Mod{{FileName: "sodium-sodium-2.jar", ProjectID: "sodium", VersionID: "sodium-2"}}
+	if !reflect.DeepEqual(inst.Mods, want) {
+		t.Errorf("mods = %+v, want %+v", inst.Mods, want)
+	}
+}
+
+func TestPlanInstallBadHash(t *testing.T) {
+	api := newFakeAPI(t)
+	api.addVersion("sodium", "sodium-1", "sodium", day)
+	store, inst := newInstance(t)
+
+	plan, err := api.client().Resolve(context.Background(), store, inst, "sodium")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	api.mu.Lock()
+	api.files["sodium-sodium-1.jar"] = []byte("tampered")
+	api.mu.Unlock()
+
+	if err := plan.Install(context.Background(), testManager(), store, inst, nil); err == nil {
+		t.Fatal("Install accepted a jar with the wrong hash")
+	}
+	if len(inst.Mods) != 0 {
+		t.Errorf("a failed install recorded %+v", inst.Mods)
+	}
+	if jars, _ := mods.List(store.GameDir(inst)); len(jars) != 0 {
+		t.Errorf("a failed install left %d jars in mods/", len(jars))
+	}
+}
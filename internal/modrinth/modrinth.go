@@ -0,0 +1,288 @@
+package modrinth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"Nix-Client-Launcher/internal/download"
+)
+
+const APIURL = "https://api.modrinth.com/v2"
+
+// Dependency types on a version
+const (
+	DependencyRequired     = "required"
+	DependencyOptional     = "optional"
+	DependencyIncompatible = "incompatible"
+	DependencyEmbedded     = "embedded"
+)
+
+type SearchResult struct {
+	Hits      []SearchHit `json:"hits"`
+	Offset    int         `json:"offset"`
+	Limit     int         `json:"limit"`
+	TotalHits int         `json:"total_hits"`
+}
+
+type SearchHit struct {
+	ProjectID   string   `json:"project_id"`
+	Slug        string   `json:"slug"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Author      string   `json:"author"`
+	ProjectType string   `json:"project_type"`
+	Downloads   int      `json:"downloads"`
+	IconURL     string   `json:"icon_url"`
+	Categories  []string `json:"categories"`
+	Versions    []string `json:"versions"`
+}
+
+type Project struct {
+	ID          string `json:"id"`
+	Slug        string `json:"slug"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	ProjectType string `json:"project_type"`
+	ClientSide  string `json:"client_side"`
+	ServerSide  string `json:"server_side"`
+	IconURL     string `json:"icon_url"`
+}
+
+type Version struct {
+	ID            string       `json:"id"`
+	ProjectID     string       `json:"project_id"`
+	Name          string       `json:"name"`
+	VersionNumber string       `json:"version_number"`
+	VersionType   string       `json:"version_type"` // release, beta or alpha
+	GameVersions  []string     `json:"game_versions"`
+	Loaders       []string     `json:"loaders"`
+	Files         []File       `json:"files"`
+	Dependencies  []Dependency `json:"dependencies"`
+	DatePublished time.Time    `json:"date_published"`
+}
+
+type File struct {
+	Hashes struct {
+		SHA1   string `json:"sha1"`
+		SHA512 string `json:"sha512"`
+	} `json:"hashes"`
+	URL      string `json:"url"`
+	Filename string `json:"filename"`
+	Primary  bool   `json:"primary"`
+	Size     int64  `json:"size"`
+}
+
+type Dependency struct {
+	VersionID      string `json:"version_id,omitempty"`
+	ProjectID      string `json:"project_id,omitempty"`
+	FileName       string `json:"file_name,omitempty"`
+	DependencyType string `json:"dependency_type"`
+}
+
+// PrimaryFile returns the file marked primary, or the first one
+func (v *Version) PrimaryFile() (*File, error) {
+	for i := range v.Files {
+		if v.Files[i].Primary {
+			return &v.Files[i], nil
+		}
+	}
+	if len(v.Files) == 0 {
+		return nil, fmt.Errorf("version %s has no files", v.ID)
+	}
+	return &v.Files[0], nil
+}
+
+// jarFile returns the primary file and the name to save it under in mods/.
+// Anything but a plain .jar name is refused, the name comes from the API.
+func (v *Version) jarFile() (*File, string, error) {
+	f, err := v.PrimaryFile()
+	if err != nil {
+		return nil, "", err
+	}
+	name := filepath.Base(filepath.Clean(f.Filename))
+	if name == "." || name == ".." || !strings.HasSuffix(name, ".jar") {
+		return nil, "", fmt.Errorf("unexpected file name %q for %s", f.Filename, v.Name)
+	}
+	return f, name, nil
+}
+
+// Supports reports whether the version lists gameVersion and loader
+func (v *Version) Supports(gameVersion, loader string) bool {
+	return contains(v.GameVersions, gameVersion) && contains(v.Loaders, loader)
+}
+
+// Client talks to the Modrinth v2 API. BaseURL can point at a local stand-in.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+func NewClient() *Client {
+	return &Client{BaseURL: APIURL, HTTPClient: download.DefaultClient}
+}
+
+// SearchOptions narrow a search. Empty fields are not filtered on.
+type SearchOptions struct {
+	GameVersion string
+	Loader      string
+	ProjectType string // defaults to "mod"
+	Limit       int
+	Offset      int
+}
+
+// Search finds projects matching query
+func (c *Client) Search(ctx context.Context, query string, opts SearchOptions) (*SearchResult, error) {
+	projectType := opts.ProjectType
+	if projectType == "" {
+		projectType = "mod"
+	}
+	facets := [][]string{{"project_type:" + projectType}}
+	if opts.GameVersion != "" {
+		facets = append(facets, []string{"versions:" + opts.GameVersion})
+	}
+	if opts.Loader != "" {
+		facets = append(facets, []string{"categories:" + opts.Loader})
+	}
+	facetJSON, err := json.Marshal(facets)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("facets", string(facetJSON))
+	if opts.Limit > 0 {
+		params.Set("limit", fmt.Sprint(opts.Limit))
+	}
+	if opts.Offset > 0 {
+		params.Set("offset", fmt.Sprint(opts.Offset))
+	}
+
+	var result SearchResult
+	if err := c.get(ctx, "/search?"+params.Encode(), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Project fetches a project by id or slug
+func (c *Client) Project(ctx context.Context, idOrSlug string) (*Project, error) {
+	var p Project
+	if err := c.get(ctx, "/project/"+url.PathEscape(idOrSlug), &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Versions lists a project's versions for gameVersion and loader, newest
+// first. Empty filters list everything.
+func (c *Client) Versions(ctx context.Context, idOrSlug, gameVersion, loader string) ([]Version, error) {
+	params := url.Values{}
+	if gameVersion != "" {
+		params.Set("game_versions", jsonList(gameVersion))
+	}
+	if loader != "" {
+		params.Set("loaders", jsonList(loader))
+	}
+	path := "/project/" + url.PathEscape(idOrSlug) + "/version"
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+	var versions []Version
+	if err := c.get(ctx, path, &versions); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// Version fetches one version by id
+func (c *Client) Version(ctx context.Context, id string) (*Version, error) {
+	var v Version
+	if err := c.get(ctx, "/version/"+url.PathEscape(id), &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// PickVersion chooses the newest release supporting gameVersion and loader,
+// falling back to betas and then alphas
+func PickVersion(versions []Version, gameVersion, loader string) (*Version, error) {
+	for _, channel := range []string{"release", "beta", "alpha"} {
+		var best *Version
+		for i := range versions {
+			v := &versions[i]
+			if v.VersionType != channel || !v.Supports(gameVersion, loader) {
+				continue
+			}
+			if best == nil || v.DatePublished.After(best.DatePublished) {
+				best = v
+			}
+		}
+		if best != nil {
+			return best, nil
+		}
+	}
+	return nil, fmt.Errorf("no version for Minecraft %s with %s", gameVersion, loader)
+}
+
+func (c *Client) get(ctx context.Context, path string, out interface{}) error {
+	return c.do(ctx, "GET", path, nil, out)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", download.UserAgent)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = download.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("modrinth request failed: %s - %s", resp.Status, string(data))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func jsonList(items ...string) string {
+	data, _ := json.Marshal(items)
+	return string(data)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
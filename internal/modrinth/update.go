@@ -0,0 +1,301 @@
+package modrinth
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"Nix-Client-Launcher/internal/download"
+	"Nix-Client-Launcher/internal/instance"
+	"Nix-Client-Launcher/internal/mods"
+)
+
+const backupManifest = "backup.json"
+
+// Update is a newer compatible version of a jar in the mods folder
+type Update struct {
+	Mod     *mods.Mod
+	Current *Version
+	Latest  *Version
+}
+
+// VersionsByHash identifies files by SHA-1 with the version_files endpoint.
+// Hashes Modrinth doesn't know are missing from the result.
+func (c *Client) VersionsByHash(ctx context.Context, hashes []string) (map[string]Version, error) {
+	body := map[string]interface{}{"hashes": hashes, "algorithm": "sha1"}
+	result := map[string]Version{}
+	if err := c.do(ctx, "POST", "/version_files", body, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// LatestByHash returns the newest version for each file's project that
+// supports gameVersion and loader, using the version_files/update endpoint
+func (c *Client) LatestByHash(ctx context.Context, hashes []string, gameVersion, loader string) (map[string]Version, error) {
+	body := map[string]interface{}{
+		"hashes":        hashes,
+		"algorithm":     "sha1",
+		"loaders":       []string{loader},
+		"game_versions": []string{gameVersion},
+	}
+	result := map[string]Version{}
+	if err := c.do(ctx, "POST", "/version_files/update", body, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CheckUpdates hashes every jar in the instance's mods folder and returns the
+// ones with a newer version for its Minecraft version and loader. Pinned jars
+// are skipped.
+func (c *Client) CheckUpdates(ctx context.Context, store *instance.Store, inst *instance.Instance) ([]Update, error) {
+	if inst.Loader == "" {
+		return nil, fmt.Errorf("%s has no mod loader", inst.Name)
+	}
+	installed, err := mods.List(store.GameDir(inst))
+	if err != nil {
+		return nil, err
+	}
+
+	byHash := map[string]*mods.Mod{}
+	var hashes []string
+	for _, m := range installed {
+		if inst.IsPinned(baseName(m)) {
+			continue
+		}
+		hash, err := hashFile(m.Path)
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := byHash[hash]; !dup {
+			hashes = append(hashes, hash)
+		}
+		byHash[hash] = m
+	}
+	if len(hashes) == 0 {
+		return nil, nil
+	}
+
+	current, err := c.VersionsByHash(ctx, hashes)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := c.LatestByHash(ctx, hashes, inst.MinecraftVersion, inst.Loader)
+	if err != nil {
+		return nil, err
+	}
+
+	var updates []Update
+	for _, hash := range hashes {
+		cur, ok := current[hash]
+		if !ok {
+			continue
+		}
+		next, ok := latest[hash]
+		if !ok || next.ID == cur.ID || !next.DatePublished.After(cur.DatePublished) {
+			continue
+		}
+		if !next.Supports(inst.MinecraftVersion, inst.Loader) {
+			continue
+		}
+		updates = append(updates, Update{Mod: byHash[hash], Current: &cur, Latest: &next})
+	}
+	return updates, nil
+}
+
+// backup is what ApplyUpdates replaced, kept so Rollback can undo it
+type backup struct {
+	Mods     []instance.Mod `json:"mods"`      // instance records before the update
+	Replaced []string       `json:"replaced"`  // old jars, moved into the backup dir
+	Added    []string       `json:"installed"` // new jars in the mods folder
+}
+
+// BackupDir holds the jars replaced by the last applied update
+func BackupDir(store *instance.Store, inst *instance.Instance) string {
+	return filepath.Join(store.Dir(inst.ID), "mod-updates")
+}
+
+// HasBackup reports whether there is an update to roll back
+func HasBackup(store *instance.Store, inst *instance.Instance) bool {
+	_, err := os.Stat(filepath.Join(BackupDir(store, inst), backupManifest))
+	return err == nil
+}
+
+// ApplyUpdates downloads every update before touching the mods folder, then
+// swaps the jars, moving the old ones into a fresh backup that replaces
+// BackupDir once the swap has worked. If a swap fails the folder is put back
+// the way it was and the previous backup is kept. Disabled jars stay disabled.
+func ApplyUpdates(ctx context.Context, m *download.Manager, store *instance.Store, inst *instance.Instance, updates []Update, events chan<- download.Event) error {
+	if len(updates) == 0 {
+		return nil
+	}
+	modsDir := mods.Dir(store.GameDir(inst))
+	backupDir := BackupDir(store, inst)
+	// Runs that crashed half way leave their directory behind
+	if stale, err := filepath.Glob(backupDir + "-*"); err == nil {
+		for _, dir := range stale {
+			os.RemoveAll(dir)
+		}
+	}
+	runDir, err := os.MkdirTemp(filepath.Dir(backupDir), filepath.Base(backupDir)+"-")
+	if err != nil {
+		return err
+	}
+	stagingDir := filepath.Join(runDir, "staging")
+	if err := os.MkdirAll(stagingDir, 0755); err != nil {
+		os.RemoveAll(runDir)
+		return err
+	}
+
+	var files []download.File
+	for _, u := range updates {
+		f, name, err := u.Latest.jarFile()
+		if err != nil {
+			os.RemoveAll(runDir)
+			return err
+		}
+		newName := name
+		if !u.Mod.Enabled {
+			newName += mods.DisabledSuffix
+		}
+		// The jar being replaced may share the name, anything else is left alone
+		if target := filepath.Join(modsDir, newName); target != u.Mod.Path {
+			if _, err := os.Lstat(target); err == nil {
+				os.RemoveAll(runDir)
+				return fmt.Errorf("can't update %s, %s is already in the mods folder", u.Mod.FileName, newName)
+			}
+		}
+		files = append(files, download.File{
+			URL:    f.URL,
+			Path:   filepath.Join(stagingDir, name),
+			SHA1:   f.Hashes.SHA1,
+			SHA512: f.Hashes.SHA512,
+			Size:   f.Size,
+		})
+	}
+	if err := m.Download(ctx, files, events); err != nil {
+		os.RemoveAll(runDir)
+		return fmt.Errorf("failed to download updates: %v", err)
+	}
+
+	b := backup{Mods: append([]instance.Mod(nil), inst.Mods...)}
+	undo := func() {
+		for _, name := range b.Added {
+			os.Remove(filepath.Join(modsDir, name))
+		}
+		for _, name := range b.Replaced {
+			os.Rename(filepath.Join(runDir, name), filepath.Join(modsDir, name))
+		}
+		os.RemoveAll(runDir)
+	}
+
+	for i, u := range updates {
+		newName := filepath.Base(files[i].Path)
+		if !u.Mod.Enabled {
+			newName += mods.DisabledSuffix
+		}
+		if err := os.Rename(u.Mod.Path, filepath.Join(runDir, u.Mod.FileName)); err != nil {
+			undo()
+			return fmt.Errorf("failed to back up %s: %v", u.Mod.FileName, err)
+		}
+		b.Replaced = append(b.Replaced, u.Mod.FileName)
+		if err := os.Rename(files[i].Path, filepath.Join(modsDir, newName)); err != nil {
+			undo()
+			return fmt.Errorf("failed to install %s: %v", newName, err)
+		}
+		b.Added = append(b.Added, newName)
+	}
+	os.RemoveAll(stagingDir)
+
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err == nil {
+		err = os.WriteFile(filepath.Join(runDir, backupManifest), data, 0644)
+	}
+	if err == nil {
+		err = os.RemoveAll(backupDir)
+	}
+	if err == nil {
+		err = os.Rename(runDir, backupDir)
+	}
+	if err != nil {
+		undo()
+		return err
+	}
+
+	for i, u := range updates {
+		inst.RemoveMod(baseName(u.Mod))
+		inst.SetMod(instance.Mod{
+			FileName:  filepath.Base(files[i].Path),
+			ProjectID: u.Latest.ProjectID,
+			VersionID: u.Latest.ID,
+		})
+	}
+	return store.Save(inst)
+}
+
+// Rollback restores the jars and records replaced by the last ApplyUpdates
+func Rollback(store *instance.Store, inst *instance.Instance) error {
+	backupDir := BackupDir(store, inst)
+	data, err := os.ReadFile(filepath.Join(backupDir, backupManifest))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("there is no update to roll back")
+	}
+	if err != nil {
+		return err
+	}
+	var b backup
+	if err := json.Unmarshal(data, &b); err != nil {
+		return fmt.Errorf("failed to read update backup: %v", err)
+	}
+
+	modsDir := mods.Dir(store.GameDir(inst))
+	for _, name := range b.Added {
+		// The user may have toggled the jar since
+		os.Remove(filepath.Join(modsDir, name))
+		os.Remove(filepath.Join(modsDir, toggled(name)))
+	}
+	for _, name := range b.Replaced {
+		if err := os.Rename(filepath.Join(backupDir, name), filepath.Join(modsDir, name)); err != nil {
+			return fmt.Errorf("failed to restore %s: %v", name, err)
+		}
+	}
+
+	inst.Mods = b.Mods
+	if err := store.Save(inst); err != nil {
+		return err
+	}
+	return os.RemoveAll(backupDir)
+}
+
+// baseName is the jar's name without .disabled, which instance records use
+func baseName(m *mods.Mod) string {
+	return strings.TrimSuffix(m.FileName, mods.DisabledSuffix)
+}
+
+func toggled(name string) string {
+	if strings.HasSuffix(name, mods.DisabledSuffix) {
+		return strings.TrimSuffix(name, mods.DisabledSuffix)
+	}
+	return name + mods.DisabledSuffix
+}
+
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha1.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,245 @@
+package modrinth
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"Nix-Client-Launcher/internal/instance"
+	"Nix-Client-Launcher/internal/mods"
+)
+
+func TestCheckUpdates(t *testing.T) {
+	api := newFakeAPI(t)
+	api.addVersion("sodium", "sodium-1", "sodium 1", day)
+	api.addVersion("sodium", "sodium-2", "sodium 2", day.Add(24*time.Hour))
+	// Newer, but for another Minecraft version
+	api.addVersion("sodium", "sodium-3", "sodium 3", day.Add(48*time.Hour))
+	api.edit("sodium-3", func(v *Version) { v.GameVersions = []string{"1.21.10"} })
+	// Only the Quilt build has moved on
+	api.addVersion("iris", "iris-1", "iris 1", day)
+	api.addVersion("iris", "iris-2", "iris 2", day.Add(24*time.Hour))
+	api.edit("iris-2", func(v *Version) { v.Loaders = []string{"quilt"} })
+	// Pinned jars are left alone even with an update out
+	api.addVersion("lithium", "lithium-1", "lithium 1", day)
+	api.addVersion("lithium", "lithium-2", "lithium 2", day.Add(24*time.Hour))
+
+	store, inst := newInstance(t)
+	addJar(t, store, inst, "sodium-1.jar.disabled", "sodium 1")
+	addJar(t, store, inst, "iris-1.jar", "iris 1")
+	addJar(t, store, inst, "lithium-1.jar", "lithium 1")
+	inst.SetPinned("lithium-1.jar", true)
+	addJar(t, store, inst, "handmade.jar", "not on modrinth")
+
+	updates, err := api.client().CheckUpdates(context.Background(), store, inst)
+	if err != nil {
+		t.Fatalf("CheckUpdates: %v", err)
+	}
+	if len(updates) != 1 {
+		t.Fatalf("got %d updates, want only sodium: %+v", len(updates), updates)
+	}
+	u := updates[0]
+	if u.Mod.FileName != "sodium-1.jar.disabled" || u.Current.ID != "sodium-1" || u.Latest.ID != "sodium-2" {
+		t.Errorf("update = %s from %s to %s, want sodium-1.jar.disabled from sodium-1 to sodium-2", u.Mod.FileName, u.Current.ID, u.Latest.ID)
+	}
+	if !api.requested("POST /version_files/update") {
+		t.Error("the version_files/update endpoint was not used")
+	}
+}
+
+func TestCheckUpdatesWithoutJars(t *testing.T) {
+	api := newFakeAPI(t)
+	store, inst := newInstance(t)
+	addJar(t, store, inst, "pinned.jar", "pinned")
+	inst.SetPinned("pinned.jar", true)
+
+	updates, err := api.client().CheckUpdates(context.Background(), store, inst)
+	if err != nil || len(updates) != 0 {
+		t.Fatalf("CheckUpdates = %+v, %v, want nothing", updates, err)
+	}
+	if api.requested("POST") {
+		t.Error("Modrinth was asked about an empty set of hashes")
+	}
+}
+
+// checkAndApply finds the updates for inst and applies all of them
+func checkAndApply(t *testing.T, api *fakeAPI, store *instance.Store, inst *instance.Instance) error {
+	t.Helper()
+	updates, err := api.client().CheckUpdates(context.Background(), store, inst)
+	if err != nil {
+		t.Fatalf("CheckUpdates: %v", err)
+	}
+	return ApplyUpdates(context.Background(), testManager(), store, inst, updates, nil)
+}
+
+func TestApplyUpdatesAndRollback(t *testing.T) {
+	api := newFakeAPI(t)
+	api.addVersion("sodium", "sodium-1", "sodium 1", day)
+	api.addVersion("sodium", "sodium-2", "sodium 2", day.Add(24*time.Hour))
+	api.addVersion("iris", "iris-1", "iris 1", day)
+	api.addVersion("iris", "iris-2", "iris 2", day.Add(24*time.Hour))
+	store, inst := newInstance(t)
+	addJar(t, store, inst, "sodium-1.jar", "sodium 1")
+	inst.SetMod(instance.Mod{FileName: "sodium-1.jar", ProjectID: "sodium", VersionID: "sodium-1"})
+	addJar(t, store, inst, "iris-1.jar.disabled", "iris 1")
+	original := append([]instance.Mod(nil), inst.Mods...)
+	dir := mods.Dir(store.GameDir(inst))
+
+	if HasBackup(store, inst) {
+		t.Fatal("a new instance has an update backup")
+	}
+	if err := checkAndApply(t, api, store, inst); err != nil {
+		t.Fatalf("ApplyUpdates: %v", err)
+	}
+
+	jars, err := mods.List(store.GameDir(inst))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, m := range jars {
+		names = append(names, m.FileName)
+	}
+	// The disabled jar stays disabled
+	if want := []string{"iris-iris-2.jar.disabled", "sodium-sodium-2.jar"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("mods after the update = %v, want %v", names, want)
+	}
+	saved, err := store.Get(inst.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(saved.Mods, func(i, j int) bool { return saved.Mods[i].FileName < saved.Mods[j].FileName })
+	want := []instance.Mod{
+		{FileName: "iris-iris-2.jar", ProjectID: "iris", VersionID: "iris-2"},
+		{FileName: "sodium-sodium-2.jar", ProjectID: "sodium", VersionID: "sodium-2"},
+	}
+	if !reflect.DeepEqual(saved.Mods, want) {
+		t.Errorf("saved mods = %+v, want %+v", saved.Mods, want)
+	}
+	if !HasBackup(store, inst) {
+		t.Fatal("no backup after an update")
+	}
+
+	if err := Rollback(store, inst); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	for name, content := range map[string]string{"sodium-1.jar": "sodium 1", "iris-1.jar.disabled": "iris 1"} {
+		if data, err := os.ReadFile(filepath.Join(dir, name)); err != nil || string(data) != content {
+			t.Errorf("%s = %q, %v after rollback", name, data, err)
+		}
+	}
+	if jars, _ := mods.List(store.GameDir(inst)); len(jars) != 2 {
+		t.Errorf("%d jars after rollback, want 2", len(jars))
+	}
+	if saved, _ := store.Get(inst.ID); !reflect.DeepEqual(saved.Mods, original) {
+		t.Errorf("saved mods after rollback = %+v, want %+v", saved.Mods, original)
+	}
+	if HasBackup(store, inst) {
+		t.Error("the backup is still there after rollback")
+	}
+}
+
+func TestApplyUpdatesRefusesBadFiles(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(v *Version)
+		jar    string // already in mods/ besides the one being updated
+		want   string
+	}{
+		{"dot dot name", func(v *Version) { v.Files[0].Filename = ".." }, "", "unexpected file name"},
+		{"not a jar", func(v *Version) { v.Files[0].Filename = "sodium.zip" }, "", "unexpected file name"},
+		{"no files", func(v *Version) { v.Files = nil }, "", "has no files"},
+		{"name taken by another jar", nil, "sodium-sodium-2.jar", "already in the mods folder"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newFakeAPI(t)
+			api.addVersion("sodium", "sodium-1", "sodium 1", day)
+			api.addVersion("sodium", "sodium-2", "sodium 2", day.Add(24*time.Hour))
+			if tt.change != nil {
+				api.edit("sodium-2", tt.change)
+			}
+			store, inst := newInstance(t)
+			addJar(t, store, inst, "sodium-1.jar", "sodium 1")
+			if tt.jar != "" {
+				addJar(t, store, inst, tt.jar, "someone else's jar")
+			}
+			dir := mods.Dir(store.GameDir(inst))
+			before, _ := os.ReadDir(dir)
+
+			err := checkAndApply(t, api, store, inst)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("ApplyUpdates = %v, want an error containing %q", err, tt.want)
+			}
+			if after, _ := os.ReadDir(dir); len(after) != len(before) {
+				t.Errorf("the mods folder went from %d to %d entries", len(before), len(after))
+			}
+			if tt.jar != "" {
+				if data, _ := os.ReadFile(filepath.Join(dir, tt.jar)); string(data) != "someone else's jar" {
+					t.Errorf("%s was overwritten", tt.jar)
+				}
+			}
+			if leftovers, _ := filepath.Glob(BackupDir(store, inst) + "*"); len(leftovers) != 0 {
+				t.Errorf("a refused update left %v behind", leftovers)
+			}
+		})
+	}
+}
+
+func TestApplyUpdatesKeepsBackupOnFailure(t *testing.T) {
+	api := newFakeAPI(t)
+	v2 := api.addVersion("sodium", "sodium-2", "sodium 2", day)
+	v3 := api.addVersion("sodium", "sodium-3", "sodium 3", day.Add(24*time.Hour))
+	store, inst := newInstance(t)
+	addJar(t, store, inst, "sodium-1.jar", "sodium 1")
+	inst.SetMod(instance.Mod{FileName: "sodium-1.jar", ProjectID: "sodium", VersionID: "sodium-1"})
+	original := append([]instance.Mod(nil), inst.Mods...)
+
+	update := func(latest Version) error {
+		jars, err := mods.List(store.GameDir(inst))
+		if err != nil || len(jars) != 1 {
+			t.Fatalf("List = %d jars, %v", len(jars), err)
+		}
+		current := Version{ID: inst.Mods[0].VersionID, ProjectID: "sodium"}
+		return ApplyUpdates(context.Background(), testManager(), store, inst, []Update{{Mod: jars[0], Current: &current, Latest: &latest}}, nil)
+	}
+	if err := update(v2); err != nil {
+		t.Fatalf("ApplyUpdates: %v", err)
+	}
+
+	// The second update can't be downloaded, the first one's backup must survive
+	api.mu.Lock()
+	delete(api.files, "sodium-sodium-3.jar")
+	api.mu.Unlock()
+	if err := update(v3); err == nil {
+		t.Fatal("ApplyUpdates succeeded without its jar")
+	}
+	if !HasBackup(store, inst) {
+		t.Fatal("a failed update removed the previous backup")
+	}
+	if leftovers, _ := filepath.Glob(BackupDir(store, inst) + "-*"); len(leftovers) != 0 {
+		t.Errorf("a failed update left %v behind", leftovers)
+	}
+
+	if err := Rollback(store, inst); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(mods.Dir(store.GameDir(inst)), "sodium-1.jar"))
+	if err != nil || string(data) != "sodium 1" {
+		t.Errorf("sodium-1.jar = %q, %v after rollback", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(mods.Dir(store.GameDir(inst)), "sodium-sodium-2.jar")); !os.IsNotExist(err) {
+		t.Error("the updated jar is still there after rollback")
+	}
+	if !reflect.DeepEqual(inst.Mods, original) {
+		t.Errorf("mods after rollback = %+v, want %+v", inst.Mods, original)
+	}
+	if HasBackup(store, inst) {
+		t.Error("the backup is still there after rollback")
+	}
+}
@@ -0,0 +1,270 @@
+package mods
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Severity int
+
+const (
+	SeverityError   Severity = iota // the game will refuse to start or crash
+	SeverityWarning                 // worth knowing, the game may still run
+)
+
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// Problem is one finding, about Mod when it concerns a single jar
+type Problem struct {
+	Severity Severity
+	Mod      *Mod
+	Message  string
+}
+
+// Report is what Analyze found, errors first
+type Report struct {
+	Problems []Problem
+}
+
+// HasErrors reports whether the game is expected to fail
+func (r *Report) HasErrors() bool {
+	for _, p := range r.Problems {
+		if p.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// String renders the report for people, one problem per line
+func (r *Report) String() string {
+	if len(r.Problems) == 0 {
+		return "No problems found."
+	}
+	var b strings.Builder
+	for i, p := range r.Problems {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		if p.Severity == SeverityError {
+			b.WriteString("Error: ")
+		} else {
+			b.WriteString("Warning: ")
+		}
+		if p.Mod != nil {
+			fmt.Fprintf(&b, "%s (%s): ", p.Mod.DisplayName(), p.Mod.FileName)
+		}
+		b.WriteString(p.Message)
+	}
+	return b.String()
+}
+
+// Environment is what the instance provides besides its mods
+type Environment struct {
+	MinecraftVersion string
+	Loader           string // LoaderFabric, LoaderQuilt, ...
+	LoaderVersion    string // "" when unknown, loader ranges are then not checked
+	JavaVersion      int    // major version, 0 when unknown
+}
+
+// provider is one mod or built-in answering to an id
+type provider struct {
+	id      string
+	mod     *Mod // nil for built-ins like minecraft
+	version string
+}
+
+// Analyze checks the enabled mods against each other and env before launch:
+// duplicate ids, missing or mismatched dependencies, breaks and conflicts,
+// and mods made for another loader
+func Analyze(installed []*Mod, env Environment) *Report {
+	r := &Report{}
+	providers := map[string][]provider{}
+	add := func(id string, p provider) {
+		providers[id] = append(providers[id], p)
+	}
+
+	add("minecraft", provider{id: "minecraft", version: env.MinecraftVersion})
+	if env.JavaVersion > 0 {
+		add("java", provider{id: "java", version: fmt.Sprint(env.JavaVersion)})
+	}
+	for _, id := range loaderIDs(env.Loader) {
+		add(id, provider{id: id, version: env.LoaderVersion})
+	}
+
+	var enabled []*Mod
+	topLevel := map[string][]*Mod{}
+	for _, m := range installed {
+		if !m.Enabled {
+			continue
+		}
+		if m.ID == "" {
+			r.add(SeverityWarning, m, "no mod metadata found, it is not a mod or was made for a loader the launcher can't read")
+			continue
+		}
+		if !loaderRuns(env.Loader, m.Loader) {
+			r.add(SeverityError, m, fmt.Sprintf("is a %s mod, this instance uses %s", loaderName(m.Loader), loaderName(env.Loader)))
+			continue
+		}
+		topLevel[m.ID] = append(topLevel[m.ID], m)
+		for _, mod := range flatten(m) {
+			enabled = append(enabled, mod)
+			p := provider{id: mod.ID, mod: mod, version: mod.Version}
+			add(mod.ID, p)
+			for _, id := range mod.Provides {
+				add(id, p)
+			}
+		}
+	}
+
+	ids := make([]string, 0, len(topLevel))
+	for id := range topLevel {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if dups := topLevel[id]; len(dups) > 1 {
+			var names []string
+			for _, m := range dups {
+				names = append(names, m.FileName)
+			}
+			r.add(SeverityError, dups[0], fmt.Sprintf("mod id %s is in %d jars: %s", id, len(dups), strings.Join(names, ", ")))
+		}
+	}
+
+	for _, m := range enabled {
+		for _, dep := range m.Dependencies {
+			// With no known runtime there is nothing to hold java ranges against
+			if dep.ID == "java" && env.JavaVersion == 0 {
+				continue
+			}
+			r.checkDependency(m, dep, providers[dep.ID])
+		}
+	}
+
+	sort.SliceStable(r.Problems, func(a, b int) bool {
+		return r.Problems[a].Severity < r.Problems[b].Severity
+	})
+	return r
+}
+
+func (r *Report) checkDependency(m *Mod, dep Dependency, candidates []provider) {
+	var matching, other []provider
+	for _, p := range candidates {
+		if p.mod == m {
+			continue
+		}
+		if p.version == "" {
+			// Known to be there but not which version, give it the benefit of the doubt
+			matching = append(matching, p)
+			continue
+		}
+		if match, ok := matchesAny(m.Loader, p.version, dep.Versions); match || !ok {
+			matching = append(matching, p)
+		} else {
+			other = append(other, p)
+		}
+	}
+	wanted := versionText(dep.Versions)
+
+	switch dep.Kind {
+	case Depends:
+		if len(matching) > 0 {
+			return
+		}
+		if len(other) == 0 {
+			r.add(SeverityError, m, fmt.Sprintf("requires %s %s, which is not installed", dep.ID, wanted))
+			return
+		}
+		r.add(SeverityError, m, fmt.Sprintf("requires %s %s, but %s is installed", dep.ID, wanted, describe(other[0])))
+	case Recommends:
+		if len(matching) == 0 {
+			r.add(SeverityWarning, m, fmt.Sprintf("recommends %s %s", dep.ID, wanted))
+		}
+	case Breaks:
+		for _, p := range matching {
+			r.add(SeverityError, m, fmt.Sprintf("does not work with %s", describe(p)))
+		}
+	case Conflicts:
+		for _, p := range matching {
+			r.add(SeverityWarning, m, fmt.Sprintf("is known to have problems with %s", describe(p)))
+		}
+	}
+}
+
+func (r *Report) add(s Severity, m *Mod, message string) {
+	r.Problems = append(r.Problems, Problem{Severity: s, Mod: m, Message: message})
+}
+
+// flatten returns m and every mod nested in it
+func flatten(m *Mod) []*Mod {
+	mods := []*Mod{m}
+	for _, n := range m.Nested {
+		mods = append(mods, flatten(n)...)
+	}
+	return mods
+}
+
+// loaderIDs are the mod ids a loader answers to itself
+func loaderIDs(loader string) []string {
+	switch loader {
+	case LoaderFabric:
+		return []string{"fabricloader"}
+	case LoaderQuilt:
+		return []string{"quilt_loader", "fabricloader"}
+	case LoaderForge:
+		return []string{"forge"}
+	case LoaderNeoForge:
+		return []string{"neoforge"}
+	}
+	return nil
+}
+
+// loaderRuns reports whether instances using loader can load a mod made for
+// modLoader. Quilt loads Fabric mods.
+func loaderRuns(loader, modLoader string) bool {
+	return loader == modLoader || (loader == LoaderQuilt && modLoader == LoaderFabric)
+}
+
+func loaderName(loader string) string {
+	switch loader {
+	case LoaderFabric:
+		return "Fabric"
+	case LoaderQuilt:
+		return "Quilt"
+	case LoaderForge:
+		return "Forge"
+	case LoaderNeoForge:
+		return "NeoForge"
+	case "":
+		return "vanilla"
+	}
+	return loader
+}
+
+func versionText(ranges []string) string {
+	if len(ranges) == 0 {
+		return "(any version)"
+	}
+	return "(" + strings.Join(ranges, " or ") + ")"
+}
+
+func describe(p provider) string {
+	if p.mod == nil {
+		return strings.TrimSpace(p.id + " " + p.version)
+	}
+	name := p.mod.DisplayName()
+	if p.version != "" {
+		name += " " + p.version
+	}
+	if strings.Contains(p.mod.Path, "!/") {
+		name += " (bundled in another mod)"
+	}
+	return name
+}
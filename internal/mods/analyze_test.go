@@ -0,0 +1,241 @@
+package mods
+
+import (
+	"strings"
+	"testing"
+)
+
+func fabricMod(id, version string, deps ...Dependency) *Mod {
+	return &Mod{FileName: id + ".jar", Enabled: true, Loader: LoaderFabric, ID: id, Version: version, Dependencies: deps}
+}
+
+func TestAnalyze(t *testing.T) {
+	dep := func(kind, id string, versions ...string) Dependency {
+		return Dependency{ID: id, Kind: kind, Versions: versions}
+	}
+	withFile := func(m *Mod, name string) *Mod {
+		m.FileName = name
+		return m
+	}
+	nested := func(m *Mod, children ...*Mod) *Mod {
+		for _, c := range children {
+			c.Path = m.FileName + "!/META-INF/jars/" + c.FileName
+		}
+		m.Nested = children
+		return m
+	}
+	disabled := func(m *Mod) *Mod {
+		m.Enabled = false
+		return m
+	}
+	quiltMod := func(id, version string, deps ...Dependency) *Mod {
+		m := fabricMod(id, version, deps...)
+		m.Loader = LoaderQuilt
+		return m
+	}
+	forgeMod := func(id string) *Mod {
+		m := fabricMod(id, "1.0")
+		m.Loader = LoaderForge
+		return m
+	}
+
+	tests := []struct {
+		name   string
+		loader string
+		mods   []*Mod
+		want   []string // a substring of each problem, in order
+	}{
+		{
+			name: "nothing wrong",
+			mods: []*Mod{
+				fabricMod("sodium", "0.6.0", dep(Depends, "minecraft", "~1.21"), dep(Depends, "fabricloader", ">=0.16")),
+				fabricMod("iris", "1.8.0", dep(Depends, "sodium", ">=0.6")),
+			},
+		},
+		{
+			name: "duplicate top-level ids",
+			mods: []*Mod{withFile(fabricMod("sodium", "0.5.0"), "sodium-old.jar"), withFile(fabricMod("sodium", "0.6.0"), "sodium-new.jar")},
+			want: []string{"mod id sodium is in 2 jars: sodium-old.jar, sodium-new.jar"},
+		},
+		{
+			name: "same library nested twice is fine",
+			mods: []*Mod{
+				nested(fabricMod("a", "1.0"), fabricMod("cloth-config", "15.0")),
+				nested(fabricMod("b", "1.0"), fabricMod("cloth-config", "15.0")),
+			},
+		},
+		{
+			name: "disabled duplicate is ignored",
+			mods: []*Mod{fabricMod("sodium", "0.6.0"), disabled(fabricMod("sodium", "0.5.0"))},
+		},
+		{
+			name: "missing dependency",
+			mods: []*Mod{fabricMod("iris", "1.8.0", dep(Depends, "sodium", ">=0.6"))},
+			want: []string{"requires sodium (>=0.6), which is not installed"},
+		},
+		{
+			name: "dependency only in a disabled jar",
+			mods: []*Mod{fabricMod("iris", "1.8.0", dep(Depends, "sodium")), disabled(fabricMod("sodium", "0.6.0"))},
+			want: []string{"requires sodium (any version), which is not installed"},
+		},
+		{
+			name: "dependency too old",
+			mods: []*Mod{fabricMod("iris", "1.8.0", dep(Depends, "sodium", ">=0.6")), fabricMod("sodium", "0.5.11")},
+			want: []string{"requires sodium (>=0.6), but sodium 0.5.11 is installed"},
+		},
+		{
+			name: "wrong minecraft version",
+			mods: []*Mod{fabricMod("sodium", "0.6.0", dep(Depends, "minecraft", "1.20.x"))},
+			want: []string{"requires minecraft (1.20.x), but minecraft 1.21.11 is installed"},
+		},
+		{
+			name: "nested provider satisfies depends",
+			mods: []*Mod{
+				fabricMod("modmenu", "15.0", dep(Depends, "fabric-screen-api-v1")),
+				nested(fabricMod("fabric-api", "0.130.0"), fabricMod("fabric-screen-api-v1", "2.0")),
+			},
+		},
+		{
+			name: "provides satisfies depends",
+			mods: []*Mod{
+				fabricMod("addon", "1.0", dep(Depends, "optifabric_compat")),
+				func() *Mod { m := fabricMod("compat", "1.0"); m.Provides = []string{"optifabric_compat"}; return m }(),
+			},
+		},
+		{
+			name: "a mod doesn't satisfy its own dependency",
+			mods: []*Mod{func() *Mod {
+				m := fabricMod("self", "1.0", dep(Depends, "self_api"))
+				m.Provides = []string{"self_api"}
+				return m
+			}()},
+			want: []string{"requires self_api (any version), which is not installed"},
+		},
+		{
+			name: "breaks",
+			mods: []*Mod{fabricMod("sodium", "0.6.0", dep(Breaks, "optifabric")), fabricMod("optifabric", "1.14")},
+			want: []string{"does not work with optifabric 1.14"},
+		},
+		{
+			name: "breaks another version",
+			mods: []*Mod{fabricMod("sodium", "0.6.0", dep(Breaks, "iris", "<1.7")), fabricMod("iris", "1.8.0")},
+		},
+		{
+			name: "breaks a bundled mod",
+			mods: []*Mod{
+				fabricMod("sodium", "0.6.0", dep(Breaks, "old-lib")),
+				nested(fabricMod("pack", "1.0"), fabricMod("old-lib", "0.1")),
+			},
+			want: []string{"does not work with old-lib 0.1 (bundled in another mod)"},
+		},
+		{
+			name: "conflicts is a warning",
+			mods: []*Mod{fabricMod("sodium", "0.6.0", dep(Conflicts, "lithium", "<0.13")), fabricMod("lithium", "0.12.0")},
+			want: []string{"is known to have problems with lithium 0.12.0"},
+		},
+		{
+			name: "recommends is a warning",
+			mods: []*Mod{fabricMod("iris", "1.8.0", dep(Recommends, "sodium-extra"))},
+			want: []string{"recommends sodium-extra (any version)"},
+		},
+		{
+			name: "wrong loader",
+			mods: []*Mod{forgeMod("jei"), quiltMod("qsl", "1.0")},
+			want: []string{"is a Forge mod, this instance uses Fabric", "is a Quilt mod, this instance uses Fabric"},
+		},
+		{
+			name:   "quilt runs fabric mods",
+			loader: LoaderQuilt,
+			mods: []*Mod{
+				fabricMod("sodium", "0.6.0", dep(Depends, "fabricloader")),
+				quiltMod("qsl", "1.0", dep(Depends, "quilt_loader"), dep(Depends, "sodium")),
+			},
+		},
+		{
+			name:   "fabric mods on forge",
+			loader: LoaderForge,
+			mods:   []*Mod{fabricMod("sodium", "0.6.0")},
+			want:   []string{"is a Fabric mod, this instance uses Forge"},
+		},
+		{
+			name: "jar without metadata",
+			mods: []*Mod{{FileName: "library.jar", Enabled: true}},
+			want: []string{"no mod metadata found"},
+		},
+		{
+			name: "errors come before warnings",
+			mods: []*Mod{
+				fabricMod("a", "1.0", dep(Recommends, "b")),
+				fabricMod("c", "1.0", dep(Depends, "d")),
+			},
+			want: []string{"requires d", "recommends b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := tt.loader
+			if loader == "" {
+				loader = LoaderFabric
+			}
+			r := Analyze(tt.mods, Environment{MinecraftVersion: "1.21.11", Loader: loader, LoaderVersion: "0.17.2"})
+			if len(r.Problems) != len(tt.want) {
+				t.Fatalf("problems:\n%s\nwant %q", r, tt.want)
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(r.Problems[i].Message, want) {
+					t.Errorf("problem %d = %q, want %q", i, r.Problems[i].Message, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAnalyzeJava(t *testing.T) {
+	installed := []*Mod{fabricMod("sodium", "0.6.0", Dependency{ID: "java", Kind: Depends, Versions: []string{">=21"}})}
+
+	tests := []struct {
+		name string
+		java int
+		want string // "" for no problems
+	}{
+		{"unknown runtime", 0, ""},
+		{"new enough", 21, ""},
+		{"too old", 17, "requires java (>=21), but java 17 is installed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Analyze(installed, Environment{MinecraftVersion: "1.21.11", Loader: LoaderFabric, JavaVersion: tt.java})
+			if tt.want == "" {
+				if len(r.Problems) != 0 {
+					t.Errorf("problems = %s", r)
+				}
+				return
+			}
+			if len(r.Problems) != 1 || !strings.Contains(r.Problems[0].Message, tt.want) {
+				t.Errorf("problems = %s, want %q", r, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseQuiltSkipsMalformedDependencies(t *testing.T) {
+	data := `{"quilt_loader": {
+		"id": "example",
+		"version": "1.0.0",
+		"depends": ["minecraft", 42, {"versions": ">=1.0"}, {"id": "quilted_fabric_api", "optional": true}],
+		"breaks": [{"versions": "*"}]
+	}}`
+	var m Mod
+	if _, err := parseQuilt(&m, []byte(data)); err != nil {
+		t.Fatalf("parseQuilt: %v", err)
+	}
+	if len(m.Dependencies) != 2 || m.Dependencies[0].ID != "minecraft" || m.Dependencies[1].ID != "quilted_fabric_api" {
+		t.Fatalf("dependencies = %+v, want minecraft and quilted_fabric_api", m.Dependencies)
+	}
+
+	m.Enabled = true
+	r := Analyze([]*Mod{&m}, Environment{MinecraftVersion: "1.21.11", Loader: LoaderQuilt})
+	if len(r.Problems) != 0 {
+		t.Errorf("problems = %s", r)
+	}
+}
@@ -0,0 +1,249 @@
+package mods
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type fabricModJSON struct {
+	SchemaVersion int                        `json:"schemaVersion"`
+	ID            string                     `json:"id"`
+	Version       string                     `json:"version"`
+	Name          string                     `json:"name"`
+	Description   string                     `json:"description"`
+	Authors       []json.RawMessage          `json:"authors"`
+	Icon          json.RawMessage            `json:"icon"`
+	Provides      []string                   `json:"provides"`
+	Depends       map[string]json.RawMessage `json:"depends"`
+	Recommends    map[string]json.RawMessage `json:"recommends"`
+	Suggests      map[string]json.RawMessage `json:"suggests"`
+	Breaks        map[string]json.RawMessage `json:"breaks"`
+	Conflicts     map[string]json.RawMessage `json:"conflicts"`
+	Jars          []struct {
+		File string `json:"file"`
+	} `json:"jars"`
+}
+
+// parseFabric fills m from fabric.mod.json and returns the nested jars it lists
+func parseFabric(m *Mod, data []byte) ([]string, error) {
+	var meta fabricModJSON
+	if err := json.Unmarshal(sanitizeJSON(data), &meta); err != nil {
+		return nil, err
+	}
+	if meta.ID == "" {
+		return nil, fmt.Errorf("no mod id")
+	}
+
+	m.Loader = LoaderFabric
+	m.ID = meta.ID
+	m.Name = meta.Name
+	m.Version = meta.Version
+	m.Description = meta.Description
+	m.Provides = meta.Provides
+	m.Icon = iconPath(meta.Icon)
+	for _, raw := range meta.Authors {
+		if name := personName(raw); name != "" {
+			m.Authors = append(m.Authors, name)
+		}
+	}
+
+	for _, field := range []struct {
+		kind string
+		deps map[string]json.RawMessage
+	}{
+		{Depends, meta.Depends},
+		{Recommends, meta.Recommends},
+		{Suggests, meta.Suggests},
+		{Breaks, meta.Breaks},
+		{Conflicts, meta.Conflicts},
+	} {
+		ids := make([]string, 0, len(field.deps))
+		for id := range field.deps {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			m.Dependencies = append(m.Dependencies, Dependency{ID: id, Kind: field.kind, Versions: stringOrList(field.deps[id])})
+		}
+	}
+
+	var jars []string
+	for _, j := range meta.Jars {
+		jars = append(jars, j.File)
+	}
+	return jars, nil
+}
+
+type quiltModJSON struct {
+	SchemaVersion int `json:"schema_version"`
+	QuiltLoader   struct {
+		ID       string            `json:"id"`
+		Version  string            `json:"version"`
+		Provides []json.RawMessage `json:"provides"`
+		Depends  []json.RawMessage `json:"depends"`
+		Breaks   []json.RawMessage `json:"breaks"`
+		Jars     []string          `json:"jars"`
+		Metadata struct {
+			Name         string            `json:"name"`
+			Description  string            `json:"description"`
+			Contributors map[string]string `json:"contributors"`
+			Icon         json.RawMessage   `json:"icon"`
+		} `json:"metadata"`
+	} `json:"quilt_loader"`
+}
+
+type quiltDependency struct {
+	ID       string          `json:"id"`
+	Versions json.RawMessage `json:"versions"`
+	Optional bool            `json:"optional"`
+}
+
+// parseQuilt fills m from quilt.mod.json and returns the nested jars it lists
+func parseQuilt(m *Mod, data []byte) ([]string, error) {
+	var meta quiltModJSON
+	if err := json.Unmarshal(sanitizeJSON(data), &meta); err != nil {
+		return nil, err
+	}
+	q := meta.QuiltLoader
+	if q.ID == "" {
+		return nil, fmt.Errorf("no mod id")
+	}
+
+	m.Loader = LoaderQuilt
+	m.ID = q.ID
+	m.Name = q.Metadata.Name
+	m.Version = q.Version
+	m.Description = q.Metadata.Description
+	m.Icon = iconPath(q.Metadata.Icon)
+	for name := range q.Metadata.Contributors {
+		m.Authors = append(m.Authors, name)
+	}
+	sort.Strings(m.Authors)
+	for _, raw := range q.Provides {
+		if dep := quiltDep(raw); dep.ID != "" {
+			m.Provides = append(m.Provides, dep.ID)
+		}
+	}
+	// Entries that aren't a string or an object with an id are skipped rather
+	// than reported as a dependency on nothing
+	for _, raw := range q.Depends {
+		dep := quiltDep(raw)
+		if dep.ID == "" {
+			continue
+		}
+		kind := Depends
+		if dep.Optional {
+			kind = Suggests
+		}
+		m.Dependencies = append(m.Dependencies, Dependency{ID: dep.ID, Kind: kind, Versions: stringOrList(dep.Versions)})
+	}
+	for _, raw := range q.Breaks {
+		dep := quiltDep(raw)
+		if dep.ID == "" {
+			continue
+		}
+		m.Dependencies = append(m.Dependencies, Dependency{ID: dep.ID, Kind: Breaks, Versions: stringOrList(dep.Versions)})
+	}
+	return q.Jars, nil
+}
+
+// quiltDep reads a dependency given either as "id" or as an object
+func quiltDep(raw json.RawMessage) quiltDependency {
+	var id string
+	if json.Unmarshal(raw, &id) == nil {
+		return quiltDependency{ID: id}
+	}
+	var dep quiltDependency
+	json.Unmarshal(raw, &dep)
+	return dep
+}
+
+// stringOrList reads a version range given as a string or a list of
+// alternatives. "*" and a missing value both mean any version.
+func stringOrList(raw json.RawMessage) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+	var one string
+	if json.Unmarshal(raw, &one) == nil {
+		if one == "" || one == "*" {
+			return nil
+		}
+		return []string{one}
+	}
+	var list []string
+	json.Unmarshal(raw, &list)
+	for _, v := range list {
+		if v == "*" {
+			return nil
+		}
+	}
+	return list
+}
+
+// personName reads an author given as "name" or {"name": ...}
+func personName(raw json.RawMessage) string {
+	var name string
+	if json.Unmarshal(raw, &name) == nil {
+		return name
+	}
+	var person struct {
+		Name string `json:"name"`
+	}
+	json.Unmarshal(raw, &person)
+	return person.Name
+}
+
+// iconPath reads an icon given as a path or as a map of sizes to paths,
+// picking the largest
+func iconPath(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var single string
+	if json.Unmarshal(raw, &single) == nil {
+		return single
+	}
+	var sizes map[string]string
+	if json.Unmarshal(raw, &sizes) != nil {
+		return ""
+	}
+	best, bestSize := "", -1
+	for size, p := range sizes {
+		n, _ := strconv.Atoi(size)
+		if n > bestSize {
+			best, bestSize = p, n
+		}
+	}
+	return best
+}
+
+// sanitizeJSON replaces raw newlines and tabs inside strings, which Fabric's
+// lenient parser accepts and plenty of published mods rely on
+func sanitizeJSON(data []byte) []byte {
+	var b strings.Builder
+	inString, escaped := false, false
+	for _, c := range string(data) {
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case inString && c == '\n':
+			b.WriteString(`\n`)
+			continue
+		case inString && c == '\t':
+			b.WriteString(`\t`)
+			continue
+		case inString && c == '\r':
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return []byte(b.String())
+}
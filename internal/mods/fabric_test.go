@@ -0,0 +1,161 @@
+package mods
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseFabric(t *testing.T) {
+	data := `{
+		"schemaVersion": 1,
+		"id": "example",
+		"version": "1.2.0",
+		"name": "Example",
+		"description": "Two
+lines",
+		"authors": ["Alice", {"name": "Bob", "contact": {}}, {"contact": {}}],
+		"icon": "assets/example/icon.png",
+		"provides": ["example_api"],
+		"depends": {"minecraft": "~1.21", "fabricloader": ">=0.16", "fabric-api": "*"},
+		"recommends": {"modmenu": ["1.x", "2.x"]},
+		"breaks": {"optifabric": "*"},
+		"conflicts": {"sodium": "<0.5"},
+		"jars": [{"file": "META-INF/jars/lib.jar"}]
+	}`
+	var m Mod
+	jars, err := parseFabric(&m, []byte(data))
+	if err != nil {
+		t.Fatalf("parseFabric: %v", err)
+	}
+	want := Mod{
+		Loader:      LoaderFabric,
+		ID:          "example",
+		Name:        "Example",
+		Version:     "1.2.0",
+		Description: "Two\nlines",
+		Authors:     []string{"Alice", "Bob"},
+		Icon:        "assets/example/icon.png",
+		Provides:    []string{"example_api"},
+		Dependencies: []Dependency{
+			{ID: "fabric-api", Kind: Depends},
+			{ID: "fabricloader", Kind: Depends, Versions: []string{">=0.16"}},
+			{ID: "minecraft", Kind: Depends, Versions: []string{"~1.21"}},
+			{ID: "modmenu", Kind: Recommends, Versions: []string{"1.x", "2.x"}},
+			{ID: "optifabric", Kind: Breaks},
+			{ID: "sodium", Kind: Conflicts, Versions: []string{"<0.5"}},
+		},
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("mod = %+v\nwant  %+v", m, want)
+	}
+	if !reflect.DeepEqual(jars, []string{"META-INF/jars/lib.jar"}) {
+		t.Errorf("jars = %v", jars)
+	}
+}
+
+func TestParseFabricErrors(t *testing.T) {
+	for _, data := range []string{`{"schemaVersion": 1}`, `{"id": `, `[]`} {
+		var m Mod
+		if _, err := parseFabric(&m, []byte(data)); err == nil {
+			t.Errorf("parseFabric(%s) = %+v, want an error", data, m)
+		}
+	}
+}
+
+func TestParseQuilt(t *testing.T) {
+	data := `{
+		"schema_version": 1,
+		"quilt_loader": {
+			"id": "example",
+			"version": "2.0.0",
+			"provides": ["example_api", {"id": "example_compat", "version": "1.0"}],
+			"depends": [
+				"quilt_loader",
+				{"id": "minecraft", "versions": ">=1.21"},
+				{"id": "modmenu", "versions": ["1.x", "2.x"], "optional": true}
+			],
+			"breaks": [{"id": "optifabric", "versions": "*"}],
+			"jars": ["META-INF/jars/lib.jar"],
+			"metadata": {
+				"name": "Example",
+				"description": "A mod",
+				"contributors": {"Bob": "Artist", "Alice": "Owner"},
+				"icon": {"16": "small.png", "128": "large.png", "64": "medium.png"}
+			}
+		}
+	}`
+	var m Mod
+	jars, err := parseQuilt(&m, []byte(data))
+	if err != nil {
+		t.Fatalf("parseQuilt: %v", err)
+	}
+	want := Mod{
+		Loader:      LoaderQuilt,
+		ID:          "example",
+		Name:        "Example",
+		Version:     "2.0.0",
+		Description: "A mod",
+		Authors:     []string{"Alice", "Bob"},
+		Icon:        "large.png",
+		Provides:    []string{"example_api", "example_compat"},
+		Dependencies: []Dependency{
+			{ID: "quilt_loader", Kind: Depends},
+			{ID: "minecraft", Kind: Depends, Versions: []string{">=1.21"}},
+			{ID: "modmenu", Kind: Suggests, Versions: []string{"1.x", "2.x"}},
+			{ID: "optifabric", Kind: Breaks},
+		},
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("mod = %+v\nwant  %+v", m, want)
+	}
+	if !reflect.DeepEqual(jars, []string{"META-INF/jars/lib.jar"}) {
+		t.Errorf("jars = %v", jars)
+	}
+
+	if _, err := parseQuilt(&Mod{}, []byte(`{"quilt_loader": {"version": "1.0"}}`)); err == nil {
+		t.Error("parseQuilt accepted a mod without an id")
+	}
+}
+
+func TestIconPath(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{``, ""},
+		{`"icon.png"`, "icon.png"},
+		{`{"32": "a.png", "256": "c.png", "128": "b.png"}`, "c.png"},
+		{`{"large": "a.png"}`, "a.png"},
+		{`42`, ""},
+	}
+	for _, tt := range tests {
+		if got := iconPath(json.RawMessage(tt.raw)); got != tt.want {
+			t.Errorf("iconPath(%s) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizeJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"{\"a\": \"b\"}", "{\"a\": \"b\"}"},
+		{"{\n\t\"a\": \"line\none\"\n}", "{\n\t\"a\": \"line\\none\"\n}"},
+		{"{\"a\": \"tab\there\"}", "{\"a\": \"tab\\there\"}"},
+		{"{\"a\": \"crlf\r\nend\"}", "{\"a\": \"crlf\\nend\"}"},
+		// An escaped quote doesn't end the string
+		{"{\"a\": \"say \\\"hi\n\\\"\"}", "{\"a\": \"say \\\"hi\\n\\\"\"}"},
+		{"{\"a\": \"back\\\\\", \"b\":\n1}", "{\"a\": \"back\\\\\", \"b\":\n1}"},
+	}
+	for _, tt := range tests {
+		got := string(sanitizeJSON([]byte(tt.in)))
+		if got != tt.want {
+			t.Errorf("sanitizeJSON(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		if !json.Valid([]byte(got)) {
+			t.Errorf("sanitizeJSON(%q) is not valid JSON", tt.in)
+		}
+	}
+}
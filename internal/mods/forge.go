@@ -0,0 +1,85 @@
+package mods
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parseModsTOML reads the first mod of a Forge or NeoForge mods.toml
+func parseModsTOML(m *Mod, loader string, data []byte) error {
+	doc, err := parseTOML(string(data))
+	if err != nil {
+		return err
+	}
+	list, _ := doc["mods"].([]map[string]interface{})
+	if len(list) == 0 {
+		return fmt.Errorf("no [[mods]] entry")
+	}
+	first := list[0]
+
+	m.Loader = loader
+	m.ID = tomlString(first, "modId")
+	if m.ID == "" {
+		return fmt.Errorf("no modId")
+	}
+	m.Name = tomlString(first, "displayName")
+	m.Version = tomlString(first, "version")
+	m.Description = strings.TrimSpace(tomlString(first, "description"))
+	m.Icon = tomlString(first, "logoFile")
+	if m.Icon == "" {
+		m.Icon = tomlString(doc, "logoFile")
+	}
+	authors := tomlString(first, "authors")
+	if authors == "" {
+		authors = tomlString(doc, "authors")
+	}
+	for _, a := range strings.Split(authors, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			m.Authors = append(m.Authors, a)
+		}
+	}
+	for _, extra := range list[1:] {
+		if id := tomlString(extra, "modId"); id != "" {
+			m.Provides = append(m.Provides, id)
+		}
+	}
+
+	deps, _ := doc["dependencies"].(map[string]interface{})
+	entries, _ := deps[m.ID].([]map[string]interface{})
+	for _, entry := range entries {
+		id := tomlString(entry, "modId")
+		if id == "" {
+			continue
+		}
+		dep := Dependency{ID: id, Kind: forgeDependencyKind(entry)}
+		if r := tomlString(entry, "versionRange"); r != "" && r != "*" {
+			dep.Versions = []string{r}
+		}
+		m.Dependencies = append(m.Dependencies, dep)
+	}
+	return nil
+}
+
+// forgeDependencyKind maps NeoForge's type field, or Forge's older mandatory
+// flag, onto the fabric.mod.json kinds
+func forgeDependencyKind(entry map[string]interface{}) string {
+	switch strings.ToLower(tomlString(entry, "type")) {
+	case "required":
+		return Depends
+	case "optional":
+		return Suggests
+	case "incompatible":
+		return Breaks
+	case "discouraged":
+		return Conflicts
+	}
+	if mandatory, ok := entry["mandatory"].(bool); ok && !mandatory {
+		return Suggests
+	}
+	return Depends
+}
+
+func tomlString(table map[string]interface{}, key string) string {
+	s, _ := table[key].(string)
+	return s
+}
@@ -0,0 +1,267 @@
+package mods
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxNestingDepth bounds how deep jar-in-jar mods are read
+const maxNestingDepth = 3
+
+// DisabledSuffix is appended to a jar's name to keep the loader from seeing it
+const DisabledSuffix = ".disabled"
+
+// Loaders a mod can declare metadata for
+const (
+	LoaderFabric   = "fabric"
+	LoaderQuilt    = "quilt"
+	LoaderForge    = "forge"
+	LoaderNeoForge = "neoforge"
+)
+
+// Kinds of dependency, named after the fabric.mod.json fields
+const (
+	Depends    = "depends"
+	Recommends = "recommends"
+	Suggests   = "suggests"
+	Breaks     = "breaks"
+	Conflicts  = "conflicts"
+)
+
+type Dependency struct {
+	ID   string
+	Kind string
+	// Versions are alternative version ranges in the loader's own syntax,
+	// any one of them is enough. Empty means any version.
+	Versions []string
+}
+
+// Mod is a jar in an instance's mods folder
+type Mod struct {
+	Path     string // file on disk, ending in .jar or .jar.disabled
+	FileName string
+	Enabled  bool
+
+	Loader       string // "" if the jar has no metadata we understand
+	ID           string
+	Name         string
+	Version      string
+	Description  string
+	Authors      []string
+	Icon         string // path of the icon inside the jar
+	Dependencies []Dependency
+	Provides     []string // extra ids the mod answers to
+	Nested       []*Mod   // mods bundled inside the jar
+}
+
+// Dir returns the mods folder of a game directory
+func Dir(gameDir string) string {
+	return filepath.Join(gameDir, "mods")
+}
+
+// List reads every enabled and disabled jar in gameDir/mods, sorted by name.
+// Jars that can't be read are still listed with what is known about them.
+func List(gameDir string) ([]*Mod, error) {
+	entries, err := os.ReadDir(Dir(gameDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var mods []*Mod
+	for _, e := range entries {
+		if e.IsDir() || !isModFile(e.Name()) {
+			continue
+		}
+		m, err := Read(filepath.Join(Dir(gameDir), e.Name()))
+		if err != nil {
+			m = newMod(filepath.Join(Dir(gameDir), e.Name()))
+		}
+		mods = append(mods, m)
+	}
+
+	sort.SliceStable(mods, func(a, b int) bool {
+		return strings.ToLower(mods[a].DisplayName()) < strings.ToLower(mods[b].DisplayName())
+	})
+	return mods, nil
+}
+
+// Read opens one jar and parses its fabric.mod.json, quilt.mod.json or
+// mods.toml, whichever it has, preferring the loader-specific ones
+func Read(jarPath string) (*Mod, error) {
+	r, err := zip.OpenReader(jarPath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	m := newMod(jarPath)
+	if err := m.readMetadata(&r.Reader, 0); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *Mod) readMetadata(r *zip.Reader, depth int) error {
+	files := map[string]*zip.File{}
+	for _, f := range r.File {
+		files[f.Name] = f
+	}
+
+	var nested []string
+	switch {
+	case files["quilt.mod.json"] != nil:
+		data, err := readZipFile(files["quilt.mod.json"])
+		if err == nil {
+			nested, err = parseQuilt(m, data)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read quilt.mod.json in %s: %v", m.FileName, err)
+		}
+	case files["fabric.mod.json"] != nil:
+		data, err := readZipFile(files["fabric.mod.json"])
+		if err == nil {
+			nested, err = parseFabric(m, data)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read fabric.mod.json in %s: %v", m.FileName, err)
+		}
+	case files["META-INF/neoforge.mods.toml"] != nil || files["META-INF/mods.toml"] != nil:
+		loader, f := LoaderNeoForge, files["META-INF/neoforge.mods.toml"]
+		if f == nil {
+			loader, f = LoaderForge, files["META-INF/mods.toml"]
+		}
+		data, err := readZipFile(f)
+		if err == nil {
+			err = parseModsTOML(m, loader, data)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s in %s: %v", f.Name, m.FileName, err)
+		}
+		if strings.Contains(m.Version, "${") {
+			if manifest, ok := files["META-INF/MANIFEST.MF"]; ok {
+				if data, err := readZipFile(manifest); err == nil {
+					m.Version = implementationVersion(data, m.Version)
+				}
+			}
+		}
+	}
+
+	// Jar-in-jar libraries, which the loader treats as mods of their own
+	if depth >= maxNestingDepth {
+		return nil
+	}
+	for _, name := range nested {
+		f, ok := files[strings.TrimPrefix(path.Clean("/"+name), "/")]
+		if !ok {
+			continue
+		}
+		data, err := readZipFile(f)
+		if err != nil {
+			continue
+		}
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			continue
+		}
+		child := &Mod{Path: m.Path + "!/" + f.Name, FileName: path.Base(f.Name), Enabled: m.Enabled}
+		if child.readMetadata(zr, depth+1) == nil && child.ID != "" {
+			m.Nested = append(m.Nested, child)
+		}
+	}
+	return nil
+}
+
+// DisplayName is the mod's name, or its file name when it has none
+func (m *Mod) DisplayName() string {
+	if m.Name != "" {
+		return m.Name
+	}
+	if m.ID != "" {
+		return m.ID
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(m.FileName, DisabledSuffix), ".jar")
+}
+
+// IconData reads the mod's icon out of its jar
+func (m *Mod) IconData() ([]byte, error) {
+	if m.Icon == "" {
+		return nil, fmt.Errorf("%s has no icon", m.DisplayName())
+	}
+	r, err := zip.OpenReader(m.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	name := strings.TrimPrefix(path.Clean("/"+m.Icon), "/")
+	for _, f := range r.File {
+		if f.Name == name {
+			return readZipFile(f)
+		}
+	}
+	return nil, fmt.Errorf("icon %s is missing from %s", m.Icon, m.FileName)
+}
+
+// SetEnabled renames the jar to or from .jar.disabled
+func (m *Mod) SetEnabled(enabled bool) error {
+	if m.Enabled == enabled {
+		return nil
+	}
+	target := strings.TrimSuffix(m.Path, DisabledSuffix)
+	if !enabled {
+		target = m.Path + DisabledSuffix
+	}
+	if _, err := os.Stat(target); err == nil {
+		return fmt.Errorf("%s already exists", filepath.Base(target))
+	}
+	if err := os.Rename(m.Path, target); err != nil {
+		return err
+	}
+	m.Path = target
+	m.FileName = filepath.Base(target)
+	m.Enabled = enabled
+	return nil
+}
+
+// Delete removes the jar from disk
+func (m *Mod) Delete() error {
+	return os.Remove(m.Path)
+}
+
+func newMod(jarPath string) *Mod {
+	name := filepath.Base(jarPath)
+	return &Mod{Path: jarPath, FileName: name, Enabled: !strings.HasSuffix(name, DisabledSuffix)}
+}
+
+func isModFile(name string) bool {
+	return strings.HasSuffix(name, ".jar") || strings.HasSuffix(name, ".jar"+DisabledSuffix)
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// implementationVersion fills in ${file.jarVersion} from the jar manifest
+func implementationVersion(manifest []byte, fallback string) string {
+	for _, line := range strings.Split(string(manifest), "\n") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(line), "Implementation-Version:"); ok {
+			return strings.TrimSpace(v)
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,219 @@
+package mods
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// jarData zips files into an in-memory jar
+func jarData(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, data := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(data)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func writeJar(t *testing.T, path string, files map[string][]byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, jarData(t, files), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fabricJar builds the files of a Fabric mod bundling jars, which are stored
+// under META-INF/jars/
+func fabricJar(t *testing.T, id, name string, jars map[string][]byte) map[string][]byte {
+	t.Helper()
+	files := map[string][]byte{}
+	var entries []string
+	for jar, data := range jars {
+		files["META-INF/jars/"+jar] = data
+		entries = append(entries, fmt.Sprintf(`{"file": "META-INF/jars/%s"}`, jar))
+	}
+	files["fabric.mod.json"] = []byte(fmt.Sprintf(`{"schemaVersion": 1, "id": %q, "name": %q, "version": "1.0.0", "jars": [%s]}`,
+		id, name, strings.Join(entries, ", ")))
+	return files
+}
+
+func TestList(t *testing.T) {
+	gameDir := t.TempDir()
+	dir := Dir(gameDir)
+	writeJar(t, filepath.Join(dir, "sodium.jar"), fabricJar(t, "sodium", "Sodium", nil))
+	writeJar(t, filepath.Join(dir, "lithium.jar.disabled"), fabricJar(t, "lithium", "Lithium", nil))
+	writeJar(t, filepath.Join(dir, "Quilted.jar"), map[string][]byte{
+		"quilt.mod.json": []byte(`{"quilt_loader": {"id": "qsl", "version": "1.0.0", "metadata": {"name": "amazing"}}}`),
+	})
+	// Listed by file name, even though neither can be read
+	writeJar(t, filepath.Join(dir, "no-metadata.jar"), map[string][]byte{"a.class": nil})
+	if err := os.WriteFile(filepath.Join(dir, "broken.jar"), []byte("not a zip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Not mods
+	os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0644)
+	os.Mkdir(filepath.Join(dir, "folder.jar"), 0755)
+
+	list, err := List(gameDir)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	var got []string
+	for _, m := range list {
+		got = append(got, fmt.Sprintf("%s %s %v", m.FileName, m.DisplayName(), m.Enabled))
+	}
+	want := []string{
+		"Quilted.jar amazing true",
+		"broken.jar broken true",
+		"lithium.jar.disabled Lithium false",
+		"no-metadata.jar no-metadata true",
+		"sodium.jar Sodium true",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("List = %q\nwant   %q", got, want)
+	}
+}
+
+func TestListWithoutModsFolder(t *testing.T) {
+	list, err := List(t.TempDir())
+	if err != nil || list != nil {
+		t.Errorf("List = %v, %v, want nothing", list, err)
+	}
+}
+
+func TestReadNested(t *testing.T) {
+	// outer bundles a, a bundles b and so on down to e. Only the first
+	// maxNestingDepth levels are read.
+	chain := []string{"outer", "a", "b", "c", "d", "e"}
+	var bundled map[string][]byte
+	for i := len(chain) - 1; i > 0; i-- {
+		bundled = map[string][]byte{chain[i] + ".jar": jarData(t, fabricJar(t, chain[i], chain[i], bundled))}
+	}
+	path := filepath.Join(t.TempDir(), "outer.jar")
+	writeJar(t, path, fabricJar(t, "outer", "Outer", bundled))
+
+	m, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	var got []string
+	for n := m; len(n.Nested) > 0; n = n.Nested[0] {
+		if len(n.Nested) != 1 {
+			t.Fatalf("%s has %d nested mods, want 1", n.ID, len(n.Nested))
+		}
+		got = append(got, n.Nested[0].ID)
+	}
+	if want := chain[1 : 1+maxNestingDepth]; !reflect.DeepEqual(got, want) {
+		t.Errorf("nested ids = %v, want %v", got, want)
+	}
+	a := m.Nested[0]
+	if a.FileName != "a.jar" || a.Path != path+"!/META-INF/jars/a.jar" || !a.Enabled {
+		t.Errorf("nested mod = %s at %s, enabled %v", a.FileName, a.Path, a.Enabled)
+	}
+}
+
+func TestReadSkipsBadNestedJars(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outer.jar")
+	files := fabricJar(t, "outer", "Outer", map[string][]byte{
+		"good.jar":        jarData(t, fabricJar(t, "good", "Good", nil)),
+		"not-a-zip.jar":   []byte("garbage"),
+		"no-metadata.jar": jarData(t, map[string][]byte{"a.class": nil}),
+	})
+	// Listed but missing from the jar
+	files["fabric.mod.json"] = bytes.Replace(files["fabric.mod.json"], []byte(`"jars": [`), []byte(`"jars": [{"file": "META-INF/jars/gone.jar"}, `), 1)
+	writeJar(t, path, files)
+
+	m, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(m.Nested) != 1 || m.Nested[0].ID != "good" {
+		t.Errorf("nested = %+v, want only good", m.Nested)
+	}
+}
+
+func TestIconData(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mod.jar")
+	files := fabricJar(t, "example", "Example", nil)
+	files["fabric.mod.json"] = []byte(`{"id": "example", "icon": "./assets/icon.png"}`)
+	files["assets/icon.png"] = []byte("png")
+	writeJar(t, path, files)
+
+	m, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if data, err := m.IconData(); err != nil || string(data) != "png" {
+		t.Errorf("IconData = %q, %v", data, err)
+	}
+}
+
+func TestSetEnabled(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sodium.jar")
+	writeJar(t, path, fabricJar(t, "sodium", "Sodium", nil))
+	m, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+
+	if err := m.SetEnabled(false); err != nil {
+		t.Fatalf("SetEnabled(false): %v", err)
+	}
+	if m.Enabled || m.FileName != "sodium.jar.disabled" || m.Path != path+DisabledSuffix {
+		t.Errorf("disabled mod = %s at %s, enabled %v", m.FileName, m.Path, m.Enabled)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("the enabled jar is still there")
+	}
+	if err := m.SetEnabled(false); err != nil {
+		t.Errorf("disabling twice: %v", err)
+	}
+
+	// Another copy has since been dropped in, which must not be overwritten
+	if err := os.WriteFile(path, []byte("another copy"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = m.SetEnabled(true)
+	if err == nil || !strings.Contains(err.Error(), "sodium.jar already exists") {
+		t.Fatalf("SetEnabled(true) = %v, want an already exists error", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "another copy" {
+		t.Error("the other copy was overwritten")
+	}
+	if m.Enabled || m.Path != path+DisabledSuffix {
+		t.Error("a failed SetEnabled changed the mod")
+	}
+
+	os.Remove(path)
+	if err := m.SetEnabled(true); err != nil {
+		t.Fatalf("SetEnabled(true): %v", err)
+	}
+	if !m.Enabled || m.Path != path {
+		t.Errorf("enabled mod = %s, enabled %v", m.Path, m.Enabled)
+	}
+
+	if err := m.Delete(); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("%d files left after Delete", len(entries))
+	}
+}
@@ -0,0 +1,266 @@
+package mods
+
+import (
+	"strconv"
+	"strings"
+
+	"Nix-Client-Launcher/internal/game/libraries"
+)
+
+// semVersion is a version in Fabric's relaxed SemVer: any number of numeric
+// core segments, an optional pre-release and ignored build metadata
+type semVersion struct {
+	core []int
+	pre  []string
+}
+
+func parseSemVer(s string) (semVersion, bool) {
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+	var v semVersion
+	core := s
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		core = s[:i]
+		v.pre = strings.Split(s[i+1:], ".")
+	}
+	if core == "" {
+		return v, false
+	}
+	for _, seg := range strings.Split(core, ".") {
+		n, err := strconv.Atoi(seg)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		v.core = append(v.core, n)
+	}
+	return v, true
+}
+
+// compareCore compares the numeric parts only, missing segments count as 0
+func compareCore(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func compareSemVer(a, b semVersion) int {
+	if c := compareCore(a.core, b.core); c != 0 {
+		return c
+	}
+	// A release sorts after its pre-releases
+	switch {
+	case len(a.pre) == 0 && len(b.pre) == 0:
+		return 0
+	case len(a.pre) == 0:
+		return 1
+	case len(b.pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(a.pre) && i < len(b.pre); i++ {
+		x, xErr := strconv.Atoi(a.pre[i])
+		y, yErr := strconv.Atoi(b.pre[i])
+		var c int
+		switch {
+		case xErr == nil && yErr == nil:
+			c = compareInts(x, y)
+		case xErr == nil:
+			c = -1
+		case yErr == nil:
+			c = 1
+		default:
+			c = strings.Compare(a.pre[i], b.pre[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return compareInts(len(a.pre), len(b.pre))
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// matchesAny reports whether version satisfies any of the alternative
+// ranges, read in the syntax of the loader that declared them. ok is false
+// when the version or a range can't be understood, the caller shouldn't
+// report a mismatch then.
+func matchesAny(loader, version string, ranges []string) (match, ok bool) {
+	if len(ranges) == 0 {
+		return true, true
+	}
+	ok = true
+	for _, r := range ranges {
+		var m, understood bool
+		if loader == LoaderForge || loader == LoaderNeoForge {
+			m, understood = matchMaven(version, r)
+		} else {
+			m, understood = matchFabric(version, r)
+		}
+		if m {
+			return true, true
+		}
+		ok = ok && understood
+	}
+	return false, ok
+}
+
+// matchFabric checks a Fabric/Quilt range: space separated predicates that
+// must all hold, each an operator (=, >, >=, <, <=, ~, ^) and a version that
+// may end in x wildcards
+func matchFabric(version, r string) (bool, bool) {
+	r = strings.TrimSpace(r)
+	if r == "" || r == "*" {
+		return true, true
+	}
+	v, semver := parseSemVer(version)
+
+	for _, pred := range strings.Fields(r) {
+		op := ""
+		for _, candidate := range []string{">=", "<=", ">", "<", "=", "~", "^"} {
+			if strings.HasPrefix(pred, candidate) {
+				op = candidate
+				break
+			}
+		}
+		target := strings.TrimPrefix(pred, op)
+
+		if !semver {
+			// Fabric compares non-SemVer versions by equality only
+			if op != "" && op != "=" {
+				return false, false
+			}
+			if version != target {
+				return false, true
+			}
+			continue
+		}
+
+		if prefix, wildcard := wildcardPrefix(target); wildcard {
+			if op != "" && op != "=" {
+				return false, false
+			}
+			if len(v.core) < len(prefix) || compareCore(v.core[:len(prefix)], prefix) != 0 {
+				return false, true
+			}
+			continue
+		}
+
+		t, valid := parseSemVer(target)
+		if !valid {
+			return false, false
+		}
+		c := compareSemVer(v, t)
+		var holds bool
+		switch op {
+		case "", "=":
+			holds = c == 0
+		case ">":
+			holds = c > 0
+		case ">=":
+			holds = c >= 0
+		case "<":
+			holds = c < 0
+		case "<=":
+			holds = c <= 0
+		case "~":
+			holds = c >= 0 && compareCore(v.core, bump(t.core, 1)) < 0
+		case "^":
+			holds = c >= 0 && compareCore(v.core, bump(t.core, 0)) < 0
+		}
+		if !holds {
+			return false, true
+		}
+	}
+	return true, true
+}
+
+// wildcardPrefix splits "1.21.x" into [1 21]
+func wildcardPrefix(target string) ([]int, bool) {
+	segs := strings.Split(target, ".")
+	last := segs[len(segs)-1]
+	if last != "x" && last != "X" && last != "*" {
+		return nil, false
+	}
+	var prefix []int
+	for _, seg := range segs[:len(segs)-1] {
+		n, err := strconv.Atoi(seg)
+		if err != nil {
+			return nil, false
+		}
+		prefix = append(prefix, n)
+	}
+	return prefix, true
+}
+
+// bump returns the smallest core above every version sharing core[:at+1]
+func bump(core []int, at int) []int {
+	out := make([]int, at+1)
+	copy(out, core)
+	if at < len(core) {
+		out[at] = core[at] + 1
+	} else {
+		out[at] = 1
+	}
+	return out
+}
+
+// matchMaven checks a Forge Maven range such as "[47,)", "(,1.2]" or several
+// joined with commas. A bare version is only a recommendation and matches
+// anything.
+func matchMaven(version, r string) (bool, bool) {
+	r = strings.TrimSpace(r)
+	if r == "" || r == "*" || !strings.ContainsAny(r, "[(") {
+		return true, true
+	}
+
+	for r != "" {
+		end := strings.IndexAny(r, "])")
+		if end < 0 || (r[0] != '[' && r[0] != '(') {
+			return false, false
+		}
+		spec := r[1:end]
+		lowerInclusive, upperInclusive := r[0] == '[', r[end] == ']'
+		r = strings.TrimPrefix(strings.TrimSpace(r[end+1:]), ",")
+		r = strings.TrimSpace(r)
+
+		lower, upper := spec, spec
+		if i := strings.IndexByte(spec, ','); i >= 0 {
+			lower, upper = strings.TrimSpace(spec[:i]), strings.TrimSpace(spec[i+1:])
+		}
+		if lower != "" {
+			c := libraries.CompareVersions(version, lower)
+			if c < 0 || (c == 0 && !lowerInclusive) {
+				continue
+			}
+		}
+		if upper != "" {
+			c := libraries.CompareVersions(version, upper)
+			if c > 0 || (c == 0 && !upperInclusive) {
+				continue
+			}
+		}
+		return true, true
+	}
+	return false, true
+}
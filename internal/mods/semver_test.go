@@ -0,0 +1,130 @@
+package mods
+
+import "testing"
+
+func TestMatchFabric(t *testing.T) {
+	tests := []struct {
+		version, r string
+		match, ok  bool
+	}{
+		{"1.0.0", "", true, true},
+		{"1.0.0", "*", true, true},
+		{"1.20.1", "1.20.1", true, true},
+		{"1.20.1", "=1.20.1", true, true},
+		{"1.20", "=1.20.0", true, true},
+		{"1.20.2", "1.20.1", false, true},
+		{"1.20.1", ">1.20", true, true},
+		{"1.20", ">1.20", false, true},
+		{"1.20", ">=1.20", true, true},
+		{"1.19.4", ">=1.20", false, true},
+		{"1.19.4", "<1.20", true, true},
+		{"1.20", "<=1.20", true, true},
+		{"21", ">=17", true, true},
+		{"17", ">=21", false, true},
+		// Every space separated predicate must hold
+		{"1.20.4", ">=1.20 <1.21", true, true},
+		{"1.21", ">=1.20 <1.21", false, true},
+		// ~ allows patch updates, ^ minor ones
+		{"1.2.9", "~1.2.3", true, true},
+		{"1.3.0", "~1.2.3", false, true},
+		{"1.2.2", "~1.2.3", false, true},
+		{"1.0.5", "~1", true, true},
+		{"1.2", "~1", false, true},
+		{"1.9.0", "^1.2.3", true, true},
+		{"2.0.0", "^1.2.3", false, true},
+		{"0.9", "^0.5", true, true},
+		// Wildcards
+		{"1.21.4", "1.21.x", true, true},
+		{"1.21", "1.21.x", true, true},
+		{"1.22.0", "1.21.x", false, true},
+		{"1.20.6", "=1.20.X", true, true},
+		{"1.2.3", "1.*", true, true},
+		{"1.21.4", ">=1.21.x", false, false},
+		// Pre-releases sort before their release
+		{"1.0.0-beta.2", ">=1.0.0-beta.1", true, true},
+		{"1.0.0-beta.2", ">=1.0.0", false, true},
+		{"1.0.0", ">1.0.0-rc.1", true, true},
+		{"1.0.0-alpha", "<1.0.0-alpha.1", true, true},
+		{"1.0.0-alpha.1", "<1.0.0-alpha.beta", true, true},
+		{"1.0.0-2", "<1.0.0-10", true, true},
+		{"0.16.14-beta.1", ">=0.15.0", true, true},
+		// Build metadata is ignored
+		{"0.92.2+1.20.1", "0.92.2", true, true},
+		{"0.92.2+1.20.1", ">=0.92.0", true, true},
+		// Versions that aren't SemVer only compare equal
+		{"1.20.1-forge-47", "1.20.1-forge-47", true, true},
+		{"snapshot", "snapshot", true, true},
+		{"snapshot", "other", false, true},
+		{"snapshot", ">=1.0", false, false},
+		{"1.0.0", ">=latest", false, false},
+	}
+	for _, tt := range tests {
+		match, ok := matchFabric(tt.version, tt.r)
+		if match != tt.match || ok != tt.ok {
+			t.Errorf("matchFabric(%q, %q) = %v, %v, want %v, %v", tt.version, tt.r, match, ok, tt.match, tt.ok)
+		}
+	}
+}
+
+func TestMatchMaven(t *testing.T) {
+	tests := []struct {
+		version, r string
+		match, ok  bool
+	}{
+		{"47.2.0", "", true, true},
+		{"47.2.0", "*", true, true},
+		// A bare version is a recommendation
+		{"1.0", "2.0", true, true},
+		{"47.2.0", "[47,)", true, true},
+		{"46.0.14", "[47,)", false, true},
+		{"47", "(47,)", false, true},
+		{"1.2", "(,1.2]", true, true},
+		{"1.2", "(,1.2)", false, true},
+		{"1.3", "(,1.2]", false, true},
+		{"1.20.1", "[1.20.1,1.21)", true, true},
+		{"1.20.6", "[1.20.1,1.21)", true, true},
+		{"1.21", "[1.20.1,1.21)", false, true},
+		{"1.0", "[1.0]", true, true},
+		{"1.0.1", "[1.0]", false, true},
+		// Alternatives joined with commas
+		{"2.5", "[1,2),[3,)", false, true},
+		{"3.1", "[1,2), [3,)", true, true},
+		{"1.5", "[1,2),[3,)", true, true},
+		{"21.1.77", "[21.1.0,)", true, true},
+		{"1.0", "[1,2", false, false},
+		{"1.0", "1,2]", true, true},
+		{"3.0", "[1,2]x", false, false},
+	}
+	for _, tt := range tests {
+		match, ok := matchMaven(tt.version, tt.r)
+		if match != tt.match || ok != tt.ok {
+			t.Errorf("matchMaven(%q, %q) = %v, %v, want %v, %v", tt.version, tt.r, match, ok, tt.match, tt.ok)
+		}
+	}
+}
+
+func TestMatchesAny(t *testing.T) {
+	tests := []struct {
+		loader, version string
+		ranges          []string
+		match, ok       bool
+	}{
+		{LoaderFabric, "1.0", nil, true, true},
+		{LoaderFabric, "1.20.1", []string{"1.19.x", "1.20.x"}, true, true},
+		{LoaderFabric, "1.18.2", []string{"1.19.x", "1.20.x"}, false, true},
+		{LoaderQuilt, "0.5", []string{">=1.0"}, false, true},
+		// One alternative that can't be read keeps a mismatch from being reported
+		{LoaderFabric, "1.0", []string{">=2.0", ">=latest"}, false, false},
+		{LoaderFabric, "1.0", []string{">=latest", "1.0"}, true, true},
+		// Forge and NeoForge ranges use Maven syntax
+		{LoaderForge, "47.2.0", []string{"[47,)"}, true, true},
+		{LoaderNeoForge, "20.4.1", []string{"[21,)"}, false, true},
+		{LoaderFabric, "47.2.0", []string{"[47,)"}, false, false},
+	}
+	for _, tt := range tests {
+		match, ok := matchesAny(tt.loader, tt.version, tt.ranges)
+		if match != tt.match || ok != tt.ok {
+			t.Errorf("matchesAny(%s, %q, %q) = %v, %v, want %v, %v", tt.loader, tt.version, tt.ranges, match, ok, tt.match, tt.ok)
+		}
+	}
+}
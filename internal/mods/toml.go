@@ -0,0 +1,349 @@
+package mods
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseTOML is a small TOML reader covering what mods.toml files use: tables,
+// arrays of tables, dotted keys, strings, booleans, arrays and inline tables.
+// Numbers and dates are kept as their source text.
+func parseTOML(data string) (map[string]interface{}, error) {
+	p := &tomlParser{s: strings.ReplaceAll(data, "\r\n", "\n")}
+	root := map[string]interface{}{}
+	current := root
+
+	for {
+		p.skipBlank(true)
+		if p.eof() {
+			return root, nil
+		}
+
+		if p.peek() == '[' {
+			array := strings.HasPrefix(p.s[p.i:], "[[")
+			if array {
+				p.i += 2
+			} else {
+				p.i++
+			}
+			keys, err := p.key()
+			if err != nil {
+				return nil, err
+			}
+			closing := "]"
+			if array {
+				closing = "]]"
+			}
+			p.skipBlank(false)
+			if !strings.HasPrefix(p.s[p.i:], closing) {
+				return nil, p.errorf("expected %s", closing)
+			}
+			p.i += len(closing)
+
+			parent, err := descend(root, keys[:len(keys)-1])
+			if err != nil {
+				return nil, p.errorf("%v", err)
+			}
+			last := keys[len(keys)-1]
+			if array {
+				table := map[string]interface{}{}
+				list, _ := parent[last].([]map[string]interface{})
+				parent[last] = append(list, table)
+				current = table
+			} else {
+				table, ok := parent[last].(map[string]interface{})
+				if !ok {
+					table = map[string]interface{}{}
+					parent[last] = table
+				}
+				current = table
+			}
+		} else {
+			if err := p.keyValue(current); err != nil {
+				return nil, err
+			}
+		}
+
+		p.skipBlank(false)
+		if !p.eof() && p.peek() != '\n' {
+			return nil, p.errorf("unexpected %q", p.peek())
+		}
+	}
+}
+
+// descend walks dotted keys from table, creating tables as needed and
+// stepping into the newest entry of an array of tables
+func descend(table map[string]interface{}, keys []string) (map[string]interface{}, error) {
+	for _, k := range keys {
+		switch next := table[k].(type) {
+		case nil:
+			created := map[string]interface{}{}
+			table[k] = created
+			table = created
+		case map[string]interface{}:
+			table = next
+		case []map[string]interface{}:
+			table = next[len(next)-1]
+		default:
+			return nil, fmt.Errorf("key %s is not a table", k)
+		}
+	}
+	return table, nil
+}
+
+type tomlParser struct {
+	s string
+	i int
+}
+
+func (p *tomlParser) eof() bool  { return p.i >= len(p.s) }
+func (p *tomlParser) peek() byte { return p.s[p.i] }
+
+func (p *tomlParser) errorf(format string, args ...interface{}) error {
+	line := strings.Count(p.s[:p.i], "\n") + 1
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// skipBlank skips spaces and comments, and newlines too when newlines is set
+func (p *tomlParser) skipBlank(newlines bool) {
+	for !p.eof() {
+		switch c := p.peek(); {
+		case c == ' ' || c == '\t' || c == '\r':
+			p.i++
+		case c == '\n' && newlines:
+			p.i++
+		case c == '#':
+			for !p.eof() && p.peek() != '\n' {
+				p.i++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *tomlParser) keyValue(table map[string]interface{}) error {
+	keys, err := p.key()
+	if err != nil {
+		return err
+	}
+	p.skipBlank(false)
+	if p.eof() || p.peek() != '=' {
+		return p.errorf("expected = after %s", strings.Join(keys, "."))
+	}
+	p.i++
+	p.skipBlank(false)
+	value, err := p.value()
+	if err != nil {
+		return err
+	}
+	parent, err := descend(table, keys[:len(keys)-1])
+	if err != nil {
+		return p.errorf("%v", err)
+	}
+	parent[keys[len(keys)-1]] = value
+	return nil
+}
+
+func (p *tomlParser) key() ([]string, error) {
+	var keys []string
+	for {
+		p.skipBlank(false)
+		if p.eof() {
+			return nil, p.errorf("expected a key")
+		}
+		switch p.peek() {
+		case '"', '\'':
+			k, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, k.(string))
+		default:
+			start := p.i
+			for !p.eof() && isBareKeyChar(p.peek()) {
+				p.i++
+			}
+			if start == p.i {
+				return nil, p.errorf("unexpected %q in key", p.peek())
+			}
+			keys = append(keys, p.s[start:p.i])
+		}
+		p.skipBlank(false)
+		if p.eof() || p.peek() != '.' {
+			return keys, nil
+		}
+		p.i++
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+func (p *tomlParser) value() (interface{}, error) {
+	if p.eof() {
+		return nil, p.errorf("expected a value")
+	}
+	rest := p.s[p.i:]
+	switch {
+	case strings.HasPrefix(rest, `"""`):
+		return p.multiline(`"""`, true)
+	case strings.HasPrefix(rest, "'''"):
+		return p.multiline("'''", false)
+	case rest[0] == '"':
+		end := p.i + 1
+		for end < len(p.s) && p.s[end] != '"' {
+			if p.s[end] == '\\' {
+				end++
+			}
+			if end < len(p.s) && p.s[end] == '\n' {
+				return nil, p.errorf("unterminated string")
+			}
+			end++
+		}
+		if end >= len(p.s) {
+			return nil, p.errorf("unterminated string")
+		}
+		s, err := unescape(p.s[p.i+1 : end])
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		p.i = end + 1
+		return s, nil
+	case rest[0] == '\'':
+		end := strings.IndexAny(rest[1:], "'\n")
+		if end < 0 || rest[1+end] != '\'' {
+			return nil, p.errorf("unterminated string")
+		}
+		p.i += end + 2
+		return rest[1 : 1+end], nil
+	case rest[0] == '[':
+		return p.array()
+	case rest[0] == '{':
+		return p.inlineTable()
+	case strings.HasPrefix(rest, "true"):
+		p.i += 4
+		return true, nil
+	case strings.HasPrefix(rest, "false"):
+		p.i += 5
+		return false, nil
+	}
+
+	start := p.i
+	for !p.eof() && !strings.ContainsRune(",]}#\n", rune(p.peek())) {
+		p.i++
+	}
+	raw := strings.TrimSpace(p.s[start:p.i])
+	if raw == "" {
+		return nil, p.errorf("expected a value")
+	}
+	return raw, nil
+}
+
+func (p *tomlParser) multiline(quote string, escapes bool) (interface{}, error) {
+	p.i += len(quote)
+	// A newline straight after the opening quotes is not part of the string
+	if !p.eof() && p.peek() == '\n' {
+		p.i++
+	}
+	end := strings.Index(p.s[p.i:], quote)
+	if end < 0 {
+		return nil, p.errorf("unterminated string")
+	}
+	s := p.s[p.i : p.i+end]
+	p.i += end + len(quote)
+	if !escapes {
+		return s, nil
+	}
+	// A backslash at the end of a line joins it with the next non-blank text
+	for {
+		idx := strings.Index(s, "\\\n")
+		if idx < 0 {
+			break
+		}
+		s = s[:idx] + strings.TrimLeft(s[idx+2:], " \t\n")
+	}
+	out, err := unescape(s)
+	if err != nil {
+		return nil, p.errorf("%v", err)
+	}
+	return out, nil
+}
+
+func (p *tomlParser) array() (interface{}, error) {
+	p.i++
+	var list []interface{}
+	for {
+		p.skipBlank(true)
+		if p.eof() {
+			return nil, p.errorf("unterminated array")
+		}
+		if p.peek() == ']' {
+			p.i++
+			return list, nil
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+		p.skipBlank(true)
+		if !p.eof() && p.peek() == ',' {
+			p.i++
+		}
+	}
+}
+
+func (p *tomlParser) inlineTable() (interface{}, error) {
+	p.i++
+	table := map[string]interface{}{}
+	for {
+		p.skipBlank(false)
+		if p.eof() {
+			return nil, p.errorf("unterminated inline table")
+		}
+		if p.peek() == '}' {
+			p.i++
+			return table, nil
+		}
+		if err := p.keyValue(table); err != nil {
+			return nil, err
+		}
+		p.skipBlank(false)
+		if !p.eof() && p.peek() == ',' {
+			p.i++
+		}
+	}
+}
+
+// unescape handles TOML basic string escapes, which are a subset of Go's.
+// Raw quotes and newlines can appear in multi-line strings.
+func unescape(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s):
+			b.WriteByte(c)
+			b.WriteByte(s[i+1])
+			i++
+		case c == '"':
+			b.WriteString(`\"`)
+		case c == '\n':
+			b.WriteString(`\n`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	out, err := strconv.Unquote(b.String())
+	if err != nil {
+		return "", fmt.Errorf("bad escape in %q", s)
+	}
+	return out, nil
+}
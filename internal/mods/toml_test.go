@@ -0,0 +1,309 @@
+package mods
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type table = map[string]interface{}
+
+func TestParseTOML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want table
+	}{
+		{
+			name: "bare keys and scalars",
+			in:   "modLoader = \"javafml\"\nloaderVersion='[47,)'\nshowAsResourcePack = false\nclientSideOnly = true\nweight = 10\n",
+			want: table{
+				"modLoader":          "javafml",
+				"loaderVersion":      "[47,)",
+				"showAsResourcePack": false,
+				"clientSideOnly":     true,
+				"weight":             "10",
+			},
+		},
+		{
+			name: "comments and blank lines",
+			in:   "# leading comment\n\n  key = \"value\" # trailing comment\n\t# indented comment\nhash = \"a # inside a string\"\n",
+			want: table{"key": "value", "hash": "a # inside a string"},
+		},
+		{
+			name: "quoted and dotted keys",
+			in:   "\"quoted key\" = 1\n'literal.key' = 2\nsite.\"sub key\".leaf = \"x\"\n",
+			want: table{
+				"quoted key":  "1",
+				"literal.key": "2",
+				"site":        table{"sub key": table{"leaf": "x"}},
+			},
+		},
+		{
+			name: "escapes",
+			in:   `s = "tab\there \"quoted\" \\ back \u00e9"`,
+			want: table{"s": "tab\there \"quoted\" \\ back é"},
+		},
+		{
+			name: "multi-line basic string",
+			in:   "description = \"\"\"\nFirst line\n  \"indented\" second\n\"\"\"\n",
+			want: table{"description": "First line\n  \"indented\" second\n"},
+		},
+		{
+			name: "multi-line basic string with line continuation",
+			in:   "s = \"\"\"\\\n    joined \\\n    line\"\"\"\n",
+			want: table{"s": "joined line"},
+		},
+		{
+			name: "multi-line literal string keeps backslashes",
+			in:   "s = '''\nC:\\mods\\\n'''\n",
+			want: table{"s": "C:\\mods\\\n"},
+		},
+		{
+			name: "arrays across lines",
+			in:   "list = [\n  \"a\", # first\n  'b',\n  [1, 2],\n]\nempty = []\n",
+			want: table{
+				"list":  []interface{}{"a", "b", []interface{}{"1", "2"}},
+				"empty": []interface{}(nil),
+			},
+		},
+		{
+			name: "inline tables",
+			in:   "dep = { modId = \"forge\", mandatory = true, nested = { a = 'b' } }\nempty = {}\n",
+			want: table{
+				"dep":   table{"modId": "forge", "mandatory": true, "nested": table{"a": "b"}},
+				"empty": table{},
+			},
+		},
+		{
+			name: "tables",
+			in:   "[a]\nx = 1\n[a.b]\ny = 2\n[ c . \"d\" ]\nz = 3\n",
+			want: table{
+				"a": table{"x": "1", "b": table{"y": "2"}},
+				"c": table{"d": table{"z": "3"}},
+			},
+		},
+		{
+			name: "arrays of tables",
+			in:   "[[mods]]\nmodId = \"one\"\n[[mods]]\nmodId = \"two\"\n[mods.sub]\nk = true\n",
+			want: table{
+				"mods": []map[string]interface{}{
+					{"modId": "one"},
+					{"modId": "two", "sub": table{"k": true}},
+				},
+			},
+		},
+		{
+			name: "arrays of tables under a dotted key",
+			in:   "[[dependencies.examplemod]]\nmodId = \"forge\"\n[[dependencies.examplemod]]\nmodId = \"minecraft\"\n[[dependencies.other]]\nmodId = \"x\"\n",
+			want: table{
+				"dependencies": table{
+					"examplemod": []map[string]interface{}{{"modId": "forge"}, {"modId": "minecraft"}},
+					"other":      []map[string]interface{}{{"modId": "x"}},
+				},
+			},
+		},
+		{
+			name: "windows line endings",
+			in:   "a = 1\r\n[t]\r\nb = \"x\"\r\n",
+			want: table{"a": "1", "t": table{"b": "x"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTOML(tt.in)
+			if err != nil {
+				t.Fatalf("parseTOML: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTOML =\n%#v\nwant\n%#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTOMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"unterminated string", "a = \"open\n", "line 1: unterminated string"},
+		{"unterminated multi-line string", "a = \"\"\"\nnever closed\n", "unterminated string"},
+		{"unterminated array", "a = [1, 2\n", "unterminated array"},
+		{"unterminated inline table", "a = { b = 1", "unterminated inline table"},
+		{"missing equals", "\n\nkey \"value\"\n", "line 3: expected ="},
+		{"missing value", "a =\n", "expected a value"},
+		{"unclosed table header", "[mods\n", "expected ]"},
+		{"unclosed array of tables header", "[[mods]\n", "expected ]]"},
+		{"two values on a line", "a = \"x\" \"y\"\n", "unexpected"},
+		{"value used as a table", "a = 1\n[a.b]\n", "not a table"},
+		{"bad escape", `a = "\q"`, "bad escape"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTOML(tt.in)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("parseTOML error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// forgeModsTOML is the mods.toml layout of the Forge 1.20.1 MDK
+const forgeModsTOML = `# This is an example mods.toml file. It contains the data relating to the loading mods.
+modLoader="javafml" #mandatory
+loaderVersion="[47,)" #mandatory This is typically bumped every Minecraft version by Forge.
+license="MIT"
+issueTrackerURL="https://example.com/issues" #optional
+
+[[mods]] #mandatory
+modId="examplemod" #mandatory
+version="1.0.0" #mandatory
+displayName="Example Mod" #mandatory
+logoFile="examplemod.png" #optional
+credits="Thanks to everyone" #optional
+authors="Alice, Bob" #optional
+description='''
+This is a long form description of the mod. You can write whatever you want here
+
+Have some lorem ipsum.
+'''
+
+[[dependencies.examplemod]] #optional
+    modId="forge" #mandatory
+    mandatory=true #mandatory
+    versionRange="[47,)" #mandatory
+    ordering="NONE"
+    side="BOTH"
+
+[[dependencies.examplemod]]
+    modId="minecraft"
+    mandatory=true
+    versionRange="[1.20.1,1.21)"
+    ordering="NONE"
+    side="BOTH"
+
+[[dependencies.examplemod]]
+    modId="jei"
+    mandatory=false
+    versionRange="*"
+    ordering="AFTER"
+    side="CLIENT"
+`
+
+// neoForgeModsTOML is the neoforge.mods.toml layout NeoForge 1.21 uses, with
+// the type field in place of mandatory and a second mod in the same jar
+const neoForgeModsTOML = `modLoader = "javafml"
+loaderVersion = "[4,)"
+license = "LGPL-3.0-or-later"
+authors = "Example Team"
+
+[[mods]]
+modId = "examplemod"
+version = "2.1.0+1.21.1"
+displayName = "Example Mod"
+description = """
+A mod with a "quoted" word.
+"""
+
+[[mods]]
+modId = "examplemod_api"
+version = "2.1.0"
+
+[[mixins]]
+config = "examplemod.mixins.json"
+
+[[accessTransformers]]
+file = "META-INF/accesstransformer.cfg"
+
+[[dependencies.examplemod]]
+modId = "neoforge"
+type = "required"
+versionRange = "[21.1.0,)"
+ordering = "NONE"
+side = "BOTH"
+
+[[dependencies.examplemod]]
+modId = "sodium"
+type = "incompatible"
+reason = "Use Embeddium instead"
+versionRange = "*"
+
+[[dependencies.examplemod]]
+modId = "jei"
+type = "optional"
+versionRange = "[19,)"
+
+[[dependencies.examplemod]]
+modId = "optifine"
+type = "discouraged"
+versionRange = "*"
+`
+
+func TestParseModsTOML(t *testing.T) {
+	tests := []struct {
+		name   string
+		loader string
+		data   string
+		want   Mod
+	}{
+		{
+			name:   "forge",
+			loader: LoaderForge,
+			data:   forgeModsTOML,
+			want: Mod{
+				Loader:      LoaderForge,
+				ID:          "examplemod",
+				Name:        "Example Mod",
+				Version:     "1.0.0",
+				Description: "This is a long form description of the mod. You can write whatever you want here\n\nHave some lorem ipsum.",
+				Authors:     []string{"Alice", "Bob"},
+				Icon:        "examplemod.png",
+				Dependencies: []Dependency{
+					{ID: "forge", Kind: Depends, Versions: []string{"[47,)"}},
+					{ID: "minecraft", Kind: Depends, Versions: []string{"[1.20.1,1.21)"}},
+					{ID: "jei", Kind: Suggests},
+				},
+			},
+		},
+		{
+			name:   "neoforge",
+			loader: LoaderNeoForge,
+			data:   neoForgeModsTOML,
+			want: Mod{
+				Loader:      LoaderNeoForge,
+				ID:          "examplemod",
+				Name:        "Example Mod",
+				Version:     "2.1.0+1.21.1",
+				Description: `A mod with a "quoted" word.`,
+				Authors:     []string{"Example Team"},
+				Provides:    []string{"examplemod_api"},
+				Dependencies: []Dependency{
+					{ID: "neoforge", Kind: Depends, Versions: []string{"[21.1.0,)"}},
+					{ID: "sodium", Kind: Breaks},
+					{ID: "jei", Kind: Suggests, Versions: []string{"[19,)"}},
+					{ID: "optifine", Kind: Conflicts},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Mod
+			if err := parseModsTOML(&got, tt.loader, []byte(tt.data)); err != nil {
+				t.Fatalf("parseModsTOML: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseModsTOML =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseModsTOMLWithoutMods(t *testing.T) {
+	var m Mod
+	if err := parseModsTOML(&m, LoaderForge, []byte("modLoader=\"javafml\"\n")); err == nil {
+		t.Error("parseModsTOML accepted a file without [[mods]]")
+	}
+}
@@ -2,14 +2,28 @@ package storage
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 )
 
 type MinecraftProfile struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Gamertag string `json:"gamertag,omitempty"` // Xbox gamertag, missing for accounts saved before it was stored
+	XUID     string `json:"xuid,omitempty"`
+	Skin     string `json:"skin,omitempty"` // URL of the active skin texture
+}
+
+// DisplayName is the gamertag, or the player name when there is none
+func (p MinecraftProfile) DisplayName() string {
+	if p.Gamertag != "" {
+		return p.Gamertag
+	}
+	return p.Name
 }
 
 type AuthTokens struct {
@@ -37,36 +51,178 @@ func GetConfigDir() (string, error) {
 	return path, nil
 }
 
-func SaveAccount(data AccountData) error {
+// Accounts is every logged in account, keyed by Minecraft profile UUID, and
+// which one the launcher plays with
+type Accounts struct {
+	Active   string                 `json:"active"`
+	Accounts map[string]AccountData `json:"accounts"`
+}
+
+// Get returns the account for a profile UUID
+func (a *Accounts) Get(id string) (*AccountData, bool) {
+	account, ok := a.Accounts[id]
+	if !ok {
+		return nil, false
+	}
+	return &account, true
+}
+
+// ActiveAccount returns the account to play with, or nil when logged out
+func (a *Accounts) ActiveAccount() *AccountData {
+	account, _ := a.Get(a.Active)
+	return account
+}
+
+// List returns every account sorted by player name
+func (a *Accounts) List() []AccountData {
+	list := make([]AccountData, 0, len(a.Accounts))
+	for _, account := range a.Accounts {
+		list = append(list, account)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return strings.ToLower(list[i].Profile.Name) < strings.ToLower(list[j].Profile.Name)
+	})
+	return list
+}
+
+// Put adds an account or replaces the one with the same profile UUID
+func (a *Accounts) Put(data AccountData) {
+	if a.Accounts == nil {
+		a.Accounts = map[string]AccountData{}
+	}
+	a.Accounts[data.Profile.ID] = data
+}
+
+// Remove forgets an account. Removing the active one activates another, if
+// there is one left.
+func (a *Accounts) Remove(id string) {
+	delete(a.Accounts, id)
+	if a.Active == id {
+		a.Active = ""
+		if list := a.List(); len(list) > 0 {
+			a.Active = list[0].Profile.ID
+		}
+	}
+}
+
+// SetActive switches to another stored account
+func (a *Accounts) SetActive(id string) error {
+	if _, ok := a.Accounts[id]; !ok {
+		return fmt.Errorf("no account with id %s", id)
+	}
+	a.Active = id
+	return nil
+}
+
+// LoadAccounts reads accounts.json, returning an empty store when nobody has
+// logged in yet. A file from before multiple accounts is read as one account.
+func LoadAccounts() (*Accounts, error) {
+	dir, err := GetConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "accounts.json"))
+	if os.IsNotExist(err) {
+		return &Accounts{Accounts: map[string]AccountData{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, err
+	}
+	if _, legacy := probe["tokens"]; legacy {
+		var single AccountData
+		if err := json.Unmarshal(data, &single); err != nil {
+			return nil, err
+		}
+		accounts := &Accounts{Active: single.Profile.ID}
+		accounts.Put(single)
+		return accounts, nil
+	}
+
+	var accounts Accounts
+	if err := json.Unmarshal(data, &accounts); err != nil {
+		return nil, err
+	}
+	if accounts.Accounts == nil {
+		accounts.Accounts = map[string]AccountData{}
+	}
+	return &accounts, nil
+}
+
+// SaveAccounts writes accounts.json, readable only by the user as it holds
+// tokens
+func SaveAccounts(accounts *Accounts) error {
 	dir, err := GetConfigDir()
 	if err != nil {
 		return err
 	}
-	file, err := os.Create(filepath.Join(dir, "accounts.json"))
+	data, err := json.MarshalIndent(accounts, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, "accounts.json.tmp")
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, "accounts.json"))
+}
+
+// SaveAccount stores a freshly logged in account and makes it the active one
+func SaveAccount(data AccountData) error {
+	accounts, err := LoadAccounts()
 	if err != nil {
 		return err
 	}
-	defer file.Close()
+	accounts.Put(data)
+	accounts.Active = data.Profile.ID
+	return SaveAccounts(accounts)
+}
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(data)
+// UpdateAccount stores refreshed tokens without changing the active account
+func UpdateAccount(data AccountData) error {
+	accounts, err := LoadAccounts()
+	if err != nil {
+		return err
+	}
+	accounts.Put(data)
+	return SaveAccounts(accounts)
 }
 
+// LoadAccount returns the active account
 func LoadAccount() (*AccountData, error) {
-	dir, err := GetConfigDir()
+	accounts, err := LoadAccounts()
 	if err != nil {
 		return nil, err
 	}
-	file, err := os.Open(filepath.Join(dir, "accounts.json"))
+	account := accounts.ActiveAccount()
+	if account == nil {
+		return nil, os.ErrNotExist
+	}
+	return account, nil
+}
+
+// RemoveAccount logs an account out
+func RemoveAccount(id string) error {
+	accounts, err := LoadAccounts()
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer file.Close()
+	accounts.Remove(id)
+	return SaveAccounts(accounts)
+}
 
-	var data AccountData
-	if err := json.NewDecoder(file).Decode(&data); err != nil {
-		return nil, err
+// SetActiveAccount switches the account the launcher plays with
+func SetActiveAccount(id string) error {
+	accounts, err := LoadAccounts()
+	if err != nil {
+		return err
+	}
+	if err := accounts.SetActive(id); err != nil {
+		return err
 	}
-	return &data, nil
+	return SaveAccounts(accounts)
 }